@@ -58,3 +58,70 @@ func (s aabb) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
 	}
 	return dummyHit
 }
+
+func (s aabb) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return s, true
+}
+
+// hitRange returns the part of tMin to tMax over which the ray is
+// inside the box, or false if it misses.
+func (s aabb) hitRange(r Ray, tMin float64, tMax float64) (float64, float64, bool) {
+	for a := 0; a < 3; a++ {
+		invD := 1 / axis(r.Direction, a)
+		t0 := (axis(s.minimum, a) - axis(r.Origin, a)) * invD
+		t1 := (axis(s.maximum, a) - axis(r.Origin, a)) * invD
+		if invD < 0 {
+			t0, t1 = t1, t0
+		}
+		// NaN, from a ray lying in the plane of a face, must not
+		// narrow the range.
+		if t0 > tMin {
+			tMin = t0
+		}
+		if t1 < tMax {
+			tMax = t1
+		}
+		if tMax < tMin {
+			return 0, 0, false
+		}
+	}
+	return tMin, tMax, true
+}
+
+// surroundingBox returns the box which encloses both boxes.
+func surroundingBox(a aabb, b aabb) aabb {
+	return aabb{
+		minimum: minVector(a.minimum, b.minimum),
+		maximum: maxVector(a.maximum, b.maximum),
+	}
+}
+
+// expand returns the box grown by d along every axis.
+func (s aabb) expand(d float64) aabb {
+	return aabb{
+		minimum: s.minimum.SubtractScalar(d),
+		maximum: s.maximum.AddScalar(d),
+	}
+}
+
+func (s aabb) corners() [8]Vector3 {
+	lo, hi := s.minimum, s.maximum
+	return [8]Vector3{
+		{lo.X, lo.Y, lo.Z},
+		{hi.X, lo.Y, lo.Z},
+		{lo.X, hi.Y, lo.Z},
+		{hi.X, hi.Y, lo.Z},
+		{lo.X, lo.Y, hi.Z},
+		{hi.X, lo.Y, hi.Z},
+		{lo.X, hi.Y, hi.Z},
+		{hi.X, hi.Y, hi.Z},
+	}
+}
+
+func minVector(a Vector3, b Vector3) Vector3 {
+	return Vector3{math.Min(a.X, b.X), math.Min(a.Y, b.Y), math.Min(a.Z, b.Z)}
+}
+
+func maxVector(a Vector3, b Vector3) Vector3 {
+	return Vector3{math.Max(a.X, b.X), math.Max(a.Y, b.Y), math.Max(a.Z, b.Z)}
+}
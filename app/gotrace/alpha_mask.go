@@ -0,0 +1,48 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type alphaMask struct {
+	Object Hittable
+	Alpha  Texture
+}
+
+// NewAlphaMask returns the object with holes cut wherever the first
+// channel of alpha, looked up by the hit's U and V, is under one half,
+// as for leaves or a chain-link fence drawn on a single quad.  Rays
+// pass straight through the holes, so they neither show nor cast
+// shadows.
+func NewAlphaMask(object Hittable, alpha Texture) Hittable {
+	return alphaMask{Object: object, Alpha: alpha}
+}
+
+func (m alphaMask) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	for i := 0; i < maxSkippedHits; i++ {
+		hr := m.Object.Hit(r, tMin, tMax)
+		if hr == nil || m.Alpha.Value(hr.U, hr.V, hr.P).X >= 0.5 {
+			return hr
+		}
+		tMin = math.Nextafter(hr.T, math.Inf(1))
+	}
+	return nil
+}
+
+func (m alphaMask) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return m.Object.BoundingBox(time0, time1)
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Interpolation selects how a Track moves between its keyframes.
+type Interpolation int
+
+// Supported interpolation modes.
+const (
+	InterpolateLinear Interpolation = iota
+	InterpolateSpline
+)
+
+// Keyframe holds the value of a Track at a point in time, in seconds.
+type Keyframe struct {
+	Time  float64
+	Value Vector3
+}
+
+// Track is a keyframed Vector3 value.  Before the first keyframe
+// and after the last one, the value is held constant.
+type Track struct {
+	Keyframes     []Keyframe
+	Interpolation Interpolation
+}
+
+// NewTrack returns a Track with the keyframes sorted by time.
+func NewTrack(interpolation Interpolation, keys ...Keyframe) Track {
+	sorted := make([]Keyframe, len(keys))
+	copy(sorted, keys)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time < sorted[j].Time
+	})
+	return Track{Keyframes: sorted, Interpolation: interpolation}
+}
+
+// StaticTrack returns a Track which always has the same value.
+func StaticTrack(v Vector3) Track {
+	return Track{Keyframes: []Keyframe{{0, v}}}
+}
+
+// At returns the value of the track at the given time.
+func (tr Track) At(time float64) Vector3 {
+	keys := tr.Keyframes
+	if len(keys) == 0 {
+		return Vector3{}
+	}
+	if time <= keys[0].Time {
+		return keys[0].Value
+	}
+	last := len(keys) - 1
+	if time >= keys[last].Time {
+		return keys[last].Value
+	}
+
+	// index of the first keyframe after time; always >= 1 here.
+	i := sort.Search(len(keys), func(n int) bool {
+		return keys[n].Time > time
+	})
+	k0, k1 := keys[i-1], keys[i]
+	dt := k1.Time - k0.Time
+	if dt <= 0 {
+		return k1.Value
+	}
+	u := (time - k0.Time) / dt
+
+	if tr.Interpolation == InterpolateLinear {
+		return k0.Value.Lerp(k1.Value, u)
+	}
+
+	// Cubic Hermite spline, with Catmull-Rom style tangents that take
+	// the spacing of the keyframes into account.
+	m0 := tr.tangent(i - 1).MultiplyScalar(dt)
+	m1 := tr.tangent(i).MultiplyScalar(dt)
+	u2 := u * u
+	u3 := u2 * u
+	h00 := 2*u3 - 3*u2 + 1
+	h10 := u3 - 2*u2 + u
+	h01 := -2*u3 + 3*u2
+	h11 := u3 - u2
+	return k0.Value.MultiplyScalar(h00).
+		Add(m0.MultiplyScalar(h10)).
+		Add(k1.Value.MultiplyScalar(h01)).
+		Add(m1.MultiplyScalar(h11))
+}
+
+// tangent returns the rate of change at keyframe i, using a one-sided
+// difference at either end of the track.
+func (tr Track) tangent(i int) Vector3 {
+	keys := tr.Keyframes
+	prev, next := i-1, i+1
+	if prev < 0 {
+		prev = i
+	}
+	if next >= len(keys) {
+		next = i
+	}
+	dt := keys[next].Time - keys[prev].Time
+	if dt <= 0 {
+		return Vector3{}
+	}
+	return keys[next].Value.Subtract(keys[prev].Value).DivideScalar(dt)
+}
+
+// AnimatedCamera holds keyframed camera placement.  Make builds the
+// camera for each frame, so any projection can be animated.
+type AnimatedCamera struct {
+	LookFrom Track
+	LookAt   Track
+	Vup      Track
+	Make     CameraMaker
+}
+
+// CameraAt returns the camera for a shutter which opens at time0 and
+// closes at time1.  The camera is placed where it is at the middle of
+// the shutter interval.
+func (a AnimatedCamera) CameraAt(time0 float64, time1 float64) Camera {
+	from, at, up := a.Placement((time0 + time1) / 2)
+	return a.Make(from, at, up, time0, time1)
+}
+
+// Placement returns where the camera is, what it is looking at, and
+// which way is up at the given time.
+func (a AnimatedCamera) Placement(time float64) (Vector3, Vector3, Vector3) {
+	return a.LookFrom.At(time), a.LookAt.At(time), a.Vup.At(time)
+}
+
+// Animation describes which frames of a sequence to render.
+type Animation struct {
+	FrameStart   int
+	FrameEnd     int
+	FrameRate    float64
+	ShutterAngle float64
+}
+
+// ShutterInterval returns the times at which the shutter opens and
+// closes for a frame.  Frame N opens at N / FrameRate seconds, and
+// stays open for ShutterAngle/360 of the frame.
+func (a Animation) ShutterInterval(frame int) (float64, float64) {
+	open := float64(frame) / a.FrameRate
+	return open, open + a.ShutterAngle/360.0/a.FrameRate
+}
+
+// FrameFilename returns the numbered output file for a frame.
+func FrameFilename(prefix string, frame int) string {
+	return fmt.Sprintf("%s_%04d.png", prefix, frame)
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrack_At(t *testing.T) {
+	keys := []Keyframe{
+		{2, Vector3{4, 0, 0}},
+		{0, Vector3{0, 0, 0}},
+		{1, Vector3{2, 2, 0}},
+	}
+	tests := []struct {
+		name          string
+		interpolation Interpolation
+		time          float64
+		want          Vector3
+	}{
+		{"linear before start", InterpolateLinear, -1, Vector3{0, 0, 0}},
+		{"linear after end", InterpolateLinear, 3, Vector3{4, 0, 0}},
+		{"linear on keyframe", InterpolateLinear, 1, Vector3{2, 2, 0}},
+		{"linear between keyframes", InterpolateLinear, 0.5, Vector3{1, 1, 0}},
+		{"linear second segment", InterpolateLinear, 1.5, Vector3{3, 1, 0}},
+		{"spline on keyframe", InterpolateSpline, 1, Vector3{2, 2, 0}},
+		{"spline after end", InterpolateSpline, 3, Vector3{4, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTrack(tt.interpolation, keys...)
+			if got := tr.At(tt.time); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Track.At() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrack_AtSplineIsSmooth(t *testing.T) {
+	tr := NewTrack(InterpolateSpline,
+		Keyframe{0, Vector3{0, 0, 0}},
+		Keyframe{1, Vector3{1, 1, 0}},
+		Keyframe{2, Vector3{2, 0, 0}},
+	)
+	const h = 1e-6
+	before := tr.At(1).Subtract(tr.At(1 - h)).DivideScalar(h)
+	after := tr.At(1 + h).Subtract(tr.At(1)).DivideScalar(h)
+	if before.Subtract(after).Length() > 1e-4 {
+		t.Errorf("spline tangent jumps at keyframe: %v vs %v", before, after)
+	}
+}
+
+func TestAnimation_ShutterInterval(t *testing.T) {
+	a := Animation{FrameRate: 24, ShutterAngle: 180}
+	open, close := a.ShutterInterval(12)
+	if open != 0.5 || close != 0.5+1.0/48 {
+		t.Errorf("Animation.ShutterInterval() = %v, %v, want 0.5, %v", open, close, 0.5+1.0/48)
+	}
+}
+
+func TestMovingSphere_RestsOutsideItsMotion(t *testing.T) {
+	s := NewMovingSphere(Vector3{0, 0, 0}, Vector3{1, 0, 0}, 0, 1, 0.5, nil).(movingSphere)
+	tests := []struct {
+		time float64
+		want Vector3
+	}{
+		{-1, Vector3{0, 0, 0}},
+		{0.5, Vector3{0.5, 0, 0}},
+		{3, Vector3{1, 0, 0}},
+	}
+	for _, tt := range tests {
+		if got := s.center(tt.time); got != tt.want {
+			t.Errorf("center(%v) = %v, want %v", tt.time, got, tt.want)
+		}
+	}
+	hr := s.Hit(NewRay(Vector3{1, 0, 5}, Vector3{0, 0, -1}, 3), 0, 100)
+	if hr == nil || hr.Velocity != (Vector3{}) {
+		t.Errorf("Hit() after the motion = %v, want at rest", hr)
+	}
+}
+
+func TestFrameFilename(t *testing.T) {
+	if got := FrameFilename("out", 1); got != "out_0001.png" {
+		t.Errorf("FrameFilename() = %v, want out_0001.png", got)
+	}
+}
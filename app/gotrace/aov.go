@@ -0,0 +1,282 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// AOV is an arbitrary output variable: a pass written alongside the
+// image, describing what each pixel first saw, for use in compositing
+// and denoising.
+type AOV int
+
+// Supported AOVs.
+const (
+	// AOVDepth is the distance along the camera ray to the first hit.
+	AOVDepth AOV = iota
+	// AOVNormal is the world space normal, facing the camera.
+	AOVNormal
+	// AOVAlbedo is the base color of the material.
+	AOVAlbedo
+	// AOVObjectID identifies the object hit, counting from 1.
+	AOVObjectID
+	// AOVMaterialID identifies the material hit, counting from 1.
+	AOVMaterialID
+	// AOVUV holds the texture coordinates of the hit.
+	AOVUV
+	// AOVMotion is how far the hit moves across the image while the
+	// shutter is open, in pixels, with X to the right and Y up.
+	AOVMotion
+	numAOVs
+)
+
+var aovNames = [numAOVs]string{"depth", "normal", "albedo", "objectid", "materialid", "uv", "motion"}
+
+// aovChannels names the channels each AOV is written as.
+var aovChannels = [numAOVs][]string{
+	{"Z"},
+	{"X", "Y", "Z"},
+	{"R", "G", "B"},
+	{"id"},
+	{"id"},
+	{"U", "V"},
+	{"X", "Y"},
+}
+
+func (a AOV) String() string {
+	return aovNames[a]
+}
+
+// isID is true for AOVs which hold identifiers, which must not be
+// averaged.
+func (a AOV) isID() bool {
+	return a == AOVObjectID || a == AOVMaterialID
+}
+
+// ParseAOVs parses a comma separated list of AOV names.
+func ParseAOVs(list string) ([]AOV, error) {
+	ret := []AOV{}
+	if list == "" {
+		return ret, nil
+	}
+outer:
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		for a, n := range aovNames {
+			if n == name {
+				ret = append(ret, AOV(a))
+				continue outer
+			}
+		}
+		return nil, fmt.Errorf("unknown AOV %q", name)
+	}
+	return ret, nil
+}
+
+// addAOVs returns list with any of more which it lacks added to the end.
+func addAOVs(list []AOV, more ...AOV) []AOV {
+	ret := append([]AOV{}, list...)
+outer:
+	for _, a := range more {
+		for _, have := range ret {
+			if a == have {
+				continue outer
+			}
+		}
+		ret = append(ret, a)
+	}
+	return ret
+}
+
+// aovSample holds the value of every AOV for one camera sample.
+type aovSample [numAOVs]Vector3
+
+// firstHitAOVs returns the AOVs for a camera ray which first hit hr.
+// width and height are the image size, which motion vectors are
+// measured in.
+func firstHitAOVs(cam Camera, r Ray, hr *HitRecord, width int, height int) aovSample {
+	var ret aovSample
+	ret[AOVDepth] = Vector3{X: hr.T * r.Direction.Length()}
+	ret[AOVNormal] = hr.Normal
+	if m, ok := hr.Material.(AlbedoMaterial); ok {
+		ret[AOVAlbedo] = m.Albedo(hr)
+	}
+	ret[AOVObjectID] = Vector3{X: float64(hr.ObjectID)}
+	ret[AOVMaterialID] = Vector3{X: float64(MaterialID(hr.Material))}
+	ret[AOVUV] = Vector3{X: hr.U, Y: hr.V}
+
+	if p, ok := cam.(Projector); ok && !NearZeroVector(hr.Velocity) {
+		time0, time1 := p.Shutter()
+		open := hr.P.Add(hr.Velocity.MultiplyScalar(time0 - r.Time))
+		close := hr.P.Add(hr.Velocity.MultiplyScalar(time1 - r.Time))
+		ok0, s0, t0 := p.Project(open)
+		ok1, s1, t1 := p.Project(close)
+		if ok0 && ok1 {
+			ds := s1 - s0
+			// Panoramas wrap around, so take the short way.
+			if ds > 0.5 {
+				ds--
+			} else if ds < -0.5 {
+				ds++
+			}
+			ret[AOVMotion] = Vector3{X: ds * float64(width), Y: (t1 - t0) * float64(height)}
+		}
+	}
+	return ret
+}
+
+// AOVFilm collects AOVs for a rectangle of the image.  Most AOVs are
+// averaged over the samples of each pixel which hit something.  IDs
+// cannot be averaged, so they come from the first sample of each pixel.
+type AOVFilm struct {
+	Bounds image.Rectangle
+	AOVs   []AOV
+	values [numAOVs][]Vector3
+	hits   []int
+}
+
+// NewAOVFilm returns an empty AOV film covering bounds, in raster space.
+func NewAOVFilm(bounds image.Rectangle, aovs []AOV) *AOVFilm {
+	n := bounds.Dx() * bounds.Dy()
+	ret := &AOVFilm{
+		Bounds: bounds,
+		AOVs:   aovs,
+		hits:   make([]int, n),
+	}
+	for _, a := range aovs {
+		ret.values[a] = make([]Vector3, n)
+	}
+	return ret
+}
+
+func (f *AOVFilm) offset(x int, y int) int {
+	return (y-f.Bounds.Min.Y)*f.Bounds.Dx() + (x - f.Bounds.Min.X)
+}
+
+// AddSample records the AOVs of a sample of pixel x, y which hit
+// something.  IDs are taken from the first such sample.
+func (f *AOVFilm) AddSample(x int, y int, s *aovSample) {
+	p := f.offset(x, y)
+	f.hits[p]++
+	for _, a := range f.AOVs {
+		if a.isID() {
+			if f.hits[p] == 1 {
+				f.values[a][p] = s[a]
+			}
+			continue
+		}
+		f.values[a][p] = f.values[a][p].Add(s[a])
+	}
+}
+
+// Merge adds the samples collected by another film into this one.
+func (f *AOVFilm) Merge(o *AOVFilm) {
+	area := o.Bounds.Intersect(f.Bounds)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			p, op := f.offset(x, y), o.offset(x, y)
+			first := f.hits[p] == 0
+			f.hits[p] += o.hits[op]
+			for _, a := range f.AOVs {
+				if a.isID() {
+					if first {
+						f.values[a][p] = o.values[a][op]
+					}
+					continue
+				}
+				f.values[a][p] = f.values[a][p].Add(o.values[a][op])
+			}
+		}
+	}
+}
+
+// Value returns an AOV for a pixel.  Where nothing was hit, depth is
+// infinite and everything else is zero.
+func (f *AOVFilm) Value(a AOV, x int, y int) Vector3 {
+	p := f.offset(x, y)
+	if f.hits[p] == 0 {
+		if a == AOVDepth {
+			return Vector3{X: math.Inf(1)}
+		}
+		return Vector3{}
+	}
+	if a.isID() {
+		return f.values[a][p]
+	}
+	return f.values[a][p].DivideScalar(float64(f.hits[p]))
+}
+
+// Image returns a picture of an AOV, scaled to be easy to look at.
+// Depth and motion are scaled by the largest value in the image, IDs
+// are given random colors, and normals are mapped from -1..1 to 0..1.
+func (f *AOVFilm) Image(a AOV) *image.NRGBA {
+	largest := 0.0
+	for y := f.Bounds.Min.Y; y < f.Bounds.Max.Y; y++ {
+		for x := f.Bounds.Min.X; x < f.Bounds.Max.X; x++ {
+			v := f.Value(a, x, y)
+			if a == AOVDepth && !math.IsInf(v.X, 1) {
+				largest = math.Max(largest, v.X)
+			}
+			if a == AOVMotion {
+				largest = math.Max(largest, math.Max(math.Abs(v.X), math.Abs(v.Y)))
+			}
+		}
+	}
+	if largest == 0 {
+		largest = 1
+	}
+
+	im := image.NewNRGBA(image.Rect(0, 0, f.Bounds.Dx(), f.Bounds.Dy()))
+	for y := f.Bounds.Min.Y; y < f.Bounds.Max.Y; y++ {
+		for x := f.Bounds.Min.X; x < f.Bounds.Max.X; x++ {
+			v := f.Value(a, x, y)
+			var c Vector3
+			switch a {
+			case AOVDepth:
+				d := 1.0
+				if !math.IsInf(v.X, 1) {
+					d = v.X / largest
+				}
+				c = Vector3{d, d, d}
+			case AOVNormal:
+				c = v.AddScalar(1).MultiplyScalar(0.5)
+			case AOVAlbedo:
+				c = v.Gamma2()
+			case AOVObjectID, AOVMaterialID:
+				if v.X != 0 {
+					h := hashInts(int(v.X))
+					c = Vector3{float64(h & 0xff), float64(h >> 8 & 0xff), float64(h >> 16 & 0xff)}.DivideScalar(255)
+				}
+			case AOVUV:
+				c = v
+			case AOVMotion:
+				c = Vector3{v.X/largest*0.5 + 0.5, v.Y/largest*0.5 + 0.5, 0.5}
+			}
+			c = c.Clamp(0, 0.999).MultiplyScalar(256)
+			o := (y-f.Bounds.Min.Y)*im.Stride + (x-f.Bounds.Min.X)*4
+			im.Pix[o] = uint8(c.X)
+			im.Pix[o+1] = uint8(c.Y)
+			im.Pix[o+2] = uint8(c.Z)
+			im.Pix[o+3] = 0xff
+		}
+	}
+	return im
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseAOVs(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []AOV
+		wantErr bool
+	}{
+		{"empty", "", []AOV{}, false},
+		{"one", "depth", []AOV{AOVDepth}, false},
+		{"several", "normal, motion,objectid", []AOV{AOVNormal, AOVMotion, AOVObjectID}, false},
+		{"unknown", "depth,colour", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAOVs(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAOVs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAOVs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAOVFilm_Value(t *testing.T) {
+	film := NewAOVFilm(image.Rect(0, 0, 2, 1), []AOV{AOVDepth, AOVObjectID})
+	film.AddSample(0, 0, &aovSample{AOVDepth: {X: 2}, AOVObjectID: {X: 3}})
+	film.AddSample(0, 0, &aovSample{AOVDepth: {X: 4}, AOVObjectID: {X: 5}})
+
+	if got := film.Value(AOVDepth, 0, 0); got != (Vector3{X: 3}) {
+		t.Errorf("depth = %v, want the average of the hits", got)
+	}
+	if got := film.Value(AOVObjectID, 0, 0); got != (Vector3{X: 3}) {
+		t.Errorf("object ID = %v, want the first sample's", got)
+	}
+	if got := film.Value(AOVDepth, 1, 0); !math.IsInf(got.X, 1) {
+		t.Errorf("depth of a miss = %v, want infinity", got)
+	}
+}
+
+func TestAOVFilm_MergeKeepsFirstID(t *testing.T) {
+	film := NewAOVFilm(image.Rect(0, 0, 2, 1), []AOV{AOVObjectID})
+	film.AddSample(0, 0, &aovSample{AOVObjectID: {X: 3}})
+	other := NewAOVFilm(image.Rect(0, 0, 2, 1), []AOV{AOVObjectID})
+	other.AddSample(0, 0, &aovSample{AOVObjectID: {X: 5}})
+	other.AddSample(1, 0, &aovSample{AOVObjectID: {X: 7}})
+	film.Merge(other)
+	if got := film.Value(AOVObjectID, 0, 0); got != (Vector3{X: 3}) {
+		t.Errorf("object ID = %v, want the first film's", got)
+	}
+	if got := film.Value(AOVObjectID, 1, 0); got != (Vector3{X: 7}) {
+		t.Errorf("object ID = %v, want the only hit's", got)
+	}
+}
+
+// paletteTexture is a texture which is not hashable.
+type paletteTexture []Vector3
+
+func (t paletteTexture) Value(u float64, v float64, p Vector3) Vector3 {
+	return t[0]
+}
+
+func TestMaterialID_UnhashableFields(t *testing.T) {
+	red := NewLambertianMaterial(Vector3{1, 0, 0})
+	a := NewMixMaterial(red, red, paletteTexture{{0.5, 0.5, 0.5}})
+	b := NewMixMaterial(red, red, paletteTexture{{0.2, 0.2, 0.2}})
+	if MaterialID(a) == 0 || MaterialID(a) != MaterialID(b) {
+		t.Errorf("MaterialID() = %v, %v, want the same ID for the type", MaterialID(a), MaterialID(b))
+	}
+	c := NewMixMaterial(red, red, NewSolidValue(0.5))
+	d := NewMixMaterial(red, red, NewSolidValue(0.2))
+	if MaterialID(c) == MaterialID(d) {
+		t.Errorf("MaterialID() = %v for different materials", MaterialID(c))
+	}
+}
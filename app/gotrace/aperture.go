@@ -0,0 +1,150 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	_ "image/jpeg" // register decoders for image apertures
+	_ "image/png"
+	"math"
+	"os"
+	"sort"
+)
+
+// ApertureShape defines the shape of the lens opening, which is what
+// gives out-of-focus highlights (bokeh) their shape.  Sample maps two
+// uniform numbers in [0, 1) to a point on the aperture, within the unit
+// square centered on the lens axis.
+type ApertureShape interface {
+	Sample(u float64, v float64) (float64, float64)
+}
+
+// CircularAperture is the usual round lens opening.
+type CircularAperture struct{}
+
+// Sample returns a uniformly distributed point within the unit disk.
+func (a CircularAperture) Sample(u float64, v float64) (float64, float64) {
+	p := SampleUnitDisk(u, v)
+	return p.X, p.Y
+}
+
+// PolygonalAperture is the opening formed by a diaphragm with straight
+// blades, such as the hexagon from a six-bladed lens.
+type PolygonalAperture struct {
+	Blades   int
+	Rotation float64
+}
+
+// NewPolygonalAperture returns an aperture with the given number of
+// blades, rotated by the given number of degrees.
+func NewPolygonalAperture(blades int, rotation float64) PolygonalAperture {
+	if blades < 3 {
+		blades = 3
+	}
+	return PolygonalAperture{Blades: blades, Rotation: rotation * math.Pi / 180}
+}
+
+// Sample returns a uniformly distributed point within the polygon.
+func (a PolygonalAperture) Sample(u float64, v float64) (float64, float64) {
+	// Every triangle formed by the center and one edge has the same
+	// area, so pick one, then pick a point within it.
+	u *= float64(a.Blades)
+	edge := math.Min(math.Floor(u), float64(a.Blades-1))
+	u -= edge
+	step := 2 * math.Pi / float64(a.Blades)
+	s0, c0 := math.Sincos(a.Rotation + step*edge)
+	s1, c1 := math.Sincos(a.Rotation + step*(edge+1))
+	if u+v > 1 {
+		u, v = 1-u, 1-v
+	}
+	return c0*u + c1*v, s0*u + s1*v
+}
+
+// ImageAperture uses the brightness of an image as the shape of the
+// lens opening, so bokeh can take any shape.
+type ImageAperture struct {
+	width  int
+	height int
+	scale  float64
+	cdf    []float64
+}
+
+// NewImageAperture returns an aperture shaped like the bright parts of
+// the image.  The longer side of the image spans the aperture.
+func NewImageAperture(im image.Image) ImageAperture {
+	b := im.Bounds()
+	ret := ImageAperture{
+		width:  b.Dx(),
+		height: b.Dy(),
+		scale:  2 / math.Max(float64(b.Dx()), float64(b.Dy())),
+		cdf:    make([]float64, 0, b.Dx()*b.Dy()),
+	}
+	total := 0.0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, _ := im.At(x, y).RGBA()
+			total += 0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)
+			ret.cdf = append(ret.cdf, total)
+		}
+	}
+	if total == 0 {
+		// A black image would let no light in at all, which is never
+		// what was meant.  Treat it as fully open instead.
+		for i := range ret.cdf {
+			ret.cdf[i] = float64(i + 1)
+		}
+	}
+	return ret
+}
+
+// LoadImageAperture reads an image file for use as an aperture.
+func LoadImageAperture(filename string) (ImageAperture, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return ImageAperture{}, err
+	}
+	defer f.Close()
+	im, _, err := image.Decode(f)
+	if err != nil {
+		return ImageAperture{}, err
+	}
+	return NewImageAperture(im), nil
+}
+
+// Sample picks a pixel with probability proportional to its brightness,
+// and returns a point within it.
+func (a ImageAperture) Sample(u float64, v float64) (float64, float64) {
+	target := u * a.cdf[len(a.cdf)-1]
+	i := sort.SearchFloat64s(a.cdf, target)
+	if i >= len(a.cdf) {
+		i = len(a.cdf) - 1
+	}
+	// Reuse where u fell within the pixel's share to place the point
+	// across the pixel.
+	low := 0.0
+	if i > 0 {
+		low = a.cdf[i-1]
+	}
+	across := 0.5
+	if a.cdf[i] > low {
+		across = (target - low) / (a.cdf[i] - low)
+	}
+	px := float64(i%a.width) + across
+	py := float64(i/a.width) + v
+	// Image rows run downwards, the lens v axis runs upwards.
+	return (px - float64(a.width)/2) * a.scale, (float64(a.height)/2 - py) * a.scale
+}
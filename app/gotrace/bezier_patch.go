@@ -0,0 +1,295 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Every patch is split at least this many times, so the flatness
+	// test cannot be fooled by a wave which passes through all of its
+	// sample points.
+	minPatchDepth = 2
+	// Nor is a patch split more than this many times, whatever the
+	// tolerance, bounding it to 2 * 4^8 triangles.
+	maxPatchDepth = 8
+)
+
+// patchNode covers part of a patch.  Leaves hold the triangles it was
+// tessellated into.
+type patchNode struct {
+	Box       aabb
+	Children  []int
+	Triangles []triangle
+}
+
+type bezierPatch struct {
+	Control  [16]Vector3
+	Nodes    []patchNode
+	Material Material
+}
+
+// NewBezierPatch returns a bicubic Bézier patch, with control points
+// given a row of constant V at a time.  It is tessellated into
+// triangles, split more finely where it curves, until each piece is
+// within tolerance of the true surface.  Normals are taken from the
+// true surface, and U and V are the patch's own parameters.
+func NewBezierPatch(control [16]Vector3, tolerance float64, mat Material) Hittable {
+	p := &bezierPatch{Control: control, Material: mat}
+	p.build(0, 1, 0, 1, 0, tolerance)
+	return p
+}
+
+// bernstein returns the cubic Bernstein weights at t, and their
+// derivatives.
+func bernstein(t float64) ([4]float64, [4]float64) {
+	s := 1 - t
+	return [4]float64{s * s * s, 3 * t * s * s, 3 * t * t * s, t * t * t},
+		[4]float64{-3 * s * s, 3*s*s - 6*t*s, 6*t*s - 3*t*t, 3 * t * t}
+}
+
+// evaluate returns the point at u, v, and the derivatives along U
+// and V.
+func (p *bezierPatch) evaluate(u float64, v float64) (Vector3, Vector3, Vector3) {
+	bu, du := bernstein(u)
+	bv, dv := bernstein(v)
+	var pt, dpdu, dpdv Vector3
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			c := p.Control[j*4+i]
+			pt = pt.Add(c.MultiplyScalar(bu[i] * bv[j]))
+			dpdu = dpdu.Add(c.MultiplyScalar(du[i] * bv[j]))
+			dpdv = dpdv.Add(c.MultiplyScalar(bu[i] * dv[j]))
+		}
+	}
+	return pt, dpdu, dpdv
+}
+
+// normal returns the surface normal at u, v.  Where the patch pinches
+// to a point, as at the top of a teapot lid, the derivatives vanish,
+// and the normal is taken from just inside the patch instead.
+func (p *bezierPatch) normal(u float64, v float64) Vector3 {
+	for step := 0; step < 4; step++ {
+		_, dpdu, dpdv := p.evaluate(u, v)
+		if n := dpdu.Cross(dpdv); n.LengthSquared() > 1e-20 {
+			return n.Normalize()
+		}
+		u += (0.5 - u) * 1e-3
+		v += (0.5 - v) * 1e-3
+	}
+	return Vector3{0, 1, 0}
+}
+
+// vertex returns the tessellated corner at u, v.
+func (p *bezierPatch) vertex(u float64, v float64) (Vector3, Vector3, [2]float64) {
+	pt, _, _ := p.evaluate(u, v)
+	return pt, p.normal(u, v), [2]float64{u, v}
+}
+
+// build adds the node for the part of the patch from u0, v0 to u1, v1,
+// and returns its index.
+func (p *bezierPatch) build(u0 float64, u1 float64, v0 float64, v1 float64, depth int, tolerance float64) int {
+	index := len(p.Nodes)
+	p.Nodes = append(p.Nodes, patchNode{})
+	if depth >= maxPatchDepth || (depth >= minPatchDepth && p.flat(u0, u1, v0, v1, tolerance)) {
+		p00, n00, uv00 := p.vertex(u0, v0)
+		p10, n10, uv10 := p.vertex(u1, v0)
+		p01, n01, uv01 := p.vertex(u0, v1)
+		p11, n11, uv11 := p.vertex(u1, v1)
+		tris := []triangle{
+			{[3]Vector3{p00, p10, p11}, [3]Vector3{n00, n10, n11}, [3][2]float64{uv00, uv10, uv11}, p.Material},
+			{[3]Vector3{p00, p11, p01}, [3]Vector3{n00, n11, n01}, [3][2]float64{uv00, uv11, uv01}, p.Material},
+		}
+		box, _ := tris[0].BoundingBox(0, 0)
+		other, _ := tris[1].BoundingBox(0, 0)
+		p.Nodes[index] = patchNode{Box: surroundingBox(box, other), Triangles: tris}
+		return index
+	}
+
+	um, vm := (u0+u1)/2, (v0+v1)/2
+	children := []int{
+		p.build(u0, um, v0, vm, depth+1, tolerance),
+		p.build(um, u1, v0, vm, depth+1, tolerance),
+		p.build(u0, um, vm, v1, depth+1, tolerance),
+		p.build(um, u1, vm, v1, depth+1, tolerance),
+	}
+	box := p.Nodes[children[0]].Box
+	for _, child := range children[1:] {
+		box = surroundingBox(box, p.Nodes[child].Box)
+	}
+	p.Nodes[index] = patchNode{Box: box, Children: children}
+	return index
+}
+
+// flat reports whether the part of the patch from u0, v0 to u1, v1
+// stays within tolerance of the bilinear surface through its corners.
+func (p *bezierPatch) flat(u0 float64, u1 float64, v0 float64, v1 float64, tolerance float64) bool {
+	p00, _, _ := p.evaluate(u0, v0)
+	p10, _, _ := p.evaluate(u1, v0)
+	p01, _, _ := p.evaluate(u0, v1)
+	p11, _, _ := p.evaluate(u1, v1)
+	for j := 0; j <= 4; j++ {
+		for i := 0; i <= 4; i++ {
+			s, t := float64(i)/4, float64(j)/4
+			pt, _, _ := p.evaluate(u0+(u1-u0)*s, v0+(v1-v0)*t)
+			bilinear := p00.Lerp(p10, s).Lerp(p01.Lerp(p11, s), t)
+			if pt.Subtract(bilinear).Length() > tolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *bezierPatch) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return p.hitNode(0, r, tMin, tMax)
+}
+
+// hitNode walks down the tessellation, visiting children nearest first
+// so the search can stop at the first hit.
+func (p *bezierPatch) hitNode(index int, r Ray, tMin float64, tMax float64) *HitRecord {
+	n := p.Nodes[index]
+	var closest *HitRecord
+	for _, tr := range n.Triangles {
+		if hr := tr.Hit(r, tMin, tMax); hr != nil {
+			closest = hr
+			tMax = hr.T
+		}
+	}
+
+	type entry struct {
+		index int
+		t     float64
+	}
+	order := make([]entry, 0, len(n.Children))
+	for _, child := range n.Children {
+		if t, _, ok := p.Nodes[child].Box.hitRange(r, tMin, tMax); ok {
+			order = append(order, entry{child, t})
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].t < order[j].t })
+	for _, e := range order {
+		if e.t > tMax {
+			break
+		}
+		if hr := p.hitNode(e.index, r, tMin, tMax); hr != nil {
+			closest = hr
+			tMax = hr.T
+		}
+	}
+	return closest
+}
+
+func (p *bezierPatch) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return p.Nodes[0].Box, true
+}
+
+// ReadBezierPatches reads patches in the format of Newell's teapot: the
+// number of patches, then sixteen vertex numbers for each, counting
+// from one, then the number of vertices and their coordinates.
+// Numbers may be separated by commas or white space.
+func ReadBezierPatches(in io.Reader, tolerance float64, mat Material) ([]Hittable, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.FieldsFunc(string(data), func(c rune) bool {
+		return c == ',' || unicode.IsSpace(c)
+	})
+	next := func() (float64, error) {
+		if len(fields) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		fields = fields[1:]
+		return v, err
+	}
+	// nextCount reads a count or vertex number, which must be a whole
+	// number.  A count of things of size numbers each may not be more
+	// than the numbers left, which keeps a bad file from asking for
+	// more memory than its own size.
+	nextCount := func(size int) (int, error) {
+		v, err := next()
+		if err != nil {
+			return 0, err
+		}
+		if v < 0 || v != math.Trunc(v) || (size > 0 && v > float64(len(fields)/size)) {
+			return 0, fmt.Errorf("bad count %v", v)
+		}
+		return int(v), nil
+	}
+
+	count, err := nextCount(16)
+	if err != nil {
+		return nil, fmt.Errorf("reading patch count: %w", err)
+	}
+	indexes := make([][16]int, count)
+	for i := range indexes {
+		for j := range indexes[i] {
+			v, err := nextCount(0)
+			if err != nil {
+				return nil, fmt.Errorf("reading patch %d: %w", i+1, err)
+			}
+			indexes[i][j] = v - 1
+		}
+	}
+	count, err = nextCount(3)
+	if err != nil {
+		return nil, fmt.Errorf("reading vertex count: %w", err)
+	}
+	vertices := make([]Vector3, count)
+	for i := range vertices {
+		var xyz [3]float64
+		for j := range xyz {
+			if xyz[j], err = next(); err != nil {
+				return nil, fmt.Errorf("reading vertex %d: %w", i+1, err)
+			}
+		}
+		vertices[i] = Vector3{xyz[0], xyz[1], xyz[2]}
+	}
+
+	ret := make([]Hittable, len(indexes))
+	for i, patch := range indexes {
+		var control [16]Vector3
+		for j, v := range patch {
+			if v < 0 || v >= len(vertices) {
+				return nil, fmt.Errorf("patch %d uses vertex %d of %d", i+1, v+1, len(vertices))
+			}
+			control[j] = vertices[v]
+		}
+		ret[i] = NewBezierPatch(control, tolerance, mat)
+	}
+	return ret, nil
+}
+
+// LoadBezierPatches reads a file of patches.
+func LoadBezierPatches(filename string, tolerance float64, mat Material) ([]Hittable, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBezierPatches(f, tolerance, mat)
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// dome returns a patch over x and z from 0 to 3, raised in the middle.
+func dome() [16]Vector3 {
+	var control [16]Vector3
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			y := 0.0
+			if i == 1 || i == 2 {
+				if j == 1 || j == 2 {
+					y = 2
+				}
+			}
+			control[j*4+i] = Vector3{float64(i), y, float64(j)}
+		}
+	}
+	return control
+}
+
+func TestBezierPatch_Hit(t *testing.T) {
+	const tolerance = 1e-3
+	obj := NewBezierPatch(dome(), tolerance, nil)
+	p := obj.(*bezierPatch)
+	for _, xz := range [][2]float64{{1.5, 1.5}, {0.2, 2.9}, {2.5, 1}} {
+		r := NewRay(Vector3{xz[0], 10, xz[1]}, Vector3{0, -1, 0}, 0)
+		hr := obj.Hit(r, 0.001, math.Inf(1))
+		if hr == nil {
+			t.Fatalf("Hit() at %v missed", xz)
+		}
+		want, _, _ := p.evaluate(hr.U, hr.V)
+		if hr.P.Subtract(want).Length() > 2*tolerance {
+			t.Errorf("Hit() at %v = %v, but the patch at %v, %v is %v", xz, hr.P, hr.U, hr.V, want)
+		}
+		if math.Abs(hr.Normal.Dot(p.normal(hr.U, hr.V))) < 0.999 {
+			t.Errorf("Hit().Normal at %v = %v, want %v", xz, hr.Normal, p.normal(hr.U, hr.V))
+		}
+	}
+	// The top of the dome is 1.5 * 9/16 * 2 above the corners.
+	hr := obj.Hit(NewRay(Vector3{1.5, 10, 1.5}, Vector3{0, -1, 0}, 0), 0.001, math.Inf(1))
+	if math.Abs(hr.P.Y-1.125) > tolerance {
+		t.Errorf("Hit() top = %v, want 1.125", hr.P.Y)
+	}
+	if hr := obj.Hit(NewRay(Vector3{4, 10, 1}, Vector3{0, -1, 0}, 0), 0.001, math.Inf(1)); hr != nil {
+		t.Errorf("Hit() beside the patch = %v, want miss", hr.P)
+	}
+}
+
+func TestReadBezierPatches(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("1\n")
+	for i := 1; i <= 16; i++ {
+		fmt.Fprintf(&b, "%d,", i)
+	}
+	b.WriteString("\n16\n")
+	for _, v := range dome() {
+		fmt.Fprintf(&b, "%g, %g, %g\n", v.X, v.Y, v.Z)
+	}
+	patches, err := ReadBezierPatches(strings.NewReader(b.String()), 0.01, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 {
+		t.Fatalf("ReadBezierPatches() = %d patches, want 1", len(patches))
+	}
+	if got := patches[0].(*bezierPatch).Control; got != dome() {
+		t.Errorf("ReadBezierPatches() control = %v, want %v", got, dome())
+	}
+
+	if _, err := ReadBezierPatches(strings.NewReader("1\n1,2,3"), 0.01, nil); err == nil {
+		t.Error("ReadBezierPatches() of a truncated file succeeded")
+	}
+	if _, err := ReadBezierPatches(strings.NewReader(strings.Replace(b.String(), "16,", "17,", 1)), 0.01, nil); err == nil {
+		t.Error("ReadBezierPatches() with a missing vertex succeeded")
+	}
+}
+
+func TestReadBezierPatches_BadCounts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"negative patches", "-1\n0\n"},
+		{"fractional patches", "0.5\n0\n"},
+		{"huge patches", "1e15\n1,2,3"},
+		{"huge vertices", "0\n1e15\n"},
+		{"fractional vertex", "1\n1.5" + strings.Repeat(",1", 15) + "\n1\n0,0,0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadBezierPatches(strings.NewReader(tt.input), 0.01, nil); err == nil {
+				t.Errorf("ReadBezierPatches(%q) succeeded", tt.input)
+			}
+		})
+	}
+}
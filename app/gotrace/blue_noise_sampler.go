@@ -0,0 +1,186 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+const blueNoiseSize = 64
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoiseMask []float64
+)
+
+// blueNoise returns the blue noise dither mask, making it the first
+// time it is needed.
+func blueNoise() []float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoiseMask = makeBlueNoise(blueNoiseSize, 1.5, 1)
+	})
+	return blueNoiseMask
+}
+
+// makeBlueNoise builds a size by size dither mask with Ulichney's
+// void-and-cluster method.  Every value from 0 to 1 appears once, and
+// any threshold of the mask gives evenly spread points.
+func makeBlueNoise(size int, sigma float64, seed int64) []float64 {
+	n := size * size
+	rng := rand.New(rand.NewSource(seed))
+
+	// kernel holds the Gaussian energy at every toroidal offset.
+	kernel := make([]float64, n)
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			x := float64(min(dx, size-dx))
+			y := float64(min(dy, size-dy))
+			kernel[dy*size+dx] = math.Exp(-(x*x + y*y) / (2 * sigma * sigma))
+		}
+	}
+
+	pattern := make([]bool, n)
+	energy := make([]float64, n)
+	set := func(p int, on bool) {
+		pattern[p] = on
+		sign := 1.0
+		if !on {
+			sign = -1
+		}
+		px, py := p%size, p/size
+		for y := 0; y < size; y++ {
+			row := ((y - py + size) % size) * size
+			for x := 0; x < size; x++ {
+				energy[y*size+x] += sign * kernel[row+(x-px+size)%size]
+			}
+		}
+	}
+	// tightestCluster finds the set point with the most energy, and
+	// largestVoid the unset point with the least.
+	extreme := func(on bool) int {
+		best := -1
+		for p := 0; p < n; p++ {
+			if pattern[p] != on {
+				continue
+			}
+			if best < 0 || (on && energy[p] > energy[best]) || (!on && energy[p] < energy[best]) {
+				best = p
+			}
+		}
+		return best
+	}
+
+	// Start with a random tenth of the points, then move the tightest
+	// cluster to the largest void until that changes nothing.
+	initial := n / 10
+	for _, p := range rng.Perm(n)[:initial] {
+		set(p, true)
+	}
+	for {
+		cluster := extreme(true)
+		set(cluster, false)
+		void := extreme(false)
+		set(void, true)
+		if void == cluster {
+			break
+		}
+	}
+	start := append([]bool(nil), pattern...)
+	startEnergy := append([]float64(nil), energy...)
+
+	rank := make([]int, n)
+	// Rank the initial points by removing the tightest clusters first.
+	for ones := initial; ones > 0; ones-- {
+		cluster := extreme(true)
+		set(cluster, false)
+		rank[cluster] = ones - 1
+	}
+	// Then rank the rest by filling the largest voids.
+	copy(pattern, start)
+	copy(energy, startEnergy)
+	for ones := initial; ones < n; ones++ {
+		void := extreme(false)
+		set(void, true)
+		rank[void] = ones
+	}
+
+	mask := make([]float64, n)
+	for p, r := range rank {
+		mask[p] = (float64(r) + 0.5) / float64(n)
+	}
+	return mask
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// BlueNoiseSampler uses the same scrambled Sobol points for every
+// pixel, shifted by a blue noise dither mask.  Neighboring pixels get
+// very different shifts, so the error which remains is spread as high
+// frequency noise, which looks much less objectionable than clumps.
+type BlueNoiseSampler struct {
+	pixelSample
+	mask []float64
+}
+
+// NewBlueNoiseSampler returns a blue noise sampler.
+func NewBlueNoiseSampler(seed int64) *BlueNoiseSampler {
+	return &BlueNoiseSampler{pixelSample{seed: uint32(seed)}, blueNoise()}
+}
+
+// shift returns the dither for this pixel.  Each dimension reads the
+// mask at a different offset so dimensions do not share a pattern.
+func (s *BlueNoiseSampler) shift(dimension int) float64 {
+	ox := int(hashInts(dimension, 0) % blueNoiseSize)
+	oy := int(hashInts(dimension, 1) % blueNoiseSize)
+	x := (s.x + ox) % blueNoiseSize
+	y := (s.y + oy) % blueNoiseSize
+	return s.mask[y*blueNoiseSize+x]
+}
+
+func wrap(v float64) float64 {
+	if v >= 1 {
+		return v - 1
+	}
+	return v
+}
+
+// Get1D returns the next dimension.
+func (s *BlueNoiseSampler) Get1D() float64 {
+	d := s.next()
+	x, _ := shuffledScrambledSobol2D(uint32(s.index), hashInts(d, int(s.seed)))
+	return wrap(x + s.shift(2*d))
+}
+
+// Get2D returns the next two dimensions.
+func (s *BlueNoiseSampler) Get2D() (float64, float64) {
+	d := s.next()
+	x, y := shuffledScrambledSobol2D(uint32(s.index), hashInts(d, int(s.seed)))
+	return wrap(x + s.shift(2*d)), wrap(y + s.shift(2*d+1))
+}
+
+// Clone returns a new blue noise sampler.  The numbers depend only on
+// the pixel and sample, so the seed is ignored, and every worker agrees.
+func (s *BlueNoiseSampler) Clone(seed int64) Sampler {
+	return &BlueNoiseSampler{pixelSample{seed: s.seed}, s.mask}
+}
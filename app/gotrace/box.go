@@ -0,0 +1,115 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type box struct {
+	Minimum  Vector3
+	Maximum  Vector3
+	Material Material
+}
+
+// NewBox returns an axis aligned box with opposite corners at min and
+// max.  Rotate it with NewTransformedSolid.
+func NewBox(min Vector3, max Vector3, mat Material) Solid {
+	return box{
+		Minimum:  minVector(min, max),
+		Maximum:  maxVector(min, max),
+		Material: mat,
+	}
+}
+
+func axis(v Vector3, a int) float64 {
+	switch a {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+func axisVector(a int, length float64) Vector3 {
+	switch a {
+	case 0:
+		return Vector3{X: length}
+	case 1:
+		return Vector3{Y: length}
+	}
+	return Vector3{Z: length}
+}
+
+// surface returns the hit at t on the face across axis a, on the side
+// given by sign.  U and V run across the face along the next two axes.
+func (b box) surface(r Ray, t float64, a int, sign float64) *HitRecord {
+	p := r.Point(t)
+	size := b.Maximum.Subtract(b.Minimum)
+	rel := p.Subtract(b.Minimum)
+	ua, va := (a+1)%3, (a+2)%3
+	return &HitRecord{
+		T:        t,
+		P:        p,
+		Normal:   axisVector(a, sign),
+		U:        axis(rel, ua) / axis(size, ua),
+		V:        axis(rel, va) / axis(size, va),
+		DPDU:     axisVector(ua, axis(size, ua)),
+		DPDV:     axisVector(va, axis(size, va)),
+		Material: b.Material,
+	}
+}
+
+func (b box) Intervals(r Ray) []Interval {
+	r.countIntersectionTest()
+	tNear, tFar := math.Inf(-1), math.Inf(1)
+	nearAxis, farAxis := 0, 0
+	nearSign, farSign := 0.0, 0.0
+	for a := 0; a < 3; a++ {
+		o, d := axis(r.Origin, a), axis(r.Direction, a)
+		lo, hi := axis(b.Minimum, a), axis(b.Maximum, a)
+		if d == 0 {
+			if o < lo || o > hi {
+				return nil
+			}
+			continue
+		}
+		t0, t1 := (lo-o)/d, (hi-o)/d
+		sign := -1.0
+		if d < 0 {
+			t0, t1 = t1, t0
+			sign = 1
+		}
+		if t0 > tNear {
+			tNear, nearAxis, nearSign = t0, a, sign
+		}
+		if t1 < tFar {
+			tFar, farAxis, farSign = t1, a, -sign
+		}
+	}
+	if tNear > tFar {
+		return nil
+	}
+	return []Interval{{b.surface(r, tNear, nearAxis, nearSign), b.surface(r, tFar, farAxis, farSign)}}
+}
+
+func (b box) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return firstSurface(r, b.Intervals(r), tMin, tMax)
+}
+
+func (b box) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return aabb{b.Minimum, b.Maximum}, true
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// BumpMaterial roughens or patterns another material by tilting its
+// normals as though the surface were raised by a height texture, without
+// moving the surface itself.
+type BumpMaterial struct {
+	base   Material
+	height Texture
+	scale  float64
+}
+
+// NewBumpMaterial returns base, bumped by the first channel of height,
+// which is multiplied by scale to give a distance in scene units.
+func NewBumpMaterial(base Material, height Texture, scale float64) BumpMaterial {
+	return BumpMaterial{base: base, height: height, scale: scale}
+}
+
+// bumpDelta is the step in U and V used to find the slope of the
+// height texture.
+const bumpDelta = 5e-4
+
+// normal returns the bumped normal at the hit, on the same side as
+// the hit's normal.
+func (m BumpMaterial) normal(hr *HitRecord) Vector3 {
+	outward := hr.outwardNormal()
+	dpdu, dpdv := hr.Tangents()
+	h := m.height.Value(hr.U, hr.V, hr.P).X
+	hu := m.height.Value(hr.U+bumpDelta, hr.V, hr.P.Add(dpdu.MultiplyScalar(bumpDelta))).X
+	hv := m.height.Value(hr.U, hr.V+bumpDelta, hr.P.Add(dpdv.MultiplyScalar(bumpDelta))).X
+	dpdu = dpdu.Add(outward.MultiplyScalar(m.scale * (hu - h) / bumpDelta))
+	dpdv = dpdv.Add(outward.MultiplyScalar(m.scale * (hv - h) / bumpDelta))
+	n := dpdu.Cross(dpdv).Normalize()
+	if n.Dot(outward) < 0 {
+		n = n.Neg()
+	}
+	if !hr.FrontFace {
+		n = n.Neg()
+	}
+	return n
+}
+
+// Scatter scatters off the base with the bumped normal.
+func (m BumpMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	return m.base.Scatter(r, hr.withShadingNormal(r, m.normal(hr)), smp)
+}
+
+// Albedo returns the base's color.
+func (m BumpMaterial) Albedo(hr *HitRecord) Vector3 {
+	if am, ok := m.base.(AlbedoMaterial); ok {
+		return am.Albedo(hr)
+	}
+	return Vector3{}
+}
+
+// OneSided returns true if the base is one-sided.
+func (m BumpMaterial) OneSided() bool {
+	return oneSided(m.base)
+}
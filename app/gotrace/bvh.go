@@ -0,0 +1,148 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "sort"
+
+type bvhNode struct {
+	Left  Hittable
+	Right Hittable
+	Box   aabb
+}
+
+// hittableList is a group of objects tested one by one.
+type hittableList []Hittable
+
+// NewBVH returns a bounding volume hierarchy over the objects, so a
+// ray is only tested against the few objects near its path, rather
+// than all of them.  This is how to render many small objects, such
+// as thousands of hairs.  The boxes are taken over time0 to time1,
+// which should be the camera's shutter interval.  Any objects without
+// a bounding box are tested against every ray.
+func NewBVH(objects []Hittable, time0 float64, time1 float64) Hittable {
+	var bounded []Hittable
+	var boxes []aabb
+	var unbounded hittableList
+	for _, obj := range objects {
+		if box, ok := obj.BoundingBox(time0, time1); ok {
+			bounded = append(bounded, obj)
+			boxes = append(boxes, box)
+		} else {
+			unbounded = append(unbounded, obj)
+		}
+	}
+	if len(bounded) == 0 {
+		return unbounded
+	}
+	root := buildBVH(bounded, boxes)
+	if len(unbounded) == 0 {
+		return root
+	}
+	return append(unbounded, root)
+}
+
+// buildBVH splits the objects in half along the axis their centers
+// are most spread out on, and builds a node for each half.
+func buildBVH(objects []Hittable, boxes []aabb) Hittable {
+	if len(objects) == 1 {
+		return objects[0]
+	}
+
+	centers := make([]Vector3, len(boxes))
+	lo, hi := boxes[0].minimum, boxes[0].minimum
+	for i, b := range boxes {
+		centers[i] = b.minimum.Add(b.maximum).MultiplyScalar(0.5)
+		lo, hi = minVector(lo, centers[i]), maxVector(hi, centers[i])
+	}
+	spread := hi.Subtract(lo)
+	a := 0
+	if spread.Y > spread.X {
+		a = 1
+	}
+	if spread.Z > axis(spread, a) {
+		a = 2
+	}
+
+	order := make([]int, len(objects))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return axis(centers[order[i]], a) < axis(centers[order[j]], a)
+	})
+	sortedObjects := make([]Hittable, len(objects))
+	sortedBoxes := make([]aabb, len(boxes))
+	for i, o := range order {
+		sortedObjects[i], sortedBoxes[i] = objects[o], boxes[o]
+	}
+
+	mid := len(objects) / 2
+	box := sortedBoxes[0]
+	for _, b := range sortedBoxes[1:] {
+		box = surroundingBox(box, b)
+	}
+	return bvhNode{
+		Left:  buildBVH(sortedObjects[:mid], sortedBoxes[:mid]),
+		Right: buildBVH(sortedObjects[mid:], sortedBoxes[mid:]),
+		Box:   box,
+	}
+}
+
+func (n bvhNode) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	if _, _, ok := n.Box.hitRange(r, tMin, tMax); !ok {
+		return nil
+	}
+	closest := n.Left.Hit(r, tMin, tMax)
+	if closest != nil {
+		tMax = closest.T
+	}
+	if hr := n.Right.Hit(r, tMin, tMax); hr != nil {
+		closest = hr
+	}
+	return closest
+}
+
+func (n bvhNode) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return n.Box, true
+}
+
+func (l hittableList) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	var closest *HitRecord
+	for _, obj := range l {
+		if hr := obj.Hit(r, tMin, tMax); hr != nil {
+			closest = hr
+			tMax = hr.T
+		}
+	}
+	return closest
+}
+
+func (l hittableList) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	var ret aabb
+	for i, obj := range l {
+		box, ok := obj.BoundingBox(time0, time1)
+		if !ok {
+			return aabb{}, false
+		}
+		if i == 0 {
+			ret = box
+		} else {
+			ret = surroundingBox(ret, box)
+		}
+	}
+	return ret, len(l) > 0
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBVH_MatchesList(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() Vector3 {
+		return Vector3{rng.Float64()*10 - 5, rng.Float64()*10 - 5, rng.Float64()*10 - 5}
+	}
+	var objects []Hittable
+	for i := 0; i < 200; i++ {
+		objects = append(objects, NewSphere(random(), rng.Float64()*0.5, nil))
+		objects = append(objects, NewCurve([4]Vector3{random(), random(), random(), random()}, 0.05, 0.1, CurveTube, nil))
+	}
+	list := hittableList(objects)
+	bvh := NewBVH(objects, 0, 1)
+	if _, ok := bvh.(bvhNode); !ok {
+		t.Fatalf("NewBVH() = %T, want a bvhNode", bvh)
+	}
+	for i := 0; i < 500; i++ {
+		r := NewRay(random().MultiplyScalar(2), RandomVector(), 0)
+		want := list.Hit(r, 0.001, math.Inf(1))
+		got := bvh.Hit(r, 0.001, math.Inf(1))
+		switch {
+		case (got == nil) != (want == nil):
+			t.Fatalf("ray %d: Hit() = %v, want %v", i, got, want)
+		case got != nil && got.T != want.T:
+			t.Errorf("ray %d: Hit().T = %v, want %v", i, got.T, want.T)
+		}
+	}
+}
+
+// unboundedPlane is the plane y = 0, which has no bounding box.
+type unboundedPlane struct{}
+
+func (unboundedPlane) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	t := -r.Origin.Y / r.Direction.Y
+	if t < tMin || t > tMax {
+		return nil
+	}
+	return &HitRecord{T: t, P: r.Point(t)}
+}
+
+func (unboundedPlane) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return aabb{}, false
+}
+
+func TestBVH_Unbounded(t *testing.T) {
+	bvh := NewBVH([]Hittable{NewSphere(Vector3{0, 2, 0}, 1, nil), unboundedPlane{}, NewSphere(Vector3{5, 2, 0}, 1, nil)}, 0, 1)
+	if _, ok := bvh.BoundingBox(0, 1); ok {
+		t.Error("BoundingBox() of an unbounded BVH succeeded")
+	}
+	down := func(x float64) float64 {
+		hr := bvh.Hit(NewRay(Vector3{x, 10, 0}, Vector3{0, -1, 0}, 0), 0.001, math.Inf(1))
+		if hr == nil {
+			return -1
+		}
+		return hr.T
+	}
+	if got := down(0); got != 7 {
+		t.Errorf("Hit() on the sphere = %v, want 7", got)
+	}
+	if got := down(2.5); got != 10 {
+		t.Errorf("Hit() on the plane = %v, want 10", got)
+	}
+}
+
+// hairWorld returns a world of many curves, like a patch of fur.
+func hairWorld(n int) World {
+	rng := rand.New(rand.NewSource(1))
+	var objects []Hittable
+	for i := 0; i < n; i++ {
+		root := Vector3{rng.Float64()*4 - 2, 0, rng.Float64()*4 - 2}
+		lean := Vector3{rng.Float64() - 0.5, 0, rng.Float64() - 0.5}
+		points := []Vector3{root}
+		for j := 1; j <= 4; j++ {
+			points = append(points, root.Add(lean.MultiplyScalar(float64(j*j)*0.05)).Add(Vector3{0, float64(j) * 0.3, 0}))
+		}
+		objects = append(objects, NewBSpline(points, 0.01, 0.002, CurveRibbon, nil)...)
+	}
+	objects = append(objects, NewSphere(Vector3{0, -100, 0}, 100, nil))
+	return World{Objects: objects, MaxDepth: 10, TMin: 0.001, TMax: math.Inf(1)}
+}
+
+func TestWorld_WithBVH(t *testing.T) {
+	linear := hairWorld(500)
+	accelerated := linear.WithBVH(0, 1)
+	rng := rand.New(rand.NewSource(2))
+	hits := 0
+	for i := 0; i < 2000; i++ {
+		target := Vector3{rng.Float64()*4 - 2, rng.Float64(), rng.Float64()*4 - 2}
+		r := NewRay(Vector3{0, 2, 6}, target.Subtract(Vector3{0, 2, 6}), 0)
+		want := linear.Hit(r)
+		got := accelerated.Hit(r)
+		switch {
+		case (got == nil) != (want == nil):
+			t.Fatalf("ray %d: Hit() = %v, want %v", i, got, want)
+		case got != nil && (got.T != want.T || got.ObjectID != want.ObjectID):
+			t.Errorf("ray %d: Hit() = %v on %d, want %v on %d", i, got.T, got.ObjectID, want.T, want.ObjectID)
+		case got != nil && got.ObjectID != len(linear.Objects):
+			hits++
+		}
+	}
+	if hits == 0 {
+		t.Error("no ray hit a curve")
+	}
+}
+
+func BenchmarkWorld_HitCurves(b *testing.B) {
+	w := hairWorld(5000).WithBVH(0, 1)
+	rng := rand.New(rand.NewSource(2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		target := Vector3{rng.Float64()*4 - 2, rng.Float64(), rng.Float64()*4 - 2}
+		w.Hit(NewRay(Vector3{0, 2, 6}, target.Subtract(Vector3{0, 2, 6}), 0))
+	}
+}
@@ -16,69 +16,236 @@
 
 package main
 
-import (
-	"math"
-	"math/rand"
-)
-
-// Camera defines how we see the world.
-type Camera struct {
-	ViewportHeight  float64
-	ViewportWidth   float64
-	FocalLength     float64
-	LensRadius      float64
-	Time0           float64
-	Time1           float64
-	origin          Vector3
+import "math"
+
+// Camera defines how we see the world.  It turns a point on the image,
+// where s runs from 0 on the left to 1 on the right, and t from 0 at
+// the bottom to 1 at the top, into a ray.  It returns false if there
+// is nothing to see at that point, such as outside the image circle
+// of a fisheye lens.
+type Camera interface {
+	GetRay(s float64, t float64, smp Sampler) (bool, Ray)
+}
+
+// Projector is implemented by cameras which can find where a point in
+// the world appears on the image, as s and t, for motion vectors.  It
+// returns false if the point cannot be seen.
+type Projector interface {
+	Camera
+	Project(p Vector3) (bool, float64, float64)
+	Shutter() (float64, float64)
+}
+
+// CameraMaker builds a camera placed at lookFrom, looking towards
+// lookAt, with a shutter open from time0 to time1.
+type CameraMaker func(lookFrom Vector3, lookAt Vector3, vup Vector3, time0 float64, time1 float64) Camera
+
+// cameraBasis holds where a camera is, which way it is facing, and
+// when its shutter is open.  u points right, v up, and w backwards.
+type cameraBasis struct {
+	Time0   float64
+	Time1   float64
+	origin  Vector3
+	u, v, w Vector3
+}
+
+func newCameraBasis(lookFrom Vector3, lookAt Vector3, vup Vector3, time0 float64, time1 float64) cameraBasis {
+	ret := cameraBasis{
+		Time0:  time0,
+		Time1:  time1,
+		origin: lookFrom,
+	}
+	ret.w = lookFrom.Subtract(lookAt).Normalize()
+	ret.u = vup.Cross(ret.w).Normalize()
+	ret.v = ret.w.Cross(ret.u)
+	return ret
+}
+
+// time returns a time while the shutter is open.
+func (c cameraBasis) time(smp Sampler) float64 {
+	return c.Time0 + smp.Get1D()*(c.Time1-c.Time0)
+}
+
+// Shutter returns when the shutter opens and closes.
+func (c cameraBasis) Shutter() (float64, float64) {
+	return c.Time0, c.Time1
+}
+
+// PerspectiveCamera is a thin-lens camera.
+type PerspectiveCamera struct {
+	cameraBasis
+	ViewportHeight float64
+	ViewportWidth  float64
+	FocusDistance  float64
+	LensRadius     float64
+	Aperture       ApertureShape
+
+	// lowerLeftCorner, horizontal, and vertical describe the image
+	// plane one unit in front of the lens, relative to the origin.
 	lowerLeftCorner Vector3
 	horizontal      Vector3
 	vertical        Vector3
-	u, v, w         Vector3
-}
-
-// NewCamera returns a new Camera with the given aspect ratio.
-// Other things are hard-coded currently.
-func NewCamera(lookFrom Vector3, lookAt Vector3, vup Vector3, fieldOfView float64, aspectRatio float64, aperture float64, focalLength float64, time0 float64, time1 float64) Camera {
-	ret := Camera{
-		FocalLength: focalLength,
-		LensRadius:  aperture / 2,
-		Time0:       time0,
-		Time1:       time1,
-		origin:      lookFrom,
-	}
 
+	// focusNormal is the normal of the plane of focus.  It differs
+	// from w when the lens is tilted.
+	focusNormal Vector3
+}
+
+// NewCamera returns a new PerspectiveCamera with the given vertical field of view,
+// in degrees, and aspect ratio.  Objects at focusDistance from the camera
+// are in perfect focus.
+func NewCamera(lookFrom Vector3, lookAt Vector3, vup Vector3, fieldOfView float64, aspectRatio float64, aperture float64, focusDistance float64, time0 float64, time1 float64) PerspectiveCamera {
 	theta := fieldOfView * math.Pi / 180.0
 	h := math.Tan(theta / 2)
-	ret.ViewportHeight = 2.0 * h
-	ret.ViewportWidth = ret.ViewportHeight * aspectRatio
+	viewportHeight := 2.0 * h
+	return newCamera(lookFrom, lookAt, vup, viewportHeight*aspectRatio, viewportHeight, aperture/2, focusDistance, time0, time1)
+}
 
-	ret.w = lookFrom.Subtract(lookAt).Normalize()
-	ret.u = vup.Cross(ret.w).Normalize()
-	ret.v = ret.w.Cross(ret.u)
+func newCamera(lookFrom Vector3, lookAt Vector3, vup Vector3, viewportWidth float64, viewportHeight float64, lensRadius float64, focusDistance float64, time0 float64, time1 float64) PerspectiveCamera {
+	ret := PerspectiveCamera{
+		cameraBasis:    newCameraBasis(lookFrom, lookAt, vup, time0, time1),
+		ViewportHeight: viewportHeight,
+		ViewportWidth:  viewportWidth,
+		FocusDistance:  focusDistance,
+		LensRadius:     lensRadius,
+		Aperture:       CircularAperture{},
+	}
+	ret.focusNormal = ret.w
+
+	ret.horizontal = ret.u.MultiplyScalar(ret.ViewportWidth)
+	ret.vertical = ret.v.MultiplyScalar(ret.ViewportHeight)
+	ret.lowerLeftCorner = ret.horizontal.DivideScalar(2).
+		Add(ret.vertical.DivideScalar(2)).
+		Add(ret.w).
+		Neg()
+	return ret
+}
+
+// PhysicalCamera describes a camera the way a photographer would.
+// Lengths on the camera body are in millimeters, and lengths in the
+// scene are in meters, which is one scene unit.
+type PhysicalCamera struct {
+	LookFrom      Vector3
+	LookAt        Vector3
+	Vup           Vector3
+	SensorWidth   float64
+	SensorHeight  float64
+	FocalLength   float64
+	FStop         float64
+	FocusDistance float64
+	Aperture      ApertureShape
+
+	// ShiftX and ShiftY move the lens parallel to the sensor, in
+	// millimeters, to reframe without converging verticals.
+	ShiftX float64
+	ShiftY float64
+
+	// TiltX tilts the lens up or down, and TiltY tilts it left or
+	// right, in degrees.  This swings the plane of focus about the
+	// hinge line, following the Scheimpflug principle.
+	TiltX float64
+	TiltY float64
 
-	ret.horizontal = ret.u.MultiplyScalar(ret.ViewportWidth * ret.FocalLength)
-	ret.vertical = ret.v.MultiplyScalar(ret.ViewportHeight * ret.FocalLength)
-	ret.lowerLeftCorner = ret.origin.
-		Subtract(ret.horizontal.DivideScalar(2)).
-		Subtract(ret.vertical.DivideScalar(2)).
-		Subtract(ret.w.MultiplyScalar(ret.FocalLength))
+	Time0 float64
+	Time1 float64
+}
+
+// Camera returns the camera for these settings.  Focus breathing is
+// ignored, so the field of view is that of the lens focused at infinity.
+func (pc PhysicalCamera) Camera() PerspectiveCamera {
+	ret := newCamera(pc.LookFrom, pc.LookAt, pc.Vup,
+		pc.SensorWidth/pc.FocalLength, pc.SensorHeight/pc.FocalLength,
+		pc.FocalLength/pc.FStop/2/1000, pc.FocusDistance, pc.Time0, pc.Time1)
+	if pc.Aperture != nil {
+		ret.Aperture = pc.Aperture
+	}
+	ret.Shift(pc.ShiftX/pc.SensorWidth, pc.ShiftY/pc.SensorHeight)
+
+	// By the hinge rule, the plane of focus passes through a line
+	// at J = f / sin(tilt) from the lens, and through the point of focus
+	// on the lens axis, so it leans by atan(d / J).
+	focalLength := pc.FocalLength / 1000
+	leanX := math.Atan(pc.FocusDistance * math.Sin(pc.TiltX*math.Pi/180) / focalLength)
+	leanY := math.Atan(pc.FocusDistance * math.Sin(pc.TiltY*math.Pi/180) / focalLength)
+	ret.Tilt(leanX*180/math.Pi, leanY*180/math.Pi)
 	return ret
 }
 
-func randomBetween(a, b float64) float64 {
-	r := b - a
-	return a + rand.Float64()*r
+// Shift slides the image window by a fraction of its width and height,
+// like a shift lens.
+func (c *PerspectiveCamera) Shift(x float64, y float64) {
+	c.lowerLeftCorner = c.lowerLeftCorner.
+		Add(c.horizontal.MultiplyScalar(x)).
+		Add(c.vertical.MultiplyScalar(y))
 }
 
-// GetRay returns a ray from the camera's origin, pointing in the
-// specified direction calculated by u, v.
-func (c Camera) GetRay(s float64, t float64) Ray {
-	rd := RandomUnitDisk().MultiplyScalar(c.LensRadius)
-	offset := c.u.MultiplyScalar(rd.X).Add(c.v.MultiplyScalar(rd.Y))
-	direction := c.lowerLeftCorner.
+// Tilt leans the plane of focus, in degrees.  A positive x leans the top
+// of the plane away from the camera, and a positive y leans the right
+// side away.
+func (c *PerspectiveCamera) Tilt(x float64, y float64) {
+	sx, cx := math.Sincos(x * math.Pi / 180)
+	sy, cy := math.Sincos(y * math.Pi / 180)
+	n := c.w.MultiplyScalar(cx).Add(c.v.MultiplyScalar(sx))
+	c.focusNormal = n.MultiplyScalar(cy).Add(c.u.MultiplyScalar(sy)).Normalize()
+}
+
+// FocusOn sets the focus distance so that target is in focus.
+func (c *PerspectiveCamera) FocusOn(target Vector3) {
+	c.FocusDistance = c.origin.Subtract(target).Dot(c.w)
+}
+
+// AutoFocus focuses on whatever is first hit by a ray through the
+// center of the image.  It returns false and leaves the focus alone if
+// nothing is hit.
+func (c *PerspectiveCamera) AutoFocus(w World) bool {
+	r := NewRay(c.origin, c.pinholeDirection(0.5, 0.5), c.Time0)
+	hr := w.Hit(r)
+	if hr == nil {
+		return false
+	}
+	c.FocusOn(hr.P)
+	return true
+}
+
+func (c PerspectiveCamera) pinholeDirection(s float64, t float64) Vector3 {
+	return c.lowerLeftCorner.
 		Add(c.horizontal.MultiplyScalar(s)).
-		Add(c.vertical.MultiplyScalar(t)).
-		Subtract(c.origin).
-		Subtract(offset)
-	return Ray{c.origin.Add(offset), direction, randomBetween(c.Time0, c.Time1)}
+		Add(c.vertical.MultiplyScalar(t))
+}
+
+// Project returns where p appears on the image, as seen through the
+// center of the lens.
+func (c PerspectiveCamera) Project(p Vector3) (bool, float64, float64) {
+	d := p.Subtract(c.origin)
+	z := -d.Dot(c.w)
+	if z <= 0 {
+		return false, 0, 0
+	}
+	onPlane := d.DivideScalar(z).Subtract(c.lowerLeftCorner)
+	return true, onPlane.Dot(c.u) / c.ViewportWidth, onPlane.Dot(c.v) / c.ViewportHeight
+}
+
+// GetRay returns a ray from the camera's lens, through the point on the
+// plane of focus seen at s, t.
+func (c PerspectiveCamera) GetRay(s float64, t float64, smp Sampler) (bool, Ray) {
+	direction := c.pinholeDirection(s, t)
+	time := c.time(smp)
+	if c.LensRadius <= 0 {
+		return true, NewRay(c.origin, direction, time)
+	}
+
+	// Every ray through the lens for this pixel meets the pinhole ray
+	// where it crosses the plane of focus.
+	planePoint := c.w.MultiplyScalar(-c.FocusDistance)
+	k := planePoint.Dot(c.focusNormal) / direction.Dot(c.focusNormal)
+
+	x, y := c.Aperture.Sample(smp.Get2D())
+	offset := c.u.MultiplyScalar(x * c.LensRadius).Add(c.v.MultiplyScalar(y * c.LensRadius))
+	if k <= 0 || math.IsInf(k, 0) {
+		// A tilted plane of focus may never cross this ray, so it
+		// is focused at infinity.
+		return true, NewRay(c.origin.Add(offset), direction, time)
+	}
+	focus := direction.MultiplyScalar(k)
+	return true, NewRay(c.origin.Add(offset), focus.Subtract(offset), time)
 }
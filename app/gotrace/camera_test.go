@@ -0,0 +1,134 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// focusPoint returns where a ray crosses the plane through the camera's
+// point of focus with the given normal.
+func focusPoint(c PerspectiveCamera, r Ray) Vector3 {
+	p0 := c.origin.Subtract(c.w.MultiplyScalar(c.FocusDistance))
+	k := p0.Subtract(r.Origin).Dot(c.focusNormal) / r.Direction.Dot(c.focusNormal)
+	return r.Point(k)
+}
+
+func TestCamera_GetRayConvergesOnFocus(t *testing.T) {
+	apertures := []ApertureShape{
+		CircularAperture{},
+		NewPolygonalAperture(6, 15),
+	}
+	for _, tilt := range []float64{0, 20} {
+		for _, aperture := range apertures {
+			c := NewCamera(Vector3{0, 0, 0}, Vector3{0, 0, -1}, Vector3{0, 1, 0}, 40, 1.5, 2, 5, 0, 1)
+			c.Aperture = aperture
+			c.Tilt(tilt, 0)
+			want := focusPoint(c, Ray{Origin: c.origin, Direction: c.pinholeDirection(0.2, 0.7)})
+			for i := 0; i < 100; i++ {
+				_, r := c.GetRay(0.2, 0.7, NewIndependentSampler(1))
+				if got := focusPoint(c, r); got.Subtract(want).Length() > 1e-9 {
+					t.Fatalf("tilt %v %T: ray crosses focus at %v, want %v", tilt, aperture, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCamera_FocusOn(t *testing.T) {
+	c := NewCamera(Vector3{0, 0, 0}, Vector3{0, 0, -1}, Vector3{0, 1, 0}, 40, 1.5, 2, 5, 0, 1)
+	c.FocusOn(Vector3{1, 1, -7})
+	if c.FocusDistance != 7 {
+		t.Errorf("FocusDistance = %v, want 7", c.FocusDistance)
+	}
+}
+
+func TestPhysicalCamera_Camera(t *testing.T) {
+	pc := PhysicalCamera{
+		LookFrom:      Vector3{0, 0, 0},
+		LookAt:        Vector3{0, 0, -1},
+		Vup:           Vector3{0, 1, 0},
+		SensorWidth:   36,
+		SensorHeight:  24,
+		FocalLength:   50,
+		FStop:         2,
+		FocusDistance: 3,
+	}
+	c := pc.Camera()
+	if math.Abs(c.LensRadius-0.0125) > 1e-12 {
+		t.Errorf("LensRadius = %v, want 0.0125", c.LensRadius)
+	}
+	if math.Abs(c.ViewportWidth-0.72) > 1e-12 || math.Abs(c.ViewportHeight-0.48) > 1e-12 {
+		t.Errorf("Viewport = %v x %v, want 0.72 x 0.48", c.ViewportWidth, c.ViewportHeight)
+	}
+}
+
+func TestPolygonalAperture_Sample(t *testing.T) {
+	a := NewPolygonalAperture(5, 0)
+	for i := 0; i < 1000; i++ {
+		if x, y := a.Sample(rand.Float64(), rand.Float64()); x*x+y*y > 1 {
+			t.Fatalf("sample %v, %v outside the unit circle", x, y)
+		}
+	}
+}
+
+func TestCamera_Projections(t *testing.T) {
+	from := Vector3{1, 2, 3}
+	at := Vector3{1, 2, 0}
+	up := Vector3{0, 1, 0}
+	forward := Vector3{0, 0, -1}
+	right := Vector3{1, 0, 0}
+	tests := []struct {
+		name   string
+		camera Camera
+		s, t   float64
+		want   Vector3
+	}{
+		{"orthographic center", NewOrthographicCamera(from, at, up, 4, 2, 0, 0), 0.5, 0.5, forward},
+		{"orthographic corner", NewOrthographicCamera(from, at, up, 4, 2, 0, 0), 0, 0, forward},
+		{"fisheye center", NewFisheyeCamera(from, at, up, 180, 1, 0, 0), 0.5, 0.5, forward},
+		{"fisheye edge", NewFisheyeCamera(from, at, up, 180, 1, 0, 0), 1, 0.5, right},
+		{"equirectangular center", NewEquirectangularCamera(from, at, up, 0, 0), 0.5, 0.5, forward},
+		{"equirectangular right", NewEquirectangularCamera(from, at, up, 0, 0), 0.75, 0.5, right},
+		{"equirectangular behind", NewEquirectangularCamera(from, at, up, 0, 0), 0, 0.5, forward.Neg()},
+		{"equirectangular up", NewEquirectangularCamera(from, at, up, 0, 0), 0.3, 1, up},
+		{"cubemap right", NewCubemapCamera(from, at, up, 0, 0), 1.0 / 6, 0.75, right},
+		{"cubemap up", NewCubemapCamera(from, at, up, 0, 0), 5.0 / 6, 0.75, up},
+		{"cubemap front", NewCubemapCamera(from, at, up, 0, 0), 0.5, 0.25, forward},
+		{"cubemap back", NewCubemapCamera(from, at, up, 0, 0), 5.0 / 6, 0.25, forward.Neg()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, r := tt.camera.GetRay(tt.s, tt.t, NewIndependentSampler(1))
+			if !ok {
+				t.Fatal("expected a ray")
+			}
+			if got := r.Direction.Normalize(); got.Subtract(tt.want).Length() > 1e-9 {
+				t.Errorf("direction = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFisheyeCamera_OutsideImageCircle(t *testing.T) {
+	c := NewFisheyeCamera(Vector3{0, 0, 0}, Vector3{0, 0, -1}, Vector3{0, 1, 0}, 180, 2, 0, 0)
+	if ok, _ := c.GetRay(0.01, 0.5, NewIndependentSampler(1)); ok {
+		t.Error("expected no ray outside the image circle")
+	}
+}
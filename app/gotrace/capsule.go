@@ -0,0 +1,123 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type capsule struct {
+	End0     Vector3
+	End1     Vector3
+	Radius   float64
+	Material Material
+
+	axis   Vector3
+	length float64
+	// across is perpendicular to the axis, where U starts.
+	across Vector3
+}
+
+// NewCapsule returns a cylinder from end0 to end1 closed by a
+// hemisphere at each end.
+func NewCapsule(end0 Vector3, end1 Vector3, radius float64, mat Material) Solid {
+	c := capsule{End0: end0, End1: end1, Radius: radius, Material: mat}
+	span := end1.Subtract(end0)
+	c.length = span.Length()
+	c.axis = span.DivideScalar(c.length)
+	if c.length == 0 {
+		c.axis = Vector3{0, 1, 0}
+	}
+	helper := Vector3{1, 0, 0}
+	if math.Abs(c.axis.X) > 0.9 {
+		helper = Vector3{0, 1, 0}
+	}
+	c.across = c.axis.Cross(helper).Normalize()
+	return closedSurface{
+		Parts: []crosser{c},
+		Box:   c.box(),
+	}
+}
+
+// crossings finds where the ray crosses the side of the tube between
+// the ends, and each end's sphere beyond its end of the tube.
+func (c capsule) crossings(r Ray) []*HitRecord {
+	r.countIntersectionTest()
+	oc := r.Origin.Subtract(c.End0)
+	// Work with the parts of the ray across the axis.
+	oa, da := oc.Dot(c.axis), r.Direction.Dot(c.axis)
+	oPerp := oc.Subtract(c.axis.MultiplyScalar(oa))
+	dPerp := r.Direction.Subtract(c.axis.MultiplyScalar(da))
+
+	var ret []*HitRecord
+	for _, t := range solveQuadratic(dPerp.LengthSquared(), 2*oPerp.Dot(dPerp), oPerp.LengthSquared()-c.Radius*c.Radius) {
+		if h := oa + t*da; h >= 0 && h <= c.length {
+			ret = append(ret, c.surface(r, t, c.End0.Add(c.axis.MultiplyScalar(h))))
+		}
+	}
+	for _, end := range []Vector3{c.End0, c.End1} {
+		t0, t1, ok := sphereRoots(r, end, c.Radius)
+		if !ok {
+			continue
+		}
+		for _, t := range []float64{t0, t1} {
+			h := oa + t*da
+			if (end == c.End0 && h < 0) || (end == c.End1 && h > c.length) {
+				ret = append(ret, c.surface(r, t, end))
+			}
+		}
+	}
+	return ret
+}
+
+// surface returns the hit at t, whose nearest point on the axis is
+// center.  V runs from the tip of the first end to the tip of the
+// second.
+func (c capsule) surface(r Ray, t float64, center Vector3) *HitRecord {
+	p := r.Point(t)
+	normal := p.Subtract(center).DivideScalar(c.Radius)
+	side := c.axis.Cross(c.across)
+	phi := math.Atan2(normal.Dot(side), normal.Dot(c.across))
+	if phi < 0 {
+		phi += 2 * math.Pi
+	}
+	h := p.Subtract(c.End0).Dot(c.axis)
+	// On the ends, P also turns in towards the axis as V increases, and
+	// at their tips V has no direction, which leaves DPDV zero.
+	along := normal.Dot(c.axis) * c.Radius
+	radial := normal.MultiplyScalar(c.Radius).Subtract(c.axis.MultiplyScalar(along))
+	var dpdv Vector3
+	if rho2 := radial.LengthSquared(); rho2 > 1e-12*c.Radius*c.Radius {
+		dpdv = c.axis.Subtract(radial.MultiplyScalar(along / rho2)).MultiplyScalar(c.length + 2*c.Radius)
+	}
+	return &HitRecord{
+		T:        t,
+		P:        p,
+		Normal:   normal,
+		U:        phi / (2 * math.Pi),
+		V:        clamp((h+c.Radius)/(c.length+2*c.Radius), 0, 1),
+		DPDU:     c.axis.Cross(radial).MultiplyScalar(2 * math.Pi),
+		DPDV:     dpdv,
+		Material: c.Material,
+	}
+}
+
+func (c capsule) box() aabb {
+	pad := Vector3{c.Radius, c.Radius, c.Radius}
+	return surroundingBox(
+		aabb{c.End0.Subtract(pad), c.End0.Add(pad)},
+		aabb{c.End1.Subtract(pad), c.End1.Add(pad)},
+	)
+}
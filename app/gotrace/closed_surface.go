@@ -0,0 +1,107 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// crosser is a surface which can list every place the whole line
+// through a ray crosses it, whatever the sign of t, with the normals
+// pointing outward rather than against the ray.
+type crosser interface {
+	crossings(r Ray) []*HitRecord
+}
+
+// firstCrossing returns the nearest crossing within tMin to tMax, with
+// the normal set against the ray, or nil if there is none.
+func firstCrossing(r Ray, crossings []*HitRecord, tMin float64, tMax float64) *HitRecord {
+	var ret *HitRecord
+	for _, hr := range crossings {
+		if hr.T >= tMin && hr.T <= tMax && (ret == nil || hr.T < ret.T) {
+			ret = hr
+		}
+	}
+	if ret != nil {
+		ret.SetFaceNormal(r, ret.Normal)
+	}
+	return ret
+}
+
+// sweepAngle returns the angle of x, z around the Y axis, from +X
+// towards +Z, between 0 and 2 pi.
+func sweepAngle(x float64, z float64) float64 {
+	phi := math.Atan2(z, x)
+	if phi < 0 {
+		phi += 2 * math.Pi
+	}
+	return phi
+}
+
+// sweepRadians converts a sweep given in degrees, clamping it to a
+// full turn.
+func sweepRadians(degrees float64) float64 {
+	return clamp(degrees, 0, 360) * math.Pi / 180
+}
+
+// closedSurface is a Solid made of surfaces which together enclose a
+// volume, so that a line crossing them goes alternately in and out.
+type closedSurface struct {
+	Parts []crosser
+	Box   aabb
+}
+
+func (s closedSurface) crossings(r Ray) []*HitRecord {
+	var ret []*HitRecord
+	for _, p := range s.Parts {
+		ret = append(ret, p.crossings(r)...)
+	}
+	return ret
+}
+
+func (s closedSurface) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return firstCrossing(r, s.crossings(r), tMin, tMax)
+}
+
+// Intervals pairs each crossing into the volume, where the outward
+// normal faces the ray, with the next crossing out of it.  A line
+// grazing an edge may find only one side of the edge, so an entry
+// while already inside, or an exit while outside, is a graze and is
+// skipped, as is a crossing exactly along the surface.
+func (s closedSurface) Intervals(r Ray) []Interval {
+	crossings := s.crossings(r)
+	sort.Slice(crossings, func(i, j int) bool {
+		return crossings[i].T < crossings[j].T
+	})
+	ret := make([]Interval, 0, len(crossings)/2)
+	var enter *HitRecord
+	for _, hr := range crossings {
+		switch d := hr.Normal.Dot(r.Direction); {
+		case d < 0 && enter == nil:
+			enter = hr
+		case d > 0 && enter != nil:
+			ret = append(ret, Interval{enter, hr})
+			enter = nil
+		}
+	}
+	return ret
+}
+
+func (s closedSurface) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return s.Box, true
+}
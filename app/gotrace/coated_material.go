@@ -0,0 +1,110 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// CoatedMaterial is a layer of clear or tinted varnish over another
+// material, such as the clear coat on car paint or lacquer on wood.
+// The coat reflects light by the Fresnel equations, more so at
+// grazing angles, and what it lets through reaches the base, which
+// scatters it as usual.  Light which the base scatters back is tinted
+// by the coat's color on its way in and out, and loses what the coat
+// reflects back down as it leaves.
+type CoatedMaterial struct {
+	base  Material
+	ior   float64
+	alpha float64
+	color Vector3
+}
+
+// coatedMedium is a coat over a material with a medium inside, which
+// it keeps, so that it still nests with other media.
+type coatedMedium struct {
+	CoatedMaterial
+}
+
+// NewCoatedMaterial returns base under a coat with the given index of
+// refraction, roughness from 0 for glossy to 1, and color, which is
+// what light keeps after passing straight in and out of the coat.
+func NewCoatedMaterial(base Material, ior float64, roughness float64, color Vector3) Material {
+	m := CoatedMaterial{base: base, ior: ior, alpha: roughnessToAlpha(roughness), color: color}
+	if _, ok := base.(MediumMaterial); ok {
+		return coatedMedium{m}
+	}
+	return m
+}
+
+// Scatter reflects off the coat as often as the coat reflects, and
+// otherwise scatters off the base.
+func (m CoatedMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	frame := newShadingFrame(hr.Normal)
+	wo := frame.toLocal(r.Direction.Normalize().Neg())
+	if wo.Z <= 0 {
+		return m.base.Scatter(r, hr, smp)
+	}
+
+	facet := Vector3{0, 0, 1}
+	if m.alpha >= smoothAlpha {
+		u1, u2 := smp.Get2D()
+		facet = sampleGGXVisibleNormal(wo, m.alpha, u1, u2)
+	}
+	if smp.Get1D() < fresnelDielectric(wo.Dot(facet), m.ior) {
+		wi := reflectLocal(wo, facet)
+		if wi.Z <= 0 {
+			return false, r, Vector3{}
+		}
+		weight := 1.0
+		if m.alpha >= smoothAlpha {
+			weight = ggxG2(wo, wi, m.alpha) / ggxG1(wo, m.alpha)
+		}
+		return true, r.Spawn(hr.P, frame.fromLocal(wi)), Vector3{weight, weight, weight}
+	}
+
+	ok, scattered, attenuation := m.base.Scatter(r, hr, smp)
+	if !ok {
+		return false, scattered, attenuation
+	}
+	cosOut := scattered.Direction.Normalize().Dot(hr.Normal)
+	if cosOut <= 0 {
+		// Passing through, as with glass under the coat.
+		return true, scattered, attenuation
+	}
+	// The coat is thicker to light crossing it at an angle.
+	path := (1/math.Max(wo.Z, 1e-3) + 1/math.Max(cosOut, 1e-3)) / 2
+	tint := Vector3{math.Pow(m.color.X, path), math.Pow(m.color.Y, path), math.Pow(m.color.Z, path)}
+	leaving := 1 - fresnelDielectric(cosOut, m.ior)
+	return true, scattered, attenuation.Multiply(tint).MultiplyScalar(leaving)
+}
+
+// Albedo returns the base's color, seen through the coat.
+func (m CoatedMaterial) Albedo(hr *HitRecord) Vector3 {
+	if am, ok := m.base.(AlbedoMaterial); ok {
+		return am.Albedo(hr).Multiply(m.color)
+	}
+	return m.color
+}
+
+// OneSided returns true if the base is one-sided.
+func (m CoatedMaterial) OneSided() bool {
+	return oneSided(m.base)
+}
+
+// Interior returns the base's medium.
+func (m coatedMedium) Interior() Medium {
+	return m.base.(MediumMaterial).Interior()
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// ComplexIOR is the complex index of refraction of a metal, eta + ik,
+// given for red, green and blue.
+type ComplexIOR struct {
+	Eta Vector3
+	K   Vector3
+}
+
+// Measured indexes of refraction for common metals, taken at about
+// 650, 550 and 450 nm.
+var (
+	Gold     = ComplexIOR{Vector3{0.143119, 0.374957, 1.44248}, Vector3{3.98316, 2.38572, 1.60322}}
+	Copper   = ComplexIOR{Vector3{0.200438, 0.924033, 1.10221}, Vector3{3.91295, 2.45285, 2.14219}}
+	Aluminum = ComplexIOR{Vector3{1.65746, 0.880369, 0.521229}, Vector3{9.22387, 6.26952, 4.837}}
+	Silver   = ComplexIOR{Vector3{0.155265, 0.116723, 0.138342}, Vector3{4.82835, 3.12225, 2.14696}}
+)
+
+// ConductorMaterial is a metal, made of GGX microfacets which each
+// reflect by the Fresnel equations for the metal's complex index of
+// refraction.  Unlike ReflectiveMaterial's fuzz, it never reflects
+// more light than arrives, and blurs more at grazing angles as real
+// metals do.
+type ConductorMaterial struct {
+	ior   ComplexIOR
+	alpha float64
+}
+
+// NewConductorMaterial returns a metal with the given index of
+// refraction, such as Gold, and roughness from 0 for a mirror to 1.
+func NewConductorMaterial(ior ComplexIOR, roughness float64) ConductorMaterial {
+	return ConductorMaterial{ior: ior, alpha: roughnessToAlpha(roughness)}
+}
+
+// Scatter reflects off a microfacet picked by visible normal sampling.
+func (m ConductorMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	frame := newShadingFrame(hr.Normal)
+	wo := frame.toLocal(r.Direction.Normalize().Neg())
+	if wo.Z <= 0 {
+		return false, r, Vector3{}
+	}
+	if m.alpha < smoothAlpha {
+		wi := Vector3{-wo.X, -wo.Y, wo.Z}
+		return true, r.Spawn(hr.P, frame.fromLocal(wi)), fresnelConductor(wo.Z, m.ior.Eta, m.ior.K)
+	}
+	u1, u2 := smp.Get2D()
+	facet := sampleGGXVisibleNormal(wo, m.alpha, u1, u2)
+	wi := reflectLocal(wo, facet)
+	if wi.Z <= 0 {
+		return false, r, Vector3{}
+	}
+	weight := ggxG2(wo, wi, m.alpha) / ggxG1(wo, m.alpha)
+	attenuation := fresnelConductor(wo.Dot(facet), m.ior.Eta, m.ior.K).MultiplyScalar(weight)
+	return true, r.Spawn(hr.P, frame.fromLocal(wi)), attenuation
+}
+
+// Albedo returns the color of the metal seen head on.
+func (m ConductorMaterial) Albedo(hr *HitRecord) Vector3 {
+	return fresnelConductor(1, m.ior.Eta, m.ior.K)
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type cone struct {
+	Base     Vector3
+	Radius   float64
+	Height   float64
+	PhiMax   float64
+	Material Material
+}
+
+// NewCone returns an open cone around the Y axis, with its base circle
+// at base and its tip height above it, swept phiMax degrees around
+// from +X towards +Z.
+func NewCone(base Vector3, radius float64, height float64, phiMax float64, mat Material) Hittable {
+	return cone{base, radius, height, sweepRadians(phiMax), mat}
+}
+
+// NewCappedCone returns a cone closed across its base.
+func NewCappedCone(base Vector3, radius float64, height float64, mat Material) Solid {
+	return closedSurface{
+		Parts: []crosser{
+			cone{base, radius, height, 2 * math.Pi, mat},
+			disk{base, radius, 0, 2 * math.Pi, true, mat},
+		},
+		Box: aabb{base.Subtract(Vector3{radius, 0, radius}), base.Add(Vector3{radius, height, radius})},
+	}
+}
+
+// crossings solves x^2 + z^2 = (k (h - y))^2, where k is the slope of
+// the side.
+func (c cone) crossings(r Ray) []*HitRecord {
+	r.countIntersectionTest()
+	o := r.Origin.Subtract(c.Base)
+	d := r.Direction
+	k := c.Radius / c.Height
+	k2 := k * k
+	// Measure up from the tip, which points down from there.
+	oy, dy := c.Height-o.Y, -d.Y
+	a := d.X*d.X + d.Z*d.Z - k2*dy*dy
+	bHalf := o.X*d.X + o.Z*d.Z - k2*oy*dy
+	cc := o.X*o.X + o.Z*o.Z - k2*oy*oy
+
+	var roots []float64
+	if math.Abs(a) < 1e-12 {
+		// The ray runs parallel to the side, and crosses it once.
+		if bHalf == 0 {
+			return nil
+		}
+		roots = []float64{-cc / (2 * bHalf)}
+	} else {
+		discriminant := bHalf*bHalf - a*cc
+		if discriminant < 0 {
+			return nil
+		}
+		sqrtd := math.Sqrt(discriminant)
+		roots = []float64{(-bHalf - sqrtd) / a, (-bHalf + sqrtd) / a}
+	}
+
+	var ret []*HitRecord
+	for _, t := range roots {
+		p := o.Add(d.MultiplyScalar(t))
+		phi := sweepAngle(p.X, p.Z)
+		if p.Y < 0 || p.Y > c.Height || phi > c.PhiMax {
+			continue
+		}
+		ret = append(ret, &HitRecord{
+			T:        t,
+			P:        p.Add(c.Base),
+			Normal:   Vector3{p.X, k2 * (c.Height - p.Y), p.Z}.Normalize(),
+			U:        phi / c.PhiMax,
+			V:        p.Y / c.Height,
+			DPDU:     Vector3{-p.Z, 0, p.X}.MultiplyScalar(c.PhiMax),
+			DPDV:     Vector3{-c.Radius * math.Cos(phi), c.Height, -c.Radius * math.Sin(phi)},
+			Material: c.Material,
+		})
+	}
+	return ret
+}
+
+func (c cone) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return firstCrossing(r, c.crossings(r), tMin, tMax)
+}
+
+func (c cone) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return aabb{
+		c.Base.Subtract(Vector3{c.Radius, 0, c.Radius}),
+		c.Base.Add(Vector3{c.Radius, c.Height, c.Radius}),
+	}, true
+}
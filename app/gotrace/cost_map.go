@@ -0,0 +1,161 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CostKind is a measure of how much work a pixel took to render.
+type CostKind int
+
+// Supported costs.
+const (
+	// CostBounces counts the rays scattered from hits.
+	CostBounces CostKind = iota
+	// CostTests counts the ray-object intersection tests.
+	CostTests
+	// CostTime is the wall-clock time spent on the pixel.
+	CostTime
+	numCostKinds
+)
+
+var costNames = [numCostKinds]string{"bounces", "tests", "time"}
+
+func (k CostKind) String() string {
+	return costNames[k]
+}
+
+// ParseCostKinds parses a comma separated list of cost names.
+func ParseCostKinds(list string) ([]CostKind, error) {
+	ret := []CostKind{}
+	if list == "" {
+		return ret, nil
+	}
+outer:
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		for k, n := range costNames {
+			if n == name {
+				ret = append(ret, CostKind(k))
+				continue outer
+			}
+		}
+		return nil, fmt.Errorf("unknown heat map %q", name)
+	}
+	return ret, nil
+}
+
+// CostMap records what each pixel of a rectangle of the image cost to
+// render, summed over all of its samples.
+type CostMap struct {
+	Bounds image.Rectangle
+	values [numCostKinds][]float64
+}
+
+// NewCostMap returns an empty cost map covering bounds, in raster space.
+func NewCostMap(bounds image.Rectangle) *CostMap {
+	ret := &CostMap{Bounds: bounds}
+	for k := range ret.values {
+		ret.values[k] = make([]float64, bounds.Dx()*bounds.Dy())
+	}
+	return ret
+}
+
+func (m *CostMap) offset(x int, y int) int {
+	return (y-m.Bounds.Min.Y)*m.Bounds.Dx() + (x - m.Bounds.Min.X)
+}
+
+// AddPixel records the work done on pixel x, y, and how long it took.
+func (m *CostMap) AddPixel(x int, y int, stats RayStats, d time.Duration) {
+	p := m.offset(x, y)
+	m.values[CostBounces][p] += float64(stats.SecondaryRays)
+	m.values[CostTests][p] += float64(stats.IntersectionTests)
+	m.values[CostTime][p] += d.Seconds()
+}
+
+// Merge adds the costs recorded in another map into this one.
+func (m *CostMap) Merge(o *CostMap) {
+	area := o.Bounds.Intersect(m.Bounds)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			p, op := m.offset(x, y), o.offset(x, y)
+			for k := range m.values {
+				m.values[k][p] += o.values[k][op]
+			}
+		}
+	}
+}
+
+// Value returns one cost of a pixel.  Time is in seconds.
+func (m *CostMap) Value(k CostKind, x int, y int) float64 {
+	return m.values[k][m.offset(x, y)]
+}
+
+// heatMapPercentile is the share of pixels below the top of the color
+// scale, so a few very expensive pixels do not wash out the rest.
+const heatMapPercentile = 0.99
+
+// heatColors runs from cheap to expensive, and is interpolated between.
+var heatColors = []Vector3{
+	{0.05, 0.03, 0.2},
+	{0.2, 0.2, 0.8},
+	{0.1, 0.6, 0.9},
+	{0.2, 0.85, 0.4},
+	{0.9, 0.9, 0.2},
+	{0.95, 0.5, 0.1},
+	{0.8, 0.1, 0.05},
+	{1, 1, 1},
+}
+
+// heatColor maps 0..1 onto the heat colors.
+func heatColor(v float64) Vector3 {
+	v = clamp(v, 0, 1) * float64(len(heatColors)-1)
+	i := int(v)
+	if i >= len(heatColors)-1 {
+		return heatColors[len(heatColors)-1]
+	}
+	return heatColors[i].Lerp(heatColors[i+1], v-float64(i))
+}
+
+// HeatMap returns a false color picture of one cost, scaled so the
+// most expensive percent of pixels are all at the top of the scale.
+func (m *CostMap) HeatMap(k CostKind) *image.NRGBA {
+	sorted := append([]float64{}, m.values[k]...)
+	sort.Float64s(sorted)
+	top := 1.0
+	if len(sorted) > 0 {
+		top = sorted[int(float64(len(sorted)-1)*heatMapPercentile)]
+	}
+	if top <= 0 {
+		top = 1
+	}
+
+	im := image.NewNRGBA(image.Rect(0, 0, m.Bounds.Dx(), m.Bounds.Dy()))
+	for i, v := range m.values[k] {
+		c := heatColor(v/top).Clamp(0, 0.999).MultiplyScalar(256)
+		im.Pix[i*4] = uint8(c.X)
+		im.Pix[i*4+1] = uint8(c.Y)
+		im.Pix[i*4+2] = uint8(c.Z)
+		im.Pix[i*4+3] = 0xff
+	}
+	return im
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCostKinds(t *testing.T) {
+	got, err := ParseCostKinds("time, tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []CostKind{CostTime, CostTests}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCostKinds() = %v, want %v", got, want)
+	}
+	if _, err := ParseCostKinds("bounces,memory"); err == nil {
+		t.Errorf("ParseCostKinds() with an unknown name succeeded")
+	}
+}
+
+func TestCostMap_MergeAndHeatMap(t *testing.T) {
+	m := NewCostMap(image.Rect(0, 0, 2, 2))
+	for y := 0; y < 2; y++ {
+		line := NewCostMap(image.Rect(0, y, 2, y+1))
+		line.AddPixel(1, y, RayStats{SecondaryRays: 3, IntersectionTests: 10}, time.Millisecond)
+		m.Merge(line)
+	}
+
+	if got := m.Value(CostTests, 1, 1); got != 10 {
+		t.Errorf("tests = %v, want 10", got)
+	}
+	if got := m.Value(CostBounces, 0, 1); got != 0 {
+		t.Errorf("bounces of an untouched pixel = %v, want 0", got)
+	}
+	if got := m.Value(CostTime, 1, 0); got != 0.001 {
+		t.Errorf("time = %v, want 0.001", got)
+	}
+
+	im := m.HeatMap(CostTests)
+	if cheap, dear := im.NRGBAAt(0, 0), im.NRGBAAt(1, 0); cheap == dear {
+		t.Errorf("cheap and expensive pixels are both %v", cheap)
+	}
+}
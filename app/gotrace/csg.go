@@ -0,0 +1,157 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "sort"
+
+// CSGOperation is how a CSG node combines its two solids.
+type CSGOperation int
+
+// Supported CSG operations.
+const (
+	// CSGUnion is inside either solid.
+	CSGUnion CSGOperation = iota
+	// CSGIntersection is inside both solids.
+	CSGIntersection
+	// CSGDifference is inside the first solid but not the second.
+	CSGDifference
+)
+
+func (op CSGOperation) inside(inA bool, inB bool) bool {
+	switch op {
+	case CSGIntersection:
+		return inA && inB
+	case CSGDifference:
+		return inA && !inB
+	default:
+		return inA || inB
+	}
+}
+
+type csg struct {
+	Operation CSGOperation
+	A         Solid
+	B         Solid
+}
+
+// NewUnion returns the solid which is inside either a or b.
+func NewUnion(a Solid, b Solid) Solid {
+	return csg{CSGUnion, a, b}
+}
+
+// NewIntersection returns the solid which is inside both a and b.
+func NewIntersection(a Solid, b Solid) Solid {
+	return csg{CSGIntersection, a, b}
+}
+
+// NewDifference returns the solid which is inside a but not b.  Where
+// b cuts into a, the surface is b's, turned inside out, and has b's
+// material.
+func NewDifference(a Solid, b Solid) Solid {
+	return csg{CSGDifference, a, b}
+}
+
+type csgEvent struct {
+	hr    *HitRecord
+	fromB bool
+	enter bool
+}
+
+// Intervals walks along the ray through the surfaces of both solids,
+// tracking whether it is inside each, and so whether it is inside the
+// combination.
+func (c csg) Intervals(r Ray) []Interval {
+	a := c.A.Intervals(r)
+	b := c.B.Intervals(r)
+	switch {
+	case len(b) == 0 && c.Operation != CSGIntersection:
+		return a
+	case len(a) == 0 && c.Operation == CSGUnion:
+		return b
+	case len(a) == 0 || len(b) == 0:
+		return nil
+	}
+
+	events := make([]csgEvent, 0, 2*(len(a)+len(b)))
+	for _, iv := range a {
+		events = append(events, csgEvent{iv.Enter, false, true}, csgEvent{iv.Exit, false, false})
+	}
+	for _, iv := range b {
+		events = append(events, csgEvent{iv.Enter, true, true}, csgEvent{iv.Exit, true, false})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].hr.T < events[j].hr.T
+	})
+
+	var ret []Interval
+	var enter *HitRecord
+	inA, inB := false, false
+	for _, e := range events {
+		if e.fromB {
+			inB = e.enter
+		} else {
+			inA = e.enter
+		}
+		inside := c.Operation.inside(inA, inB)
+		if inside && enter == nil {
+			enter = c.surface(e)
+		} else if !inside && enter != nil {
+			if exit := c.surface(e); exit.T > enter.T {
+				ret = append(ret, Interval{enter, exit})
+			}
+			enter = nil
+		}
+	}
+	return ret
+}
+
+// surface returns the hit for an event, with the normal turned to
+// point out of the combined solid.
+func (c csg) surface(e csgEvent) *HitRecord {
+	if c.Operation != CSGDifference || !e.fromB {
+		return e.hr
+	}
+	hr := *e.hr
+	hr.Normal = hr.Normal.Neg()
+	return &hr
+}
+
+func (c csg) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return firstSurface(r, c.Intervals(r), tMin, tMax)
+}
+
+func (c csg) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	boxA, okA := c.A.BoundingBox(time0, time1)
+	boxB, okB := c.B.BoundingBox(time0, time1)
+	switch c.Operation {
+	case CSGIntersection:
+		if !okA {
+			return boxB, okB
+		}
+		if !okB {
+			return boxA, true
+		}
+		return aabb{maxVector(boxA.minimum, boxB.minimum), minVector(boxA.maximum, boxB.maximum)}, true
+	case CSGDifference:
+		return boxA, okA
+	default:
+		if !okA || !okB {
+			return aabb{}, false
+		}
+		return surroundingBox(boxA, boxB), true
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCSG_Hit(t *testing.T) {
+	mat := NewLambertianMaterial(Vector3{0.5, 0.5, 0.5})
+	// Two unit spheres overlapping between x = 0 and x = 1.
+	left := NewSphere(Vector3{0, 0, 0}, 1, mat)
+	right := NewSphere(Vector3{1, 0, 0}, 1, mat)
+	alongX := NewRay(Vector3{-5, 0, 0}, Vector3{1, 0, 0}, 0)
+
+	tests := []struct {
+		name       string
+		solid      Solid
+		r          Ray
+		wantT      float64
+		wantNormal Vector3
+		wantFront  bool
+	}{
+		{"union", NewUnion(left, right), alongX, 4, Vector3{-1, 0, 0}, true},
+		{"intersection", NewIntersection(left, right), alongX, 5, Vector3{-1, 0, 0}, true},
+		{"difference", NewDifference(left, right), alongX, 4, Vector3{-1, 0, 0}, true},
+		// Coming from the right, the first surface is where the right
+		// sphere was carved out, facing away from where it was.
+		{"difference carved face", NewDifference(left, right), NewRay(Vector3{2.5, 0, 0}, Vector3{-1, 0, 0}, 0), 2.5, Vector3{1, 0, 0}, true},
+		// Starting inside the union, the first surface is the far side.
+		{"inside union", NewUnion(left, right), NewRay(Vector3{0.5, 0, 0}, Vector3{1, 0, 0}, 0), 1.5, Vector3{-1, 0, 0}, false},
+		{"box minus sphere", NewDifference(NewBox(Vector3{-2, -2, -2}, Vector3{2, 2, 2}, mat), left), NewRay(Vector3{0, 0, 0}, Vector3{1, 0, 0}, 0), 1, Vector3{-1, 0, 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := tt.solid.Hit(tt.r, 0.001, math.MaxFloat64)
+			if hr == nil {
+				t.Fatal("missed")
+			}
+			if math.Abs(hr.T-tt.wantT) > 1e-9 {
+				t.Errorf("T = %v, want %v", hr.T, tt.wantT)
+			}
+			if !nearVector(hr.Normal, tt.wantNormal) {
+				t.Errorf("Normal = %v, want %v", hr.Normal, tt.wantNormal)
+			}
+			if hr.FrontFace != tt.wantFront {
+				t.Errorf("FrontFace = %v, want %v", hr.FrontFace, tt.wantFront)
+			}
+		})
+	}
+}
+
+func TestCSG_DifferenceMisses(t *testing.T) {
+	mat := NewLambertianMaterial(Vector3{0.5, 0.5, 0.5})
+	small := NewSphere(Vector3{0, 0, 0}, 1, mat)
+	big := NewSphere(Vector3{0, 0, 0}, 2, mat)
+	r := NewRay(Vector3{-5, 0, 0}, Vector3{1, 0, 0}, 0)
+	if hr := NewDifference(small, big).Hit(r, 0.001, math.MaxFloat64); hr != nil {
+		t.Errorf("hit at %v, want nothing left of the small sphere", hr.T)
+	}
+}
+
+func TestTransformedSolid_Intervals(t *testing.T) {
+	mat := NewLambertianMaterial(Vector3{0.5, 0.5, 0.5})
+	moved := NewTransformedSolid(NewBox(Vector3{-1, -1, -1}, Vector3{1, 1, 1}, mat), Motion{
+		Translate: StaticTrack(Vector3{3, 0, 0}),
+		Rotate:    StaticTrack(Vector3{0, 45, 0}),
+	})
+	intervals := moved.Intervals(NewRay(Vector3{-5, 0, 0}, Vector3{1, 0, 0}, 0))
+	if len(intervals) != 1 {
+		t.Fatalf("got %d intervals, want 1", len(intervals))
+	}
+	enter, exit := intervals[0].Enter, intervals[0].Exit
+	if math.Abs(enter.T-(8-math.Sqrt2)) > 1e-9 || math.Abs(exit.T-(8+math.Sqrt2)) > 1e-9 {
+		t.Errorf("interval = %v..%v, want %v..%v", enter.T, exit.T, 8-math.Sqrt2, 8+math.Sqrt2)
+	}
+	if enter.Normal.X >= 0 || exit.Normal.X <= 0 {
+		t.Errorf("normals %v and %v do not point out of the box", enter.Normal, exit.Normal)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// CubemapCamera renders the six 90 degree faces of a cube around the
+// camera, laid out in a 3 by 2 grid.  The top row holds right, left,
+// and up, and the bottom row holds down, front, and back.  This is
+// the usual +X, -X, +Y, -Y, +Z, -Z order, with +Z as the view direction.
+// The image should be one and a half times as wide as it is tall, so
+// the faces are square.
+type CubemapCamera struct {
+	cameraBasis
+	panoramaEye
+}
+
+// NewCubemapCamera returns a cubemap camera whose front face looks from
+// lookFrom towards lookAt.
+func NewCubemapCamera(lookFrom Vector3, lookAt Vector3, vup Vector3, time0 float64, time1 float64) CubemapCamera {
+	return CubemapCamera{
+		cameraBasis: newCameraBasis(lookFrom, lookAt, vup, time0, time1),
+	}
+}
+
+// face returns the forward, right, and up directions of a cube face.
+func (c CubemapCamera) face(n int) (Vector3, Vector3, Vector3) {
+	switch n {
+	case 0: // right
+		return c.u, c.w, c.v
+	case 1: // left
+		return c.u.Neg(), c.w.Neg(), c.v
+	case 2: // up
+		return c.v, c.u, c.w
+	case 3: // down
+		return c.v.Neg(), c.u, c.w.Neg()
+	case 4: // front
+		return c.w.Neg(), c.u, c.v
+	default: // back
+		return c.w, c.u.Neg(), c.v
+	}
+}
+
+// GetRay returns a ray from the camera's origin, or from the eye when
+// rendering stereo, through the face which covers s, t.
+func (c CubemapCamera) GetRay(s float64, t float64, smp Sampler) (bool, Ray) {
+	col := math.Min(math.Floor(s*3), 2)
+	row := math.Min(math.Floor((1-t)*2), 1)
+	if col < 0 || row < 0 {
+		return false, Ray{}
+	}
+	// position within the face, from -1 to 1
+	a := (s*3-col)*2 - 1
+	b := ((1-t)*2-row)*-2 + 1
+
+	forward, right, up := c.face(int(row)*3 + int(col))
+	direction := forward.Add(right.MultiplyScalar(a)).Add(up.MultiplyScalar(b))
+	return true, c.ray(c.cameraBasis, smp, direction)
+}
+
+func (c CubemapCamera) withEye(offset float64, convergenceDistance float64) Camera {
+	c.panoramaEye = panoramaEye{offset, convergenceDistance}
+	return c
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// CurveShape selects how a curve's width is filled in.
+type CurveShape int
+
+// Supported curve shapes.
+const (
+	// CurveRibbon is a flat strip which always faces the ray, cheap
+	// enough for grass and fur seen from a distance.
+	CurveRibbon CurveShape = iota
+	// CurveTube shades the strip as if it were round, as for hair
+	// and cables seen close up.
+	CurveTube
+)
+
+type curve struct {
+	Control  [4]Vector3
+	Width0   float64
+	Width1   float64
+	UMin     float64
+	UMax     float64
+	Shape    CurveShape
+	Material Material
+	MaxDepth int
+}
+
+// NewCurve returns a cubic Bézier curve whose width changes smoothly
+// from width0 at its start to width1 at its end.  U runs along the
+// curve, and V across it.
+func NewCurve(control [4]Vector3, width0 float64, width1 float64, shape CurveShape, mat Material) Hittable {
+	return newCurve(control, width0, width1, 0, 1, shape, mat)
+}
+
+// NewBSpline returns a uniform cubic B-spline through the points, as
+// one curve per span, such as for a strand of hair.  The width changes
+// from width0 at the start of the strand to width1 at its end, and U
+// runs along the whole strand.
+func NewBSpline(points []Vector3, width0 float64, width1 float64, shape CurveShape, mat Material) []Hittable {
+	spans := len(points) - 3
+	if spans < 1 {
+		return nil
+	}
+	ret := make([]Hittable, spans)
+	for i := range ret {
+		p0, p1, p2, p3 := points[i], points[i+1], points[i+2], points[i+3]
+		control := [4]Vector3{
+			p0.Add(p1.MultiplyScalar(4)).Add(p2).DivideScalar(6),
+			p1.MultiplyScalar(2).Add(p2).DivideScalar(3),
+			p1.Add(p2.MultiplyScalar(2)).DivideScalar(3),
+			p1.Add(p2.MultiplyScalar(4)).Add(p3).DivideScalar(6),
+		}
+		u0, u1 := float64(i)/float64(spans), float64(i+1)/float64(spans)
+		ret[i] = newCurve(control, lerp(width0, width1, u0), lerp(width0, width1, u1), u0, u1, shape, mat)
+	}
+	return ret
+}
+
+func newCurve(control [4]Vector3, width0 float64, width1 float64, uMin float64, uMax float64, shape CurveShape, mat Material) curve {
+	// Split the curve finely enough that each piece is within a
+	// twentieth of its width of a straight line.
+	l0 := 0.0
+	for i := 0; i < 2; i++ {
+		l0 = math.Max(l0, control[i].Subtract(control[i+1].MultiplyScalar(2)).Add(control[i+2]).Length())
+	}
+	eps := math.Max(width0, width1) * 0.05
+	depth := 0
+	if l0 > 0 && eps > 0 {
+		depth = int(clamp(math.Ceil(math.Log2(math.Sqrt2*6*l0/(8*eps))/2), 0, 10))
+	}
+	return curve{control, width0, width1, uMin, uMax, shape, mat, depth}
+}
+
+func lerp(a float64, b float64, t float64) float64 {
+	return a*(1-t) + b*t
+}
+
+// bezier returns the point at t along a cubic Bézier curve, and its
+// derivative.
+func bezier(cp [4]Vector3, t float64) (Vector3, Vector3) {
+	b, db := bernstein(t)
+	var p, dp Vector3
+	for i, c := range cp {
+		p = p.Add(c.MultiplyScalar(b[i]))
+		dp = dp.Add(c.MultiplyScalar(db[i]))
+	}
+	return p, dp
+}
+
+// splitBezier splits a cubic Bézier curve in half.
+func splitBezier(cp [4]Vector3) ([4]Vector3, [4]Vector3) {
+	mid := func(a Vector3, b Vector3) Vector3 { return a.Add(b).MultiplyScalar(0.5) }
+	p01, p12, p23 := mid(cp[0], cp[1]), mid(cp[1], cp[2]), mid(cp[2], cp[3])
+	p012, p123 := mid(p01, p12), mid(p12, p23)
+	p0123 := mid(p012, p123)
+	return [4]Vector3{cp[0], p01, p012, p0123}, [4]Vector3{p0123, p123, p23, cp[3]}
+}
+
+// Hit follows pbrt: the curve is moved into a space where the ray runs
+// from the origin along +Z, then split in half until the pieces are
+// nearly straight, skipping pieces whose bounds the ray misses.  Each
+// remaining piece is hit if it passes within half its width of the
+// ray.
+func (c curve) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	r.countIntersectionTest()
+	length := r.Direction.Length()
+	z := r.Direction.DivideScalar(length)
+	x, y := orthonormalBasis(z)
+	var cp [4]Vector3
+	for i, p := range c.Control {
+		d := p.Subtract(r.Origin)
+		cp[i] = Vector3{d.Dot(x), d.Dot(y), d.Dot(z)}
+	}
+	return c.hitPiece(r, [3]Vector3{x, y, z}, cp, 0, 1, c.MaxDepth, tMin*length, tMax*length)
+}
+
+// hitPiece intersects the piece of the curve from w0 to w1, whose
+// control points in ray space are cp, between distances zMin and zMax
+// along the ray.
+func (c curve) hitPiece(r Ray, frame [3]Vector3, cp [4]Vector3, w0 float64, w1 float64, depth int, zMin float64, zMax float64) *HitRecord {
+	halfWidth := math.Max(lerp(c.Width0, c.Width1, w0), lerp(c.Width0, c.Width1, w1)) / 2
+	lo := minVector(minVector(cp[0], cp[1]), minVector(cp[2], cp[3])).SubtractScalar(halfWidth)
+	hi := maxVector(maxVector(cp[0], cp[1]), maxVector(cp[2], cp[3])).AddScalar(halfWidth)
+	if lo.X > 0 || hi.X < 0 || lo.Y > 0 || hi.Y < 0 || hi.Z < zMin || lo.Z > zMax {
+		return nil
+	}
+
+	if depth > 0 {
+		a, b := splitBezier(cp)
+		wm := (w0 + w1) / 2
+		closest := c.hitPiece(r, frame, a, w0, wm, depth-1, zMin, zMax)
+		if closest != nil {
+			zMax = closest.T * r.Direction.Length()
+		}
+		if hr := c.hitPiece(r, frame, b, wm, w1, depth-1, zMin, zMax); hr != nil {
+			closest = hr
+		}
+		return closest
+	}
+
+	// The ray must pass between the planes square to the curve at
+	// each end, so neighbouring pieces do not both claim it.
+	if (cp[1].Y-cp[0].Y)*-cp[0].Y+cp[0].X*(cp[0].X-cp[1].X) < 0 {
+		return nil
+	}
+	if (cp[2].Y-cp[3].Y)*-cp[3].Y+cp[3].X*(cp[3].X-cp[2].X) < 0 {
+		return nil
+	}
+
+	// Find where the piece passes nearest the ray, treating it as
+	// a straight line.
+	seg := Vector3{cp[3].X - cp[0].X, cp[3].Y - cp[0].Y, 0}
+	denom := seg.LengthSquared()
+	if denom == 0 {
+		return nil
+	}
+	w := clamp(-(cp[0].X*seg.X+cp[0].Y*seg.Y)/denom, 0, 1)
+	pc, dpcdw := bezier(cp, w)
+	u := lerp(w0, w1, w)
+	width := lerp(c.Width0, c.Width1, u)
+	if pc.X*pc.X+pc.Y*pc.Y > width*width/4 || pc.Z < zMin || pc.Z > zMax {
+		return nil
+	}
+
+	// How far across the curve the ray passes, from -1/2 to 1/2.
+	perp := Vector3{-dpcdw.Y, dpcdw.X, 0}.Normalize()
+	across := -(pc.X*perp.X + pc.Y*perp.Y) / width
+
+	t := pc.Z / r.Direction.Length()
+	_, dpdu := bezier(c.Control, u)
+	hr := &HitRecord{
+		T:        t,
+		P:        r.Point(t),
+		U:        lerp(c.UMin, c.UMax, u),
+		V:        0.5 + across,
+		DPDU:     dpdu.DivideScalar(c.UMax - c.UMin),
+		Material: c.Material,
+	}
+
+	x, y, z := frame[0], frame[1], frame[2]
+	normal := z.Neg()
+	if c.Shape == CurveTube {
+		// Bend the normal around the curve as if across a round
+		// cross section.
+		tangent := dpdu.Normalize()
+		side := x.MultiplyScalar(perp.X).Add(y.MultiplyScalar(perp.Y))
+		view := normal.Subtract(tangent.MultiplyScalar(normal.Dot(tangent))).Normalize()
+		sinTheta := clamp(2*across, -1, 1)
+		cosTheta := math.Sqrt(1 - sinTheta*sinTheta)
+		normal = side.MultiplyScalar(sinTheta).Add(view.MultiplyScalar(cosTheta))
+	}
+	hr.SetFaceNormal(r, normal)
+	return hr
+}
+
+func (c curve) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	lo := minVector(minVector(c.Control[0], c.Control[1]), minVector(c.Control[2], c.Control[3]))
+	hi := maxVector(maxVector(c.Control[0], c.Control[1]), maxVector(c.Control[2], c.Control[3]))
+	halfWidth := math.Max(c.Width0, c.Width1) / 2
+	return aabb{lo.SubtractScalar(halfWidth), hi.AddScalar(halfWidth)}, true
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCurve_Hit(t *testing.T) {
+	// Evenly spaced control points make U proportional to X.
+	straight := [4]Vector3{{-2, 0, 0}, {-2.0 / 3, 0, 0}, {2.0 / 3, 0, 0}, {2, 0, 0}}
+	arch := [4]Vector3{{-2, 0, 0}, {-1, 2, 0}, {1, 2, 0}, {2, 0, 0}}
+	down := func(x float64, z float64) Ray {
+		return NewRay(Vector3{x, 5, z}, Vector3{0, -2, 0}, 0)
+	}
+	tests := []struct {
+		name       string
+		obj        Hittable
+		r          Ray
+		wantT      float64
+		wantNormal Vector3
+		wantU      float64
+		wantV      float64
+	}{
+		{"ribbon", NewCurve(straight, 0.5, 0.5, CurveRibbon, nil), down(0, 0.1), 2.5, Vector3{0, 1, 0}, 0.5, 0.7},
+		{"ribbon misses", NewCurve(straight, 0.5, 0.5, CurveRibbon, nil), down(0, 0.3), -1, Vector3{}, 0, 0},
+		{"ribbon past the end", NewCurve(straight, 0.5, 0.5, CurveRibbon, nil), down(2.1, 0), -1, Vector3{}, 0, 0},
+		{"tube", NewCurve(straight, 0.5, 0.5, CurveTube, nil), down(1, -0.125), 2.5, Vector3{0, math.Sqrt(3) / 2, -0.5}, 0.75, 0.25},
+		{"arch top", NewCurve(arch, 0.2, 0.2, CurveRibbon, nil), down(0, 0), 1.75, Vector3{0, 1, 0}, 0.5, 0.5},
+		{"narrow end misses", NewCurve(straight, 0.1, 1, CurveRibbon, nil), down(-1.9, 0.2), -1, Vector3{}, 0, 0},
+		{"wide end", NewCurve(straight, 0.1, 1, CurveRibbon, nil), down(1.9, 0.2), 2.5, Vector3{0, 1, 0}, 0.975, 0.5 + 0.2/0.9775},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := tt.obj.Hit(tt.r, 0.001, math.Inf(1))
+			if tt.wantT < 0 {
+				if hr != nil {
+					t.Fatalf("Hit() = %v, want miss", hr.P)
+				}
+				return
+			}
+			if hr == nil {
+				t.Fatalf("Hit() missed, want %v", tt.wantT)
+			}
+			if math.Abs(hr.T-tt.wantT) > 1e-3 {
+				t.Errorf("Hit().T = %v, want %v", hr.T, tt.wantT)
+			}
+			if hr.Normal.Subtract(tt.wantNormal).Length() > 1e-3 {
+				t.Errorf("Hit().Normal = %v, want %v", hr.Normal, tt.wantNormal)
+			}
+			if math.Abs(hr.U-tt.wantU) > 1e-3 || math.Abs(hr.V-tt.wantV) > 1e-3 {
+				t.Errorf("Hit() UV = %v, %v, want %v, %v", hr.U, hr.V, tt.wantU, tt.wantV)
+			}
+			if hr.DPDU.Normalize().Subtract(Vector3{1, 0, 0}).Length() > 1e-3 {
+				t.Errorf("Hit().DPDU = %v, want along the curve", hr.DPDU)
+			}
+		})
+	}
+}
+
+func TestNewBSpline(t *testing.T) {
+	var points []Vector3
+	for i := 0; i < 7; i++ {
+		points = append(points, Vector3{float64(i), 0, 0})
+	}
+	spans := NewBSpline(points, 0.2, 0.2, CurveRibbon, nil)
+	if len(spans) != 4 {
+		t.Fatalf("NewBSpline() = %d spans, want 4", len(spans))
+	}
+	// The strand runs from 1 to 5, so U is a quarter along at 2.
+	hr := NewBVH(spans, 0, 1).Hit(NewRay(Vector3{2.01, 1, 0}, Vector3{0, -1, 0}, 0), 0.001, math.Inf(1))
+	if hr == nil {
+		t.Fatal("Hit() missed")
+	}
+	if math.Abs(hr.U-0.2525) > 1e-6 {
+		t.Errorf("Hit().U = %v, want 0.2525", hr.U)
+	}
+}
+
+func TestHairMaterial_Scatter(t *testing.T) {
+	hr := &HitRecord{P: Vector3{}, Normal: Vector3{0, 1, 0}, DPDU: Vector3{3, 0, 0}}
+	in := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+	smp := NewIndependentSampler(1)
+	shiny := NewHairMaterial(Vector3{}, Vector3{1, 1, 1}, 1e12)
+	for i := 0; i < 100; i++ {
+		ok, out, _ := shiny.Scatter(in, hr, smp)
+		if !ok {
+			t.Fatal("Scatter() absorbed")
+		}
+		d := out.Direction.Normalize()
+		// The highlight keeps the angle to the fiber.
+		if math.Abs(d.X-math.Sqrt2/2) > 1e-4 || d.Y < -1e-9 {
+			t.Fatalf("Scatter() = %v, want on the cone at 45 degrees, above the fiber", d)
+		}
+	}
+	matt := NewHairMaterial(Vector3{0.5, 0.5, 0.5}, Vector3{}, 10)
+	for i := 0; i < 100; i++ {
+		_, out, attenuation := matt.Scatter(in, hr, smp)
+		d := out.Direction.Normalize()
+		if want := 0.5 * math.Sqrt(1-d.X*d.X); math.Abs(attenuation.X-want) > 1e-9 {
+			t.Fatalf("Scatter() toward %v = %v, want %v", d, attenuation, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type cylinder struct {
+	Base     Vector3
+	Radius   float64
+	Height   float64
+	PhiMax   float64
+	Material Material
+}
+
+// NewCylinder returns an open tube around the Y axis, from base up to
+// height, swept phiMax degrees around from +X towards +Z.
+func NewCylinder(base Vector3, radius float64, height float64, phiMax float64, mat Material) Hittable {
+	return cylinder{base, radius, height, sweepRadians(phiMax), mat}
+}
+
+// NewCappedCylinder returns a closed cylinder around the Y axis, from
+// base up to height.
+func NewCappedCylinder(base Vector3, radius float64, height float64, mat Material) Solid {
+	top := base.Add(Vector3{Y: height})
+	return closedSurface{
+		Parts: []crosser{
+			cylinder{base, radius, height, 2 * math.Pi, mat},
+			disk{base, radius, 0, 2 * math.Pi, true, mat},
+			disk{top, radius, 0, 2 * math.Pi, false, mat},
+		},
+		Box: aabb{base.Subtract(Vector3{radius, 0, radius}), top.Add(Vector3{radius, 0, radius})},
+	}
+}
+
+func (c cylinder) crossings(r Ray) []*HitRecord {
+	r.countIntersectionTest()
+	o := r.Origin.Subtract(c.Base)
+	d := r.Direction
+	a := d.X*d.X + d.Z*d.Z
+	bHalf := o.X*d.X + o.Z*d.Z
+	cc := o.X*o.X + o.Z*o.Z - c.Radius*c.Radius
+	discriminant := bHalf*bHalf - a*cc
+	if a == 0 || discriminant < 0 {
+		return nil
+	}
+	sqrtd := math.Sqrt(discriminant)
+
+	var ret []*HitRecord
+	for _, t := range [2]float64{(-bHalf - sqrtd) / a, (-bHalf + sqrtd) / a} {
+		p := o.Add(d.MultiplyScalar(t))
+		phi := sweepAngle(p.X, p.Z)
+		if p.Y < 0 || p.Y > c.Height || phi > c.PhiMax {
+			continue
+		}
+		ret = append(ret, &HitRecord{
+			T:        t,
+			P:        p.Add(c.Base),
+			Normal:   Vector3{p.X / c.Radius, 0, p.Z / c.Radius},
+			U:        phi / c.PhiMax,
+			V:        p.Y / c.Height,
+			DPDU:     Vector3{-p.Z, 0, p.X}.MultiplyScalar(c.PhiMax),
+			DPDV:     Vector3{0, c.Height, 0},
+			Material: c.Material,
+		})
+	}
+	return ret
+}
+
+func (c cylinder) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return firstCrossing(r, c.crossings(r), tMin, tMax)
+}
+
+func (c cylinder) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return aabb{
+		c.Base.Subtract(Vector3{c.Radius, 0, c.Radius}),
+		c.Base.Add(Vector3{c.Radius, c.Height, c.Radius}),
+	}, true
+}
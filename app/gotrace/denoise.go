@@ -0,0 +1,225 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// DenoiseBuffers holds a linear image and the features which guide
+// denoising it, each stored row by row from the top of the image.  Any
+// of the features may be nil, and are then not used.
+type DenoiseBuffers struct {
+	Width  int
+	Height int
+	Color  []Vector3
+	Albedo []Vector3
+	Normal []Vector3
+	Depth  []float64
+}
+
+// NewDenoiseBuffers returns the buffers for a rendered frame, taking
+// whichever features its AOVs include.
+func NewDenoiseBuffers(frame *Frame) *DenoiseBuffers {
+	b := frame.Film.Bounds
+	ret := &DenoiseBuffers{
+		Width:  b.Dx(),
+		Height: b.Dy(),
+		Color:  make([]Vector3, 0, b.Dx()*b.Dy()),
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			ret.Color = append(ret.Color, frame.Film.Color(x, y))
+		}
+	}
+	if frame.AOVs == nil {
+		return ret
+	}
+	for _, a := range frame.AOVs.AOVs {
+		switch a {
+		case AOVAlbedo:
+			ret.Albedo = aovBuffer(frame.AOVs, a)
+		case AOVNormal:
+			ret.Normal = aovBuffer(frame.AOVs, a)
+		case AOVDepth:
+			depth := aovBuffer(frame.AOVs, a)
+			ret.Depth = make([]float64, len(depth))
+			for i, d := range depth {
+				ret.Depth[i] = d.X
+			}
+		}
+	}
+	return ret
+}
+
+func aovBuffer(f *AOVFilm, a AOV) []Vector3 {
+	ret := make([]Vector3, 0, f.Bounds.Dx()*f.Bounds.Dy())
+	for y := f.Bounds.Min.Y; y < f.Bounds.Max.Y; y++ {
+		for x := f.Bounds.Min.X; x < f.Bounds.Max.X; x++ {
+			ret = append(ret, f.Value(a, x, y))
+		}
+	}
+	return ret
+}
+
+// Image returns the gamma corrected 8-bit image.
+func (b *DenoiseBuffers) Image() *image.NRGBA {
+	im := image.NewNRGBA(image.Rect(0, 0, b.Width, b.Height))
+	for i, c := range b.Color {
+		c = c.Gamma2().Clamp(0, 0.999).MultiplyScalar(256)
+		im.Pix[i*4] = uint8(c.X)
+		im.Pix[i*4+1] = uint8(c.Y)
+		im.Pix[i*4+2] = uint8(c.Z)
+		im.Pix[i*4+3] = 0xff
+	}
+	return im
+}
+
+// EXRChannels returns the linear color as R, G, and B.
+func (b *DenoiseBuffers) EXRChannels() []EXRChannel {
+	n := len(b.Color)
+	r, g, bl := make([]float32, n), make([]float32, n), make([]float32, n)
+	for i, c := range b.Color {
+		r[i], g[i], bl[i] = float32(c.X), float32(c.Y), float32(c.Z)
+	}
+	return []EXRChannel{{"R", r}, {"G", g}, {"B", bl}}
+}
+
+// Denoiser is an edge-avoiding à-trous wavelet filter, after Dammertz
+// et al., "Edge-Avoiding À-Trous Wavelet Transform for fast Global
+// Illumination Filtering".  Each pass blurs with a 5x5 B-spline kernel
+// whose taps are spread twice as far apart as the pass before, and
+// each tap is weighted down by how much its color and features differ
+// from the center pixel's, so edges in the features are kept sharp.
+//
+// Texture is kept sharp by dividing the color by the albedo before
+// filtering and multiplying it back afterwards.
+type Denoiser struct {
+	Iterations  int
+	SigmaColor  float64
+	SigmaAlbedo float64
+	SigmaNormal float64
+	// SigmaDepth is relative to the depth of the center pixel, per
+	// pixel of distance.
+	SigmaDepth float64
+}
+
+// NewDenoiser returns a denoiser with settings which suit the default
+// scene at a few dozen samples per pixel.
+func NewDenoiser(iterations int) Denoiser {
+	return Denoiser{
+		Iterations:  iterations,
+		SigmaColor:  0.6,
+		SigmaAlbedo: 0.1,
+		SigmaNormal: 0.2,
+		SigmaDepth:  0.02,
+	}
+}
+
+var atrousKernel = [5]float64{1.0 / 16, 1.0 / 4, 3.0 / 8, 1.0 / 4, 1.0 / 16}
+
+// albedoFloor keeps dark albedo from blowing up the demodulated color.
+const albedoFloor = 0.01
+
+// Denoise returns a copy of b with its color denoised.
+func (d Denoiser) Denoise(b *DenoiseBuffers) *DenoiseBuffers {
+	color := make([]Vector3, len(b.Color))
+	for i, c := range b.Color {
+		color[i] = c.Divide(d.modulation(b, i))
+	}
+
+	next := make([]Vector3, len(color))
+	sigmaColor := d.SigmaColor
+	for i := 0; i < d.Iterations; i++ {
+		d.pass(b, color, next, 1<<i, sigmaColor)
+		color, next = next, color
+		sigmaColor /= 2
+	}
+
+	ret := *b
+	ret.Color = color
+	for i := range color {
+		color[i] = color[i].Multiply(d.modulation(b, i))
+	}
+	return &ret
+}
+
+// modulation returns the albedo the color of pixel i is divided by
+// while filtering.
+func (d Denoiser) modulation(b *DenoiseBuffers, i int) Vector3 {
+	if b.Albedo == nil {
+		return Vector3{1, 1, 1}
+	}
+	a := b.Albedo[i]
+	return Vector3{
+		math.Max(a.X, albedoFloor),
+		math.Max(a.Y, albedoFloor),
+		math.Max(a.Z, albedoFloor),
+	}
+}
+
+func (d Denoiser) pass(b *DenoiseBuffers, in []Vector3, out []Vector3, step int, sigmaColor float64) {
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			p := y*b.Width + x
+			sum := Vector3{}
+			weights := 0.0
+			for j := -2; j <= 2; j++ {
+				qy := y + j*step
+				if qy < 0 || qy >= b.Height {
+					continue
+				}
+				for i := -2; i <= 2; i++ {
+					qx := x + i*step
+					if qx < 0 || qx >= b.Width {
+						continue
+					}
+					q := qy*b.Width + qx
+					w := atrousKernel[i+2] * atrousKernel[j+2] * d.weight(b, in, p, q, step, sigmaColor)
+					sum = sum.Add(in[q].MultiplyScalar(w))
+					weights += w
+				}
+			}
+			// The center tap always has a weight of at least the
+			// kernel's, so this never divides by zero.
+			out[p] = sum.DivideScalar(weights)
+		}
+	}
+}
+
+// weight returns how much pixel q is trusted to look like pixel p.
+func (d Denoiser) weight(b *DenoiseBuffers, color []Vector3, p int, q int, step int, sigmaColor float64) float64 {
+	e := color[p].Subtract(color[q]).LengthSquared() / (sigmaColor * sigmaColor)
+	if b.Albedo != nil {
+		e += b.Albedo[p].Subtract(b.Albedo[q]).LengthSquared() / (d.SigmaAlbedo * d.SigmaAlbedo)
+	}
+	if b.Normal != nil {
+		e += b.Normal[p].Subtract(b.Normal[q]).LengthSquared() / (d.SigmaNormal * d.SigmaNormal)
+	}
+	if b.Depth != nil {
+		zp, zq := b.Depth[p], b.Depth[q]
+		switch {
+		case math.IsInf(zp, 1) && math.IsInf(zq, 1):
+		case math.IsInf(zp, 1) || math.IsInf(zq, 1):
+			return 0
+		default:
+			e += math.Abs(zp-zq) / (d.SigmaDepth*zp*float64(step) + 1e-6)
+		}
+	}
+	return math.Exp(-e)
+}
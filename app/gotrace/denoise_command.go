@@ -0,0 +1,141 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// denoiseCommand denoises an image rendered earlier, from an OpenEXR
+// file holding its linear color as R, G, and B and, optionally, the
+// albedo, normal, and depth AOVs.  The AOVs may instead come from a
+// separate OpenEXR file.
+func denoiseCommand(args []string) error {
+	fs := flag.NewFlagSet("denoise", flag.ExitOnError)
+	iterations := fs.Int("iterations", 5, "number of denoising passes, each reaching twice as far as the last")
+	features := fs.String("features", "", "OpenEXR file holding the albedo, normal, and depth AOVs, if not in the input")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s denoise [flags] input.exr output.png|output.exr\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	width, height, channels, err := readEXRFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *features != "" {
+		fw, fh, more, err := readEXRFile(*features)
+		if err != nil {
+			return err
+		}
+		if fw != width || fh != height {
+			return fmt.Errorf("%s is %dx%d, but %s is %dx%d", *features, fw, fh, fs.Arg(0), width, height)
+		}
+		channels = append(channels, more...)
+	}
+	b, err := denoiseBuffersFromEXR(width, height, channels)
+	if err != nil {
+		return err
+	}
+	if b.Albedo == nil && b.Normal == nil && b.Depth == nil {
+		return errors.New("no albedo, normal, or depth AOVs to guide denoising")
+	}
+
+	b = NewDenoiser(*iterations).Denoise(b)
+	output := fs.Arg(1)
+	switch strings.ToLower(filepath.Ext(output)) {
+	case ".exr":
+		return writeFile(output, func(w io.Writer) error {
+			return WriteEXR(w, width, height, b.EXRChannels())
+		})
+	case ".png":
+		return writePNG(output, b.Image())
+	default:
+		return fmt.Errorf("cannot tell the format of %s, use .png or .exr", output)
+	}
+}
+
+func readEXRFile(filename string) (int, int, []EXRChannel, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer f.Close()
+	width, height, channels, err := ReadEXR(f)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return width, height, channels, nil
+}
+
+// denoiseBuffersFromEXR gathers the color and whichever features are
+// present from OpenEXR channels named as WriteEXR names them.
+func denoiseBuffersFromEXR(width int, height int, channels []EXRChannel) (*DenoiseBuffers, error) {
+	byName := map[string][]float32{}
+	for _, c := range channels {
+		byName[c.Name] = c.Pixels
+	}
+	vectors := func(names ...string) []Vector3 {
+		for _, name := range names {
+			if byName[name] == nil {
+				return nil
+			}
+		}
+		ret := make([]Vector3, width*height)
+		for i := range ret {
+			ret[i] = Vector3{
+				float64(byName[names[0]][i]),
+				float64(byName[names[1]][i]),
+				float64(byName[names[2]][i]),
+			}
+		}
+		return ret
+	}
+
+	ret := &DenoiseBuffers{
+		Width:  width,
+		Height: height,
+		Color:  vectors("R", "G", "B"),
+		Albedo: vectors("albedo.R", "albedo.G", "albedo.B"),
+		Normal: vectors("normal.X", "normal.Y", "normal.Z"),
+	}
+	if ret.Color == nil {
+		return nil, errors.New("no R, G, and B channels to denoise")
+	}
+	if depth := byName["depth.Z"]; depth != nil {
+		ret.Depth = make([]float64, width*height)
+		for i, d := range depth {
+			ret.Depth[i] = float64(d)
+			if math.IsInf(ret.Depth[i], 0) || math.IsNaN(ret.Depth[i]) {
+				ret.Depth[i] = math.Inf(1)
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func variance(values []Vector3) float64 {
+	mean := Vector3{}
+	for _, v := range values {
+		mean = mean.Add(v)
+	}
+	mean = mean.DivideScalar(float64(len(values)))
+	sum := 0.0
+	for _, v := range values {
+		sum += v.Subtract(mean).LengthSquared()
+	}
+	return sum / float64(len(values))
+}
+
+// A noisy image of two flat walls meeting in a vertical edge should be
+// smoothed within each wall, without the walls bleeding into each other.
+func TestDenoiser_SmoothsButKeepsEdges(t *testing.T) {
+	const width, height = 32, 16
+	b := &DenoiseBuffers{Width: width, Height: height}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			base, normal := Vector3{0.2, 0.2, 0.2}, Vector3{1, 0, 0}
+			if x >= width/2 {
+				base, normal = Vector3{0.8, 0.8, 0.8}, Vector3{0, 0, 1}
+			}
+			noise := (rand.Float64() - 0.5) * 0.2
+			b.Color = append(b.Color, base.AddScalar(noise))
+			b.Albedo = append(b.Albedo, Vector3{0.5, 0.5, 0.5})
+			b.Normal = append(b.Normal, normal)
+			b.Depth = append(b.Depth, 10)
+		}
+	}
+
+	got := NewDenoiser(5).Denoise(b)
+
+	left := func(colors []Vector3) []Vector3 {
+		ret := []Vector3{}
+		for y := 0; y < height; y++ {
+			ret = append(ret, colors[y*width:y*width+width/2]...)
+		}
+		return ret
+	}
+	if before, after := variance(left(b.Color)), variance(left(got.Color)); after > before/4 {
+		t.Errorf("variance went from %v to %v, want it at least quartered", before, after)
+	}
+	for y := 0; y < height; y++ {
+		l, r := got.Color[y*width+width/2-1], got.Color[y*width+width/2]
+		if l.X > 0.3 || r.X < 0.7 {
+			t.Errorf("edge at row %d blurred to %v, %v", y, l.X, r.X)
+		}
+	}
+}
@@ -16,19 +16,30 @@
 
 package main
 
-import (
-	"math"
-	"math/rand"
-)
+import "math"
 
-// DielectricMaterial defines a matt material.
+// DielectricMaterial is the smooth surface of a transparent medium,
+// such as glass or water, which both reflects and refracts.
 type DielectricMaterial struct {
-	indexOfRefraction float64
+	medium Medium
 }
 
-// NewDielectricMaterial returns a new material.
+// NewDielectricMaterial returns a new clear material.
 func NewDielectricMaterial(indexOfRefraction float64) DielectricMaterial {
-	return DielectricMaterial{indexOfRefraction: indexOfRefraction}
+	return DielectricMaterial{medium: Medium{IOR: indexOfRefraction}}
+}
+
+// NewDispersiveDielectricMaterial returns a clear material whose index
+// of refraction depends on wavelength, such as BK7 glass or Diamond.
+// Outside spectral mode, the index at the helium d line is used.
+func NewDispersiveDielectricMaterial(dispersion Dispersion) DielectricMaterial {
+	return DielectricMaterial{medium: Medium{IOR: dispersion.IOR(referenceWavelength), Dispersion: dispersion}}
+}
+
+// NewMediumDielectricMaterial returns the surface of the medium, which
+// may be colored by absorption, or nested within others.
+func NewMediumDielectricMaterial(medium Medium) DielectricMaterial {
+	return DielectricMaterial{medium: medium}
 }
 
 func refract(uv Vector3, n Vector3, etaiOverEtat float64, cosTheta float64) Vector3 {
@@ -44,21 +55,44 @@ func reflectance(cosine float64, refIndex float64) float64 {
 	return r0 + (1-r0)*math.Pow(1-cosine, 5)
 }
 
-// Scatter calculates how rays should scatter from this material.
-func (m DielectricMaterial) Scatter(r Ray, hr *HitRecord) (bool, Ray, Vector3) {
-	refractionRatio := m.indexOfRefraction
+// Scatter calculates how rays should scatter from this material.  The
+// ray bends by the ratio of the indexes of refraction of the media on
+// either side, so water in a glass bends light less where they meet
+// than where either meets the air.
+func (m DielectricMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	outside, outsideDispersive := r.interior.without(m.medium).ior(r.wavelengths)
+	inside := m.medium.iorFor(r.wavelengths)
+	refractionRatio := inside / outside
 	if hr.FrontFace {
-		refractionRatio = 1.0 / m.indexOfRefraction
+		refractionRatio = outside / inside
+	}
+	// Where the index depends on wavelength, the other wavelengths
+	// would go elsewhere, so only the hero carries on.
+	wavelengths := r.wavelengths
+	if outsideDispersive || m.medium.dispersive(r.wavelengths) {
+		wavelengths = wavelengths.collapse()
 	}
 	unitDirection := r.Direction.Normalize()
 	cosTheta := math.Min(unitDirection.Neg().Dot(hr.Normal), 1.0)
 	sinTheta := math.Sqrt(1.0 - cosTheta*cosTheta)
 	cannotRefract := refractionRatio*sinTheta > 1.0
-	var direction Vector3
-	if cannotRefract || reflectance(cosTheta, refractionRatio) > rand.Float64() {
-		direction = reflectRay(unitDirection, hr.Normal)
+	var scattered Ray
+	if cannotRefract || reflectance(cosTheta, refractionRatio) > smp.Get1D() {
+		scattered = r.Spawn(hr.P, reflectRay(unitDirection, hr.Normal))
 	} else {
-		direction = refract(unitDirection, hr.Normal, refractionRatio, cosTheta)
+		scattered = r.Spawn(hr.P, refract(unitDirection, hr.Normal, refractionRatio, cosTheta))
+		scattered.interior = r.interior.cross(m.medium, hr.FrontFace)
 	}
-	return true, Ray{hr.P, direction, r.Time}, Vector3{1, 1, 1}
+	scattered.wavelengths = wavelengths
+	return true, scattered, Vector3{1, 1, 1}
+}
+
+// Interior returns the medium inside the surface.
+func (m DielectricMaterial) Interior() Medium {
+	return m.medium
+}
+
+// Albedo returns white, since the surface itself absorbs nothing.
+func (m DielectricMaterial) Albedo(hr *HitRecord) Vector3 {
+	return Vector3{1, 1, 1}
 }
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type disk struct {
+	Center      Vector3
+	Radius      float64
+	InnerRadius float64
+	PhiMax      float64
+	// Down turns the disk to face -Y, as the bottom of a closed shape.
+	Down     bool
+	Material Material
+}
+
+// NewDisk returns a flat disk facing +Y, swept phiMax degrees around
+// from +X towards +Z.  An inner radius above zero makes it an annulus.
+func NewDisk(center Vector3, radius float64, innerRadius float64, phiMax float64, mat Material) Hittable {
+	return disk{center, radius, innerRadius, sweepRadians(phiMax), false, mat}
+}
+
+func (c disk) crossings(r Ray) []*HitRecord {
+	r.countIntersectionTest()
+	if r.Direction.Y == 0 {
+		return nil
+	}
+	o := r.Origin.Subtract(c.Center)
+	t := -o.Y / r.Direction.Y
+	p := o.Add(r.Direction.MultiplyScalar(t))
+	rho := math.Hypot(p.X, p.Z)
+	phi := sweepAngle(p.X, p.Z)
+	if rho > c.Radius || rho < c.InnerRadius || phi > c.PhiMax {
+		return nil
+	}
+	normal := Vector3{0, 1, 0}
+	if c.Down {
+		normal.Y = -1
+	}
+	return []*HitRecord{{
+		T:        t,
+		P:        Vector3{p.X, 0, p.Z}.Add(c.Center),
+		Normal:   normal,
+		U:        phi / c.PhiMax,
+		V:        (c.Radius - rho) / (c.Radius - c.InnerRadius),
+		DPDU:     Vector3{-p.Z, 0, p.X}.MultiplyScalar(c.PhiMax),
+		DPDV:     Vector3{p.X, 0, p.Z}.MultiplyScalar(-(c.Radius - c.InnerRadius) / math.Max(rho, 1e-12)),
+		Material: c.Material,
+	}}
+}
+
+func (c disk) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return firstCrossing(r, c.crossings(r), tMin, tMax)
+}
+
+func (c disk) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	// Pad the flat side so the box has some thickness.
+	return aabb{
+		c.Center.Subtract(Vector3{c.Radius, 1e-4, c.Radius}),
+		c.Center.Add(Vector3{c.Radius, 1e-4, c.Radius}),
+	}, true
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// DispersionModel selects the formula for how a medium's index of
+// refraction changes with wavelength.
+type DispersionModel int
+
+// Supported dispersion models.
+const (
+	DispersionNone DispersionModel = iota
+	DispersionCauchy
+	DispersionSellmeier
+)
+
+// Dispersion describes how a medium's index of refraction changes with
+// wavelength, which splits white light into colors, as in a prism.  It
+// only shows in spectral mode.  Coefficients are for wavelengths in
+// micrometres, as they are usually published.
+type Dispersion struct {
+	Model DispersionModel
+	// Cauchy's equation: n = A + B / lambda^2.
+	A float64
+	B float64
+	// The Sellmeier equation:
+	// n^2 = 1 + sum of SellmeierB[i] lambda^2 / (lambda^2 - SellmeierC[i]).
+	SellmeierB [3]float64
+	SellmeierC [3]float64
+}
+
+// CauchyDispersion returns dispersion by Cauchy's equation.
+func CauchyDispersion(a float64, b float64) Dispersion {
+	return Dispersion{Model: DispersionCauchy, A: a, B: b}
+}
+
+// SellmeierDispersion returns dispersion by the Sellmeier equation.
+func SellmeierDispersion(b [3]float64, c [3]float64) Dispersion {
+	return Dispersion{Model: DispersionSellmeier, SellmeierB: b, SellmeierC: c}
+}
+
+// Dispersion of common clear materials.
+var (
+	// BK7 is the usual optical crown glass.
+	BK7 = SellmeierDispersion(
+		[3]float64{1.03961212, 0.231792344, 1.01046945},
+		[3]float64{0.00600069867, 0.0200179144, 103.560653},
+	)
+	// Diamond disperses strongly, which gives it its fire.
+	Diamond = SellmeierDispersion(
+		[3]float64{4.3356, 0.3306, 0},
+		[3]float64{0.1060 * 0.1060, 0.1750 * 0.1750, 0},
+	)
+)
+
+// referenceWavelength is the helium d line, at which an index of
+// refraction is quoted when only one is given.
+const referenceWavelength = 587.56
+
+// IOR returns the index of refraction at lambda, in nanometres.
+func (d Dispersion) IOR(lambda float64) float64 {
+	um := lambda / 1000
+	um2 := um * um
+	switch d.Model {
+	case DispersionCauchy:
+		return d.A + d.B/um2
+	case DispersionSellmeier:
+		n2 := 1.0
+		for i := range d.SellmeierB {
+			n2 += d.SellmeierB[i] * um2 / (um2 - d.SellmeierC[i])
+		}
+		return math.Sqrt(n2)
+	}
+	return 1
+}
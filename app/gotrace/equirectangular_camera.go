@@ -0,0 +1,70 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// EquirectangularCamera renders a full 360 degree panorama.  Longitude
+// runs across the image, with the view direction in the center, and
+// latitude runs up the image.  The image should be twice as wide as it
+// is tall.
+type EquirectangularCamera struct {
+	cameraBasis
+	panoramaEye
+}
+
+// NewEquirectangularCamera returns a panoramic camera centered on the
+// direction from lookFrom to lookAt.
+func NewEquirectangularCamera(lookFrom Vector3, lookAt Vector3, vup Vector3, time0 float64, time1 float64) EquirectangularCamera {
+	return EquirectangularCamera{
+		cameraBasis: newCameraBasis(lookFrom, lookAt, vup, time0, time1),
+	}
+}
+
+// direction returns the direction seen at s, t.
+func (c EquirectangularCamera) direction(s float64, t float64) Vector3 {
+	longitude := (s - 0.5) * 2 * math.Pi
+	latitude := (t - 0.5) * math.Pi
+	sinLon, cosLon := math.Sincos(longitude)
+	sinLat, cosLat := math.Sincos(latitude)
+	return c.u.MultiplyScalar(cosLat * sinLon).
+		Add(c.v.MultiplyScalar(sinLat)).
+		Subtract(c.w.MultiplyScalar(cosLat * cosLon))
+}
+
+// GetRay returns a ray from the camera's origin, or from the eye when
+// rendering stereo.
+func (c EquirectangularCamera) GetRay(s float64, t float64, smp Sampler) (bool, Ray) {
+	return true, c.ray(c.cameraBasis, smp, c.direction(s, t))
+}
+
+// Project returns where p appears on the panorama.
+func (c EquirectangularCamera) Project(p Vector3) (bool, float64, float64) {
+	d := p.Subtract(c.origin)
+	if NearZeroVector(d) {
+		return false, 0, 0
+	}
+	d = d.Normalize()
+	longitude := math.Atan2(d.Dot(c.u), -d.Dot(c.w))
+	latitude := math.Asin(clamp(d.Dot(c.v), -1, 1))
+	return true, longitude/(2*math.Pi) + 0.5, latitude/math.Pi + 0.5
+}
+
+func (c EquirectangularCamera) withEye(offset float64, convergenceDistance float64) Camera {
+	c.panoramaEye = panoramaEye{offset, convergenceDistance}
+	return c
+}
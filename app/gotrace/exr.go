@@ -0,0 +1,181 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+)
+
+// EXRChannel is one channel of an OpenEXR image, with rows running from
+// the top of the image down.  Layers are written by giving channels
+// dotted names, such as "depth.Z".
+type EXRChannel struct {
+	Name   string
+	Pixels []float32
+}
+
+var exrMagic = []byte{0x76, 0x2f, 0x31, 0x01}
+
+const (
+	exrUint  = 0
+	exrHalf  = 1
+	exrFloat = 2
+
+	exrVersion   = 2
+	exrTiled     = 0x200
+	exrLongNames = 0x400
+	exrDeep      = 0x800
+	exrMultiPart = 0x1000
+
+	exrNoCompression   = 0
+	exrZIPSCompression = 2
+	exrZIPCompression  = 3
+)
+
+func writeEXRAttribute(buf *bytes.Buffer, name string, kind string, value []byte) {
+	buf.WriteString(name)
+	buf.WriteByte(0)
+	buf.WriteString(kind)
+	buf.WriteByte(0)
+	binary.Write(buf, binary.LittleEndian, int32(len(value)))
+	buf.Write(value)
+}
+
+func exrBytes(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// WriteEXR writes an uncompressed, single part, scanline OpenEXR image
+// with 32-bit float channels.
+func WriteEXR(w io.Writer, width int, height int, channels []EXRChannel) error {
+	if len(channels) == 0 {
+		return errors.New("no channels to write")
+	}
+	sorted := make([]EXRChannel, len(channels))
+	copy(sorted, channels)
+	// Channels must be stored in alphabetical order.
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var buf bytes.Buffer
+	buf.Write(exrMagic)
+	flags := uint32(exrVersion)
+	var chlist bytes.Buffer
+	for _, c := range sorted {
+		if len(c.Pixels) != width*height {
+			return errors.New("channel " + c.Name + " is the wrong size")
+		}
+		if len(c.Name) > 31 {
+			flags |= exrLongNames
+		}
+		chlist.WriteString(c.Name)
+		chlist.WriteByte(0)
+		chlist.Write(exrBytes(int32(exrFloat), uint8(0), [3]uint8{}, int32(1), int32(1)))
+	}
+	chlist.WriteByte(0)
+	binary.Write(&buf, binary.LittleEndian, flags)
+
+	window := exrBytes(int32(0), int32(0), int32(width-1), int32(height-1))
+	writeEXRAttribute(&buf, "channels", "chlist", chlist.Bytes())
+	writeEXRAttribute(&buf, "compression", "compression", []byte{exrNoCompression})
+	writeEXRAttribute(&buf, "dataWindow", "box2i", window)
+	writeEXRAttribute(&buf, "displayWindow", "box2i", window)
+	writeEXRAttribute(&buf, "lineOrder", "lineOrder", []byte{0})
+	writeEXRAttribute(&buf, "pixelAspectRatio", "float", exrBytes(float32(1)))
+	writeEXRAttribute(&buf, "screenWindowCenter", "v2f", exrBytes(float32(0), float32(0)))
+	writeEXRAttribute(&buf, "screenWindowWidth", "float", exrBytes(float32(1)))
+	buf.WriteByte(0)
+
+	lineSize := len(sorted) * width * 4
+	offset := uint64(buf.Len() + height*8)
+	for y := 0; y < height; y++ {
+		binary.Write(&buf, binary.LittleEndian, offset)
+		offset += uint64(8 + lineSize)
+	}
+	row := make([]byte, 4*width)
+	for y := 0; y < height; y++ {
+		binary.Write(&buf, binary.LittleEndian, int32(y))
+		binary.Write(&buf, binary.LittleEndian, int32(lineSize))
+		for _, c := range sorted {
+			for x := 0; x < width; x++ {
+				binary.LittleEndian.PutUint32(row[4*x:], math.Float32bits(c.Pixels[y*width+x]))
+			}
+			buf.Write(row)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// EXRChannels returns the linear color of the film as R, G, and B.
+func (f *Film) EXRChannels() []EXRChannel {
+	n := f.Bounds.Dx() * f.Bounds.Dy()
+	r, g, b := make([]float32, 0, n), make([]float32, 0, n), make([]float32, 0, n)
+	for y := f.Bounds.Min.Y; y < f.Bounds.Max.Y; y++ {
+		for x := f.Bounds.Min.X; x < f.Bounds.Max.X; x++ {
+			c := f.Color(x, y)
+			r = append(r, float32(c.X))
+			g = append(g, float32(c.Y))
+			b = append(b, float32(c.Z))
+		}
+	}
+	return []EXRChannel{{"R", r}, {"G", g}, {"B", b}}
+}
+
+// EXRChannels returns a layer for each AOV, named after the AOV.
+func (f *AOVFilm) EXRChannels() []EXRChannel {
+	ret := []EXRChannel{}
+	for _, a := range f.AOVs {
+		ret = append(ret, f.EXRLayer(a)...)
+	}
+	return ret
+}
+
+// EXRLayer returns the channels of one AOV, such as "normal.X",
+// "normal.Y", and "normal.Z".
+func (f *AOVFilm) EXRLayer(a AOV) []EXRChannel {
+	n := f.Bounds.Dx() * f.Bounds.Dy()
+	names := aovChannels[a]
+	layer := make([][]float32, len(names))
+	for i := range layer {
+		layer[i] = make([]float32, 0, n)
+	}
+	for y := f.Bounds.Min.Y; y < f.Bounds.Max.Y; y++ {
+		for x := f.Bounds.Min.X; x < f.Bounds.Max.X; x++ {
+			v := f.Value(a, x, y)
+			parts := [3]float64{v.X, v.Y, v.Z}
+			for i := range names {
+				layer[i] = append(layer[i], float32(parts[i]))
+			}
+		}
+	}
+	ret := make([]EXRChannel, len(names))
+	for i, name := range names {
+		ret[i] = EXRChannel{a.String() + "." + name, layer[i]}
+	}
+	return ret
+}
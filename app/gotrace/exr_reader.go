@@ -0,0 +1,264 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+type exrChannelInfo struct {
+	name      string
+	pixelType int32
+}
+
+// ReadEXR reads a single part, scanline OpenEXR image which is either
+// uncompressed or ZIP compressed, as written by WriteEXR and by most
+// other tools.  Half and unsigned int channels are converted to float.
+func ReadEXR(r io.Reader) (width int, height int, channels []EXRChannel, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if len(data) < 8 || !bytes.Equal(data[:4], exrMagic) {
+		return 0, 0, nil, errors.New("not an OpenEXR file")
+	}
+	flags := binary.LittleEndian.Uint32(data[4:])
+	if flags&(exrTiled|exrDeep|exrMultiPart) != 0 {
+		return 0, 0, nil, errors.New("only single part scanline OpenEXR files are supported")
+	}
+
+	hdr := bytes.NewReader(data[8:])
+	var infos []exrChannelInfo
+	compression := -1
+	var window [4]int32
+	haveWindow := false
+	for {
+		name, err := readEXRString(hdr)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if name == "" {
+			break
+		}
+		if _, err := readEXRString(hdr); err != nil {
+			return 0, 0, nil, err
+		}
+		var size int32
+		if err := binary.Read(hdr, binary.LittleEndian, &size); err != nil {
+			return 0, 0, nil, err
+		}
+		if size < 0 || int(size) > hdr.Len() {
+			return 0, 0, nil, fmt.Errorf("attribute %q is truncated", name)
+		}
+		value := make([]byte, size)
+		hdr.Read(value)
+		switch name {
+		case "channels":
+			infos, err = parseEXRChannels(value)
+			if err != nil {
+				return 0, 0, nil, err
+			}
+		case "compression":
+			if len(value) != 1 {
+				return 0, 0, nil, errors.New("bad compression attribute")
+			}
+			compression = int(value[0])
+		case "dataWindow":
+			if err := binary.Read(bytes.NewReader(value), binary.LittleEndian, &window); err != nil {
+				return 0, 0, nil, err
+			}
+			haveWindow = true
+		}
+	}
+	if infos == nil || !haveWindow {
+		return 0, 0, nil, errors.New("missing channels or dataWindow")
+	}
+	linesPerBlock := 1
+	switch compression {
+	case exrNoCompression, exrZIPSCompression:
+	case exrZIPCompression:
+		linesPerBlock = 16
+	default:
+		return 0, 0, nil, fmt.Errorf("unsupported compression %d", compression)
+	}
+
+	width = int(window[2] - window[0] + 1)
+	height = int(window[3] - window[1] + 1)
+	if width <= 0 || height <= 0 {
+		return 0, 0, nil, errors.New("empty dataWindow")
+	}
+	lineSize := 0
+	for _, c := range infos {
+		lineSize += exrPixelSize(c.pixelType) * width
+	}
+	channels = make([]EXRChannel, len(infos))
+	for i, c := range infos {
+		channels[i] = EXRChannel{c.name, make([]float32, width*height)}
+	}
+
+	blocks := (height + linesPerBlock - 1) / linesPerBlock
+	offsets := make([]uint64, blocks)
+	if err := binary.Read(hdr, binary.LittleEndian, offsets); err != nil {
+		return 0, 0, nil, err
+	}
+	for _, offset := range offsets {
+		if offset+8 > uint64(len(data)) {
+			return 0, 0, nil, errors.New("scanline block is out of range")
+		}
+		y := int(int32(binary.LittleEndian.Uint32(data[offset:]))) - int(window[1])
+		size := uint64(binary.LittleEndian.Uint32(data[offset+4:]))
+		if offset+8+size > uint64(len(data)) || y < 0 || y >= height {
+			return 0, 0, nil, errors.New("scanline block is out of range")
+		}
+		lines := linesPerBlock
+		if y+lines > height {
+			lines = height - y
+		}
+		block := data[offset+8 : offset+8+size]
+		if want := lines * lineSize; len(block) < want {
+			if block, err = exrInflate(block, want); err != nil {
+				return 0, 0, nil, err
+			}
+		}
+		for line := 0; line < lines; line++ {
+			for i, c := range infos {
+				pixels := channels[i].Pixels[(y+line)*width:]
+				for x := 0; x < width; x++ {
+					pixels[x], block = exrPixel(c.pixelType, block)
+				}
+			}
+		}
+	}
+	return width, height, channels, nil
+}
+
+func readEXRString(r *bytes.Reader) (string, error) {
+	var s []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", errors.New("OpenEXR header is truncated")
+		}
+		if b == 0 {
+			return string(s), nil
+		}
+		s = append(s, b)
+	}
+}
+
+func parseEXRChannels(value []byte) ([]exrChannelInfo, error) {
+	r := bytes.NewReader(value)
+	ret := []exrChannelInfo{}
+	for {
+		name, err := readEXRString(r)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return ret, nil
+		}
+		var fields struct {
+			PixelType int32
+			Linear    uint8
+			Reserved  [3]uint8
+			XSampling int32
+			YSampling int32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &fields); err != nil {
+			return nil, err
+		}
+		if fields.PixelType < exrUint || fields.PixelType > exrFloat {
+			return nil, fmt.Errorf("channel %q has unknown pixel type %d", name, fields.PixelType)
+		}
+		if fields.XSampling != 1 || fields.YSampling != 1 {
+			return nil, fmt.Errorf("channel %q is subsampled", name)
+		}
+		ret = append(ret, exrChannelInfo{name, fields.PixelType})
+	}
+}
+
+func exrPixelSize(pixelType int32) int {
+	if pixelType == exrHalf {
+		return 2
+	}
+	return 4
+}
+
+func exrPixel(pixelType int32, b []byte) (float32, []byte) {
+	switch pixelType {
+	case exrHalf:
+		return halfToFloat32(binary.LittleEndian.Uint16(b)), b[2:]
+	case exrUint:
+		return float32(binary.LittleEndian.Uint32(b)), b[4:]
+	default:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), b[4:]
+	}
+}
+
+// exrInflate undoes ZIP compression: zlib, then a delta predictor, and
+// finally the two halves of the data are interleaved back together.
+func exrInflate(block []byte, size int) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(block))
+	if err != nil {
+		return nil, err
+	}
+	t := make([]byte, size)
+	if _, err := io.ReadFull(zr, t); err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(t); i++ {
+		t[i] = t[i-1] + t[i] - 128
+	}
+	ret := make([]byte, size)
+	half := (size + 1) / 2
+	for i := range ret {
+		if i%2 == 0 {
+			ret[i] = t[i/2]
+		} else {
+			ret[i] = t[half+i/2]
+		}
+	}
+	return ret, nil
+}
+
+// halfToFloat32 converts an IEEE 754 half precision float.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+	switch {
+	case exp == 0x1f:
+		// Infinity or NaN.
+		return math.Float32frombits(sign | 0xff<<23 | mant<<13)
+	case exp != 0:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	case mant == 0:
+		return math.Float32frombits(sign)
+	}
+	// Subnormal halves are normal floats.
+	f := float32(mant) / (1 << 24)
+	if sign != 0 {
+		return -f
+	}
+	return f
+}
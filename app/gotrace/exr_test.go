@@ -0,0 +1,138 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestWriteEXR(t *testing.T) {
+	var buf bytes.Buffer
+	channels := []EXRChannel{
+		{"R", []float32{1, 2, 3, 4, 5, 6}},
+		{"G", []float32{1, 2, 3, 4, 5, 6}},
+	}
+	if err := WriteEXR(&buf, 3, 2, channels); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if !bytes.Equal(b[:4], exrMagic) {
+		t.Errorf("magic = %v, want %v", b[:4], exrMagic)
+	}
+	// Each line is its y, its size, and the data; the last line ends
+	// the file.
+	lineSize := 8 + 2*3*4
+	end := len(b) - lineSize
+	if got := int(int32(binary.LittleEndian.Uint32(b[end:]))); got != 1 {
+		t.Errorf("last line y = %d, want 1", got)
+	}
+	if got := math.Float32frombits(binary.LittleEndian.Uint32(b[end+8:])); got != 4 {
+		t.Errorf("first value of the last line = %v, want 4 from G", got)
+	}
+
+	if err := WriteEXR(&buf, 4, 2, channels); err == nil {
+		t.Errorf("WriteEXR() with the wrong size succeeded")
+	}
+}
+
+func TestReadEXR_RoundTrip(t *testing.T) {
+	channels := []EXRChannel{
+		{"depth.Z", []float32{1, float32(math.Inf(1)), 3, 4, 5, 6}},
+		{"B", []float32{0.5, 0, 0, 0, 0, 1}},
+	}
+	var buf bytes.Buffer
+	if err := WriteEXR(&buf, 2, 3, channels); err != nil {
+		t.Fatal(err)
+	}
+	width, height, got, err := ReadEXR(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 2 || height != 3 {
+		t.Errorf("size = %dx%d, want 2x3", width, height)
+	}
+	want := []EXRChannel{channels[1], channels[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadEXR() = %v, want %v", got, want)
+	}
+}
+
+// zipEXRBlock compresses a block the way OpenEXR's ZIP compression does.
+func zipEXRBlock(raw []byte) []byte {
+	t := make([]byte, len(raw))
+	half := (len(raw) + 1) / 2
+	for i, b := range raw {
+		if i%2 == 0 {
+			t[i/2] = b
+		} else {
+			t[half+i/2] = b
+		}
+	}
+	for i := len(t) - 1; i > 0; i-- {
+		t[i] = t[i] - t[i-1] + 128
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(t)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestReadEXR_ZIPHalf(t *testing.T) {
+	var chlist bytes.Buffer
+	chlist.WriteString("Y")
+	chlist.WriteByte(0)
+	chlist.Write(exrBytes(int32(exrHalf), uint8(0), [3]uint8{}, int32(1), int32(1)))
+	chlist.WriteByte(0)
+
+	var buf bytes.Buffer
+	buf.Write(exrMagic)
+	binary.Write(&buf, binary.LittleEndian, uint32(exrVersion))
+	writeEXRAttribute(&buf, "channels", "chlist", chlist.Bytes())
+	writeEXRAttribute(&buf, "compression", "compression", []byte{exrZIPCompression})
+	writeEXRAttribute(&buf, "dataWindow", "box2i", exrBytes(int32(0), int32(10), int32(63), int32(11)))
+	buf.WriteByte(0)
+
+	// 1, -2, 0.5, 65504, a subnormal, and infinity, then zeros, so the
+	// block compresses and is not stored raw.
+	halves := make([]uint16, 128)
+	copy(halves, []uint16{0x3c00, 0xc000, 0x3800, 0x7bff, 0x0001, 0x7c00})
+	block := zipEXRBlock(exrBytes(halves))
+	binary.Write(&buf, binary.LittleEndian, uint64(buf.Len()+8))
+	binary.Write(&buf, binary.LittleEndian, int32(10))
+	binary.Write(&buf, binary.LittleEndian, int32(len(block)))
+	buf.Write(block)
+
+	width, height, got, err := ReadEXR(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 64 || height != 2 {
+		t.Errorf("size = %dx%d, want 64x2", width, height)
+	}
+	pixels := make([]float32, 128)
+	copy(pixels, []float32{1, -2, 0.5, 65504, 1.0 / (1 << 24), float32(math.Inf(1))})
+	want := []EXRChannel{{"Y", pixels}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadEXR() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+)
+
+type filmPixel struct {
+	sum    Vector3
+	weight float64
+}
+
+// Film collects samples for a rectangle of the image.  Each sample is
+// splatted onto every pixel within reach of the filter, weighted by
+// the filter, and each pixel is the weighted average of what landed
+// on it.  Because splatting only ever adds, films covering parts of an
+// image can be merged in any order and give the same result.
+type Film struct {
+	Bounds image.Rectangle
+	Filter Filter
+	pixels []filmPixel
+}
+
+// NewFilm returns an empty film covering bounds, in raster space,
+// where y runs down the image.
+func NewFilm(bounds image.Rectangle, filter Filter) *Film {
+	return &Film{
+		Bounds: bounds,
+		Filter: filter,
+		pixels: make([]filmPixel, bounds.Dx()*bounds.Dy()),
+	}
+}
+
+func (f *Film) pixel(x int, y int) *filmPixel {
+	return &f.pixels[(y-f.Bounds.Min.Y)*f.Bounds.Dx()+(x-f.Bounds.Min.X)]
+}
+
+// AddSample splats a sample taken at the continuous raster position
+// x, y.  The center of pixel i is at i + 0.5.
+func (f *Film) AddSample(x float64, y float64, color Vector3) {
+	r := f.Filter.Radius()
+	x0 := int(math.Ceil(x - 0.5 - r))
+	x1 := int(math.Floor(x - 0.5 + r))
+	y0 := int(math.Ceil(y - 0.5 - r))
+	y1 := int(math.Floor(y - 0.5 + r))
+	area := image.Rect(x0, y0, x1+1, y1+1).Intersect(f.Bounds)
+	for py := area.Min.Y; py < area.Max.Y; py++ {
+		for px := area.Min.X; px < area.Max.X; px++ {
+			w := f.Filter.Evaluate(float64(px)+0.5-x, float64(py)+0.5-y)
+			if w == 0 {
+				continue
+			}
+			p := f.pixel(px, py)
+			p.sum = p.sum.Add(color.MultiplyScalar(w))
+			p.weight += w
+		}
+	}
+}
+
+// Merge adds the samples collected by another film into this one.
+func (f *Film) Merge(o *Film) {
+	area := o.Bounds.Intersect(f.Bounds)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			p, op := f.pixel(x, y), o.pixel(x, y)
+			p.sum = p.sum.Add(op.sum)
+			p.weight += op.weight
+		}
+	}
+}
+
+// Color returns the linear color of a pixel.  Filters with negative
+// lobes can leave a pixel with little or no weight, or push it below
+// zero, so those are clamped.
+func (f *Film) Color(x int, y int) Vector3 {
+	p := f.pixel(x, y)
+	if p.weight <= 1e-8 {
+		return Vector3{}
+	}
+	c := p.sum.DivideScalar(p.weight)
+	return Vector3{math.Max(0, c.X), math.Max(0, c.Y), math.Max(0, c.Z)}
+}
+
+// Image returns the gamma corrected 8-bit image.
+func (f *Film) Image() *image.NRGBA {
+	im := image.NewNRGBA(image.Rect(0, 0, f.Bounds.Dx(), f.Bounds.Dy()))
+	for y := f.Bounds.Min.Y; y < f.Bounds.Max.Y; y++ {
+		pixelOffset := (y - f.Bounds.Min.Y) * im.Stride
+		for x := f.Bounds.Min.X; x < f.Bounds.Max.X; x++ {
+			color := f.Color(x, y).
+				Gamma2().
+				Clamp(0, 0.999).
+				MultiplyScalar(256)
+			im.Pix[pixelOffset] = uint8(color.X)
+			im.Pix[pixelOffset+1] = uint8(color.Y)
+			im.Pix[pixelOffset+2] = uint8(color.Z)
+			im.Pix[pixelOffset+3] = 0xff
+			pixelOffset += 4
+		}
+	}
+	return im
+}
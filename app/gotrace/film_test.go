@@ -0,0 +1,93 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFilm_BoxAveragesPixel(t *testing.T) {
+	film := NewFilm(image.Rect(0, 0, 2, 1), BoxFilter{0.5})
+	film.AddSample(0.2, 0.5, Vector3{1, 0, 0})
+	film.AddSample(0.9, 0.1, Vector3{0, 1, 0})
+	film.AddSample(1.5, 0.5, Vector3{0, 0, 1})
+	if got := film.Color(0, 0); got != (Vector3{0.5, 0.5, 0}) {
+		t.Errorf("Color(0, 0) = %v, want %v", got, Vector3{0.5, 0.5, 0})
+	}
+	if got := film.Color(1, 0); got != (Vector3{0, 0, 1}) {
+		t.Errorf("Color(1, 0) = %v, want %v", got, Vector3{0, 0, 1})
+	}
+}
+
+// Splatting each line onto its own strip of film and merging them
+// must match splatting everything onto one film.
+func TestFilm_MergeMatchesWholeFilm(t *testing.T) {
+	const width, height = 7, 5
+	for _, name := range []string{"box", "tent", "gaussian", "mitchell", "lanczos"} {
+		filter := NewFilter(name, 0)
+		whole := NewFilm(image.Rect(0, 0, width, height), filter)
+		merged := NewFilm(image.Rect(0, 0, width, height), filter)
+		reach := int(math.Ceil(filter.Radius()))
+		for row := 0; row < height; row++ {
+			strip := NewFilm(image.Rect(0, row-reach, width, row+reach+1).Intersect(whole.Bounds), filter)
+			for i := 0; i < 50; i++ {
+				x := rand.Float64() * width
+				y := float64(row) + rand.Float64()
+				c := RandomVector()
+				whole.AddSample(x, y, c)
+				strip.AddSample(x, y, c)
+			}
+			merged.Merge(strip)
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if d := whole.Color(x, y).Subtract(merged.Color(x, y)).Length(); d > 1e-9 {
+					t.Fatalf("%s: pixel %d, %d differs by %v", name, x, y, d)
+				}
+			}
+		}
+	}
+}
+
+func TestFilter_Evaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		x, y   float64
+		want   float64
+	}{
+		{"box inside", BoxFilter{0.5}, 0.4, -0.4, 1},
+		{"box outside", BoxFilter{0.5}, 0.6, 0, 0},
+		{"tent center", TentFilter{1}, 0, 0, 1},
+		{"tent half", TentFilter{1}, 0.5, 0, 0.5},
+		{"gaussian edge", NewGaussianFilter(1.5, 2), 1.5, 0, 0},
+		{"mitchell center", MitchellFilter{2, 1.0 / 3, 1.0 / 3}, 0, 0, 8.0 / 9 * 8.0 / 9},
+		{"mitchell edge", MitchellFilter{2, 1.0 / 3, 1.0 / 3}, 2, 0, 0},
+		{"lanczos center", LanczosFilter{3, 3}, 0, 0, 1},
+		{"lanczos zero crossing", LanczosFilter{3, 3}, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Evaluate(tt.x, tt.y); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// Filter weighs a sample by its offset from the center of a pixel,
+// when reconstructing the image from samples.  Filters are separable,
+// and are zero beyond Radius along either axis.
+type Filter interface {
+	Radius() float64
+	Evaluate(x float64, y float64) float64
+}
+
+// BoxFilter weighs every sample within its radius equally.  With a
+// radius of one half, this averages the samples within each pixel.
+type BoxFilter struct {
+	R float64
+}
+
+// Radius returns the extent of the filter.
+func (f BoxFilter) Radius() float64 {
+	return f.R
+}
+
+// Evaluate returns the weight of a sample at x, y from the pixel center.
+func (f BoxFilter) Evaluate(x float64, y float64) float64 {
+	if math.Abs(x) > f.R || math.Abs(y) > f.R {
+		return 0
+	}
+	return 1
+}
+
+// TentFilter weighs samples linearly less the further they are from the
+// center of the pixel.
+type TentFilter struct {
+	R float64
+}
+
+// Radius returns the extent of the filter.
+func (f TentFilter) Radius() float64 {
+	return f.R
+}
+
+// Evaluate returns the weight of a sample at x, y from the pixel center.
+func (f TentFilter) Evaluate(x float64, y float64) float64 {
+	return math.Max(0, f.R-math.Abs(x)) * math.Max(0, f.R-math.Abs(y))
+}
+
+// GaussianFilter weighs samples by a Gaussian, shifted down so that it
+// reaches zero at its radius.
+type GaussianFilter struct {
+	R     float64
+	Alpha float64
+	edge  float64
+}
+
+// NewGaussianFilter returns a Gaussian filter.  A larger alpha gives
+// a narrower, sharper filter.
+func NewGaussianFilter(radius float64, alpha float64) GaussianFilter {
+	return GaussianFilter{R: radius, Alpha: alpha, edge: math.Exp(-alpha * radius * radius)}
+}
+
+// Radius returns the extent of the filter.
+func (f GaussianFilter) Radius() float64 {
+	return f.R
+}
+
+func (f GaussianFilter) gaussian(d float64) float64 {
+	return math.Max(0, math.Exp(-f.Alpha*d*d)-f.edge)
+}
+
+// Evaluate returns the weight of a sample at x, y from the pixel center.
+func (f GaussianFilter) Evaluate(x float64, y float64) float64 {
+	return f.gaussian(x) * f.gaussian(y)
+}
+
+// MitchellFilter is the Mitchell-Netravali cubic filter.  B and C trade
+// blurring against ringing; 1/3 each is the usual recommendation.
+type MitchellFilter struct {
+	R float64
+	B float64
+	C float64
+}
+
+// Radius returns the extent of the filter.
+func (f MitchellFilter) Radius() float64 {
+	return f.R
+}
+
+func (f MitchellFilter) mitchell(d float64) float64 {
+	// The cubic is defined over [-2, 2], so scale to fit the radius.
+	x := math.Abs(2 * d / f.R)
+	b, c := f.B, f.C
+	if x > 2 {
+		return 0
+	}
+	if x > 1 {
+		return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+	}
+	return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+}
+
+// Evaluate returns the weight of a sample at x, y from the pixel center.
+func (f MitchellFilter) Evaluate(x float64, y float64) float64 {
+	return f.mitchell(x) * f.mitchell(y)
+}
+
+// LanczosFilter is a windowed sinc filter.  Tau is how many cycles of
+// the sinc fit within the radius.
+type LanczosFilter struct {
+	R   float64
+	Tau float64
+}
+
+// Radius returns the extent of the filter.
+func (f LanczosFilter) Radius() float64 {
+	return f.R
+}
+
+func sinc(x float64) float64 {
+	if math.Abs(x) < 1e-5 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+func (f LanczosFilter) lanczos(d float64) float64 {
+	x := math.Abs(d) / f.R
+	if x > 1 {
+		return 0
+	}
+	return sinc(x*f.Tau) * sinc(x)
+}
+
+// Evaluate returns the weight of a sample at x, y from the pixel center.
+func (f LanczosFilter) Evaluate(x float64, y float64) float64 {
+	return f.lanczos(x) * f.lanczos(y)
+}
+
+// NewFilter returns the named filter with the given radius, or with
+// its usual radius if radius is zero.  It returns nil for an unknown
+// name.
+func NewFilter(name string, radius float64) Filter {
+	pick := func(r float64) float64 {
+		if radius > 0 {
+			return radius
+		}
+		return r
+	}
+	switch name {
+	case "box":
+		return BoxFilter{pick(0.5)}
+	case "tent":
+		return TentFilter{pick(1)}
+	case "gaussian":
+		return NewGaussianFilter(pick(1.5), 2)
+	case "mitchell":
+		return MitchellFilter{pick(2), 1.0 / 3, 1.0 / 3}
+	case "lanczos":
+		return LanczosFilter{pick(3), 3}
+	}
+	return nil
+}
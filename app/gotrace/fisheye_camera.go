@@ -0,0 +1,64 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// FisheyeCamera uses an equidistant fisheye projection, where the
+// distance from the center of the image is proportional to the angle
+// from the view direction.  The image circle fills the shorter side of
+// the image.
+type FisheyeCamera struct {
+	cameraBasis
+	FieldOfView float64
+	AspectRatio float64
+}
+
+// NewFisheyeCamera returns a fisheye camera which sees fieldOfView
+// degrees across the image circle.
+func NewFisheyeCamera(lookFrom Vector3, lookAt Vector3, vup Vector3, fieldOfView float64, aspectRatio float64, time0 float64, time1 float64) FisheyeCamera {
+	return FisheyeCamera{
+		cameraBasis: newCameraBasis(lookFrom, lookAt, vup, time0, time1),
+		FieldOfView: fieldOfView,
+		AspectRatio: aspectRatio,
+	}
+}
+
+// GetRay returns a ray from the camera's origin.  Points outside the
+// image circle have no ray.
+func (c FisheyeCamera) GetRay(s float64, t float64, smp Sampler) (bool, Ray) {
+	x := 2*s - 1
+	y := 2*t - 1
+	if c.AspectRatio > 1 {
+		x *= c.AspectRatio
+	} else {
+		y /= c.AspectRatio
+	}
+	radius := math.Sqrt(x*x + y*y)
+	if radius > 1 {
+		return false, Ray{}
+	}
+
+	theta := radius * c.FieldOfView / 2 * math.Pi / 180
+	phi := math.Atan2(y, x)
+	sinTheta, cosTheta := math.Sincos(theta)
+	sinPhi, cosPhi := math.Sincos(phi)
+	direction := c.u.MultiplyScalar(sinTheta * cosPhi).
+		Add(c.v.MultiplyScalar(sinTheta * sinPhi)).
+		Subtract(c.w.MultiplyScalar(cosTheta))
+	return true, NewRay(c.origin, direction, c.time(smp))
+}
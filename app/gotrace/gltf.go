@@ -0,0 +1,319 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gltfDocument is the part of a glTF 2.0 file which describes
+// materials.
+type gltfDocument struct {
+	Asset          gltfAsset      `json:"asset"`
+	ExtensionsUsed []string       `json:"extensionsUsed,omitempty"`
+	Materials      []gltfMaterial `json:"materials,omitempty"`
+	Textures       []gltfTexture  `json:"textures,omitempty"`
+	Images         []gltfImage    `json:"images,omitempty"`
+}
+
+type gltfAsset struct {
+	Version string `json:"version"`
+}
+
+type gltfTexture struct {
+	Source int `json:"source"`
+}
+
+type gltfImage struct {
+	URI string `json:"uri"`
+}
+
+type gltfTextureInfo struct {
+	Index int `json:"index"`
+}
+
+type gltfMaterial struct {
+	Name                 string                    `json:"name,omitempty"`
+	PBRMetallicRoughness *gltfPBRMetallicRoughness `json:"pbrMetallicRoughness,omitempty"`
+	Extensions           gltfExtensions            `json:"extensions,omitempty"`
+}
+
+type gltfPBRMetallicRoughness struct {
+	BaseColorFactor          *[4]float64      `json:"baseColorFactor,omitempty"`
+	BaseColorTexture         *gltfTextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor           *float64         `json:"metallicFactor,omitempty"`
+	RoughnessFactor          *float64         `json:"roughnessFactor,omitempty"`
+	MetallicRoughnessTexture *gltfTextureInfo `json:"metallicRoughnessTexture,omitempty"`
+}
+
+type gltfExtensions struct {
+	Clearcoat    *gltfClearcoat    `json:"KHR_materials_clearcoat,omitempty"`
+	Sheen        *gltfSheen        `json:"KHR_materials_sheen,omitempty"`
+	Transmission *gltfTransmission `json:"KHR_materials_transmission,omitempty"`
+	IOR          *gltfIOR          `json:"KHR_materials_ior,omitempty"`
+	Specular     *gltfSpecular     `json:"KHR_materials_specular,omitempty"`
+}
+
+type gltfClearcoat struct {
+	ClearcoatFactor           float64          `json:"clearcoatFactor"`
+	ClearcoatTexture          *gltfTextureInfo `json:"clearcoatTexture,omitempty"`
+	ClearcoatRoughnessFactor  float64          `json:"clearcoatRoughnessFactor"`
+	ClearcoatRoughnessTexture *gltfTextureInfo `json:"clearcoatRoughnessTexture,omitempty"`
+}
+
+type gltfSheen struct {
+	SheenColorFactor  [3]float64       `json:"sheenColorFactor"`
+	SheenColorTexture *gltfTextureInfo `json:"sheenColorTexture,omitempty"`
+}
+
+type gltfTransmission struct {
+	TransmissionFactor  float64          `json:"transmissionFactor"`
+	TransmissionTexture *gltfTextureInfo `json:"transmissionTexture,omitempty"`
+}
+
+type gltfIOR struct {
+	IOR *float64 `json:"ior,omitempty"`
+}
+
+type gltfSpecular struct {
+	SpecularFactor *float64 `json:"specularFactor,omitempty"`
+}
+
+// gltfExtensionNames are the extensions WriteGLTFMaterials uses.
+var gltfExtensionNames = []string{
+	"KHR_materials_clearcoat",
+	"KHR_materials_ior",
+	"KHR_materials_sheen",
+	"KHR_materials_specular",
+	"KHR_materials_transmission",
+}
+
+// ReadGLTFMaterials reads the materials of a glTF 2.0 file, by name, as
+// principled materials.  Materials without a name are called material
+// and their index.  Besides the metallic-roughness model, it reads the
+// clearcoat, sheen, transmission, ior and specular extensions.  Sheen
+// is taken from the red of the sheen color, and specular as one half
+// of the specular factor, without its texture.  Only .gltf files with
+// images in separate files are read, found relative to dir.
+func ReadGLTFMaterials(r io.Reader, dir string) (map[string]PrincipledMaterial, error) {
+	return readGLTFMaterials(r, newTextureFiles(dir))
+}
+
+// LoadGLTFMaterials reads the materials of a glTF file.
+func LoadGLTFMaterials(filename string) (map[string]PrincipledMaterial, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadGLTFMaterials(f, filepath.Dir(filename))
+}
+
+func readGLTFMaterials(r io.Reader, files *textureFiles) (map[string]PrincipledMaterial, error) {
+	var doc gltfDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	// image returns the texture of a texture info, and channel of it,
+	// scaled by factor.
+	image := func(info *gltfTextureInfo, linear bool, channel int, factor Vector3) (Texture, error) {
+		if info == nil {
+			return NewSolidColor(factor), nil
+		}
+		if info.Index < 0 || info.Index >= len(doc.Textures) {
+			return nil, fmt.Errorf("no texture %d", info.Index)
+		}
+		source := doc.Textures[info.Index].Source
+		if source < 0 || source >= len(doc.Images) {
+			return nil, fmt.Errorf("no image %d", source)
+		}
+		uri := doc.Images[source].URI
+		if uri == "" || strings.HasPrefix(uri, "data:") {
+			return nil, fmt.Errorf("image %d is not in a file", source)
+		}
+		im, err := files.load(uri, linear)
+		if err != nil {
+			return nil, err
+		}
+		return textureParts{factor: factor, image: im, channel: channel}.texture(), nil
+	}
+
+	ret := map[string]PrincipledMaterial{}
+	for i, gm := range doc.Materials {
+		name := gm.Name
+		if name == "" {
+			name = fmt.Sprintf("material%d", i)
+		}
+		m, err := gm.material(image)
+		if err != nil {
+			return nil, fmt.Errorf("material %s: %w", name, err)
+		}
+		ret[name] = m
+	}
+	return ret, nil
+}
+
+type gltfImageFunc func(info *gltfTextureInfo, linear bool, channel int, factor Vector3) (Texture, error)
+
+// material returns the principled material, using image to look up
+// textures.
+func (gm gltfMaterial) material(image gltfImageFunc) (PrincipledMaterial, error) {
+	m := NewPrincipledMaterial(Vector3{1, 1, 1})
+	var err error
+	set := func(field *Texture, info *gltfTextureInfo, linear bool, channel int, factor float64) {
+		if err == nil {
+			*field, err = image(info, linear, channel, Vector3{factor, factor, factor})
+		}
+	}
+
+	pbr := gm.PBRMetallicRoughness
+	if pbr == nil {
+		pbr = &gltfPBRMetallicRoughness{}
+	}
+	baseColor := Vector3{1, 1, 1}
+	if pbr.BaseColorFactor != nil {
+		baseColor = Vector3{pbr.BaseColorFactor[0], pbr.BaseColorFactor[1], pbr.BaseColorFactor[2]}
+	}
+	m.BaseColor, err = image(pbr.BaseColorTexture, false, -1, baseColor)
+	metallic, roughness := 1.0, 1.0
+	if pbr.MetallicFactor != nil {
+		metallic = *pbr.MetallicFactor
+	}
+	if pbr.RoughnessFactor != nil {
+		roughness = *pbr.RoughnessFactor
+	}
+	set(&m.Metallic, pbr.MetallicRoughnessTexture, true, 2, metallic)
+	set(&m.Roughness, pbr.MetallicRoughnessTexture, true, 1, roughness)
+
+	ext := gm.Extensions
+	if c := ext.Clearcoat; c != nil {
+		set(&m.Clearcoat, c.ClearcoatTexture, true, -1, c.ClearcoatFactor)
+		set(&m.ClearcoatRoughness, c.ClearcoatRoughnessTexture, true, 1, c.ClearcoatRoughnessFactor)
+	}
+	if s := ext.Sheen; s != nil {
+		set(&m.Sheen, s.SheenColorTexture, false, 0, s.SheenColorFactor[0])
+	}
+	if t := ext.Transmission; t != nil {
+		set(&m.Transmission, t.TransmissionTexture, true, -1, t.TransmissionFactor)
+	}
+	if ext.IOR != nil && ext.IOR.IOR != nil {
+		m.IOR = *ext.IOR.IOR
+	}
+	if ext.Specular != nil {
+		specular := 1.0
+		if ext.Specular.SpecularFactor != nil {
+			specular = *ext.Specular.SpecularFactor
+		}
+		m.Specular = NewSolidValue(specular / 2)
+	}
+	return m, err
+}
+
+// WriteGLTFMaterials writes materials as a glTF file with no geometry,
+// which ReadGLTFMaterials reads back the same, but for SheenTint.  Each
+// texture must be a value, or an image as glTF packs it: Metallic and
+// Roughness from the blue and green of one image, ClearcoatRoughness
+// from green, and Sheen from red.
+func WriteGLTFMaterials(w io.Writer, materials map[string]PrincipledMaterial) error {
+	names := make([]string, 0, len(materials))
+	for name := range materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	doc := gltfDocument{Asset: gltfAsset{Version: "2.0"}, ExtensionsUsed: gltfExtensionNames}
+	textures := map[string]int{}
+	// info returns the texture info for the parts, which must use the
+	// given channel, adding the image if it is new.
+	info := func(parts textureParts, linear bool, channel int) (*gltfTextureInfo, error) {
+		if parts.image == nil {
+			return nil, nil
+		}
+		if parts.image.linear != linear {
+			return nil, fmt.Errorf("image %s is not linear as glTF reads it", parts.image.path)
+		}
+		if parts.channel != channel && !(channel <= 0 && parts.channel <= 0) {
+			return nil, fmt.Errorf("image %s is not channel %d", parts.image.path, channel)
+		}
+		index, ok := textures[parts.image.path]
+		if !ok {
+			index = len(doc.Textures)
+			textures[parts.image.path] = index
+			doc.Images = append(doc.Images, gltfImage{URI: filepath.ToSlash(parts.image.path)})
+			doc.Textures = append(doc.Textures, gltfTexture{Source: len(doc.Images) - 1})
+		}
+		return &gltfTextureInfo{Index: index}, nil
+	}
+
+	for _, name := range names {
+		m := materials[name]
+		var parts [8]textureParts
+		for i, t := range []Texture{m.BaseColor, m.Metallic, m.Roughness, m.Specular, m.Clearcoat, m.ClearcoatRoughness, m.Sheen, m.Transmission} {
+			p, err := partsOf(t)
+			if err != nil {
+				return fmt.Errorf("material %s: %w", name, err)
+			}
+			parts[i] = p
+		}
+		baseColor, metallic, roughness, specular, clearcoat, clearcoatRoughness, sheen, transmission := parts[0], parts[1], parts[2], parts[3], parts[4], parts[5], parts[6], parts[7]
+		if specular.image != nil {
+			return fmt.Errorf("material %s: glTF has no specular image", name)
+		}
+		if metallic.image != roughness.image {
+			return fmt.Errorf("material %s: metallic and roughness must share an image", name)
+		}
+
+		gm := gltfMaterial{Name: name, PBRMetallicRoughness: &gltfPBRMetallicRoughness{
+			BaseColorFactor: &[4]float64{baseColor.factor.X, baseColor.factor.Y, baseColor.factor.Z, 1},
+			MetallicFactor:  &metallic.factor.X,
+			RoughnessFactor: &roughness.factor.X,
+		}}
+		pbr := gm.PBRMetallicRoughness
+		specularFactor := specular.factor.X * 2
+		var errs [7]error
+		pbr.BaseColorTexture, errs[0] = info(baseColor, false, -1)
+		pbr.MetallicRoughnessTexture, errs[1] = info(metallic, true, 2)
+		_, errs[2] = info(roughness, true, 1)
+		gm.Extensions = gltfExtensions{
+			Clearcoat:    &gltfClearcoat{ClearcoatFactor: clearcoat.factor.X, ClearcoatRoughnessFactor: clearcoatRoughness.factor.X},
+			Sheen:        &gltfSheen{SheenColorFactor: [3]float64{sheen.factor.X, sheen.factor.X, sheen.factor.X}},
+			Transmission: &gltfTransmission{TransmissionFactor: transmission.factor.X},
+			IOR:          &gltfIOR{IOR: &m.IOR},
+			Specular:     &gltfSpecular{SpecularFactor: &specularFactor},
+		}
+		gm.Extensions.Clearcoat.ClearcoatTexture, errs[3] = info(clearcoat, true, -1)
+		gm.Extensions.Clearcoat.ClearcoatRoughnessTexture, errs[4] = info(clearcoatRoughness, true, 1)
+		gm.Extensions.Sheen.SheenColorTexture, errs[5] = info(sheen, false, 0)
+		gm.Extensions.Transmission.TransmissionTexture, errs[6] = info(transmission, true, -1)
+		for _, err := range errs {
+			if err != nil {
+				return fmt.Errorf("material %s: %w", name, err)
+			}
+		}
+		doc.Materials = append(doc.Materials, gm)
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(doc)
+}
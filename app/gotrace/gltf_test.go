@@ -0,0 +1,108 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadGLTFMaterials(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImage(t, dir, "base.png", false)
+	writeTestImage(t, dir, "orm.png", true)
+	materials, err := ReadGLTFMaterials(strings.NewReader(`{
+		"asset": {"version": "2.0"},
+		"images": [{"uri": "base.png"}, {"uri": "orm.png"}],
+		"textures": [{"source": 0}, {"source": 1}],
+		"materials": [
+			{
+				"name": "rusty",
+				"pbrMetallicRoughness": {
+					"baseColorTexture": {"index": 0},
+					"metallicRoughnessTexture": {"index": 1},
+					"roughnessFactor": 0.5
+				}
+			},
+			{
+				"pbrMetallicRoughness": {"baseColorFactor": [0.1, 0.2, 0.3, 1], "metallicFactor": 0},
+				"extensions": {
+					"KHR_materials_clearcoat": {"clearcoatFactor": 1, "clearcoatRoughnessFactor": 0.1},
+					"KHR_materials_ior": {"ior": 1.33},
+					"KHR_materials_specular": {}
+				}
+			}
+		]
+	}`), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := newTextureFiles(dir)
+	base, _ := files.load("base.png", false)
+	orm, _ := files.load("orm.png", true)
+	rusty := NewPrincipledMaterial(Vector3{})
+	rusty.BaseColor = base
+	rusty.Metallic = NewChannelTexture(orm, 2)
+	rusty.Roughness = NewScaledTexture(NewChannelTexture(orm, 1), Vector3{0.5, 0.5, 0.5})
+	sameMaterial(t, "rusty", materials["rusty"], rusty)
+
+	coated := NewPrincipledMaterial(Vector3{0.1, 0.2, 0.3})
+	coated.Roughness = NewSolidValue(1)
+	coated.Clearcoat = NewSolidValue(1)
+	coated.ClearcoatRoughness = NewSolidValue(0.1)
+	coated.IOR = 1.33
+	sameMaterial(t, "material1", materials["material1"], coated)
+
+	for _, bad := range []string{
+		`{"materials": [{"pbrMetallicRoughness": {"baseColorTexture": {"index": 0}}}]}`,
+		`{"images": [{"uri": "data:image/png;base64,AAAA"}], "textures": [{"source": 0}], "materials": [{"pbrMetallicRoughness": {"baseColorTexture": {"index": 0}}}]}`,
+	} {
+		if _, err := ReadGLTFMaterials(strings.NewReader(bad), dir); err == nil {
+			t.Errorf("ReadGLTFMaterials(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestGLTFMaterials_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sheen := NewPrincipledMaterial(Vector3{0.3, 0.1, 0.5})
+	sheen.Sheen = NewSolidValue(0.8)
+	sheen.Specular = NewSolidValue(0.3)
+	want := map[string]PrincipledMaterial{
+		"paint":  carPaint(t, dir),
+		"velvet": sheen,
+	}
+	var b bytes.Buffer
+	if err := WriteGLTFMaterials(&b, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadGLTFMaterials(&b, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range want {
+		sameMaterial(t, name, got[name], want[name])
+	}
+
+	split := NewPrincipledMaterial(Vector3{})
+	split.Roughness = NewChannelTexture(writeTestImage(t, dir, "rough.png", true), 1)
+	if err := WriteGLTFMaterials(&b, map[string]PrincipledMaterial{"split": split}); err == nil {
+		t.Error("WriteGLTFMaterials() of roughness without metallic succeeded")
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// HairMaterial is the Kajiya-Kay model of a hair fiber.  Diffuse light
+// is brightest when the light crosses the fiber and fades as it runs
+// along it, and the highlight is a cone around the fiber, mirroring
+// the angle at which the ray arrived, rather than a spot.  It needs
+// a surface which sets DPDU along the fiber, such as a curve.
+type HairMaterial struct {
+	color    Vector3
+	specular Vector3
+	exponent float64
+}
+
+// NewHairMaterial returns a hair material.  A larger exponent gives a
+// tighter highlight.
+func NewHairMaterial(color Vector3, specular Vector3, exponent float64) HairMaterial {
+	return HairMaterial{color: color, specular: specular, exponent: exponent}
+}
+
+// Scatter picks the highlight or the diffuse lobe, in proportion to
+// how bright each is, and scales the chosen one up to make up for the
+// other.
+func (m HairMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	if NearZeroVector(hr.DPDU) {
+		return NewLambertianMaterial(m.color).Scatter(r, hr, smp)
+	}
+	tangent := hr.DPDU.Normalize()
+	// Around the fiber, scatter only to the side the ray came from.
+	b1 := hr.Normal.Subtract(tangent.MultiplyScalar(hr.Normal.Dot(tangent))).Normalize()
+	b2 := tangent.Cross(b1)
+
+	diffuse := (m.color.X + m.color.Y + m.color.Z) / 3
+	specular := (m.specular.X + m.specular.Y + m.specular.Z) / 3
+	pSpecular := 0.0
+	if diffuse+specular > 0 {
+		pSpecular = specular / (diffuse + specular)
+	}
+
+	var direction Vector3
+	var attenuation Vector3
+	if smp.Get1D() < pSpecular {
+		// Keep the ray's angle to the fiber, blurred by a Gaussian
+		// standing in for cos^exponent, and go anywhere around it.
+		u1, u2 := smp.Get2D()
+		blur := math.Sqrt(-2*math.Log(math.Max(u1, 1e-12))) * math.Cos(2*math.Pi*u2) / math.Sqrt(m.exponent)
+		theta := clamp(math.Acos(clamp(r.Direction.Normalize().Dot(tangent), -1, 1))+blur, 0, math.Pi)
+		phi := (smp.Get1D() - 0.5) * math.Pi
+		around := b1.MultiplyScalar(math.Cos(phi)).Add(b2.MultiplyScalar(math.Sin(phi)))
+		direction = tangent.MultiplyScalar(math.Cos(theta)).Add(around.MultiplyScalar(math.Sin(theta)))
+		attenuation = m.specular.DivideScalar(pSpecular)
+	} else {
+		direction = b1.Add(SampleUnitSphere(smp.Get2D()))
+		if NearZeroVector(direction) {
+			direction = b1
+		}
+		along := direction.Normalize().Dot(tangent)
+		sinTangent := math.Sqrt(math.Max(0, 1-along*along))
+		attenuation = m.color.MultiplyScalar(sinTangent / (1 - pSpecular))
+	}
+	return true, r.Spawn(hr.P, direction), attenuation
+}
+
+// Albedo returns the diffuse color of the hair.
+func (m HairMaterial) Albedo(hr *HitRecord) Vector3 {
+	return m.color
+}
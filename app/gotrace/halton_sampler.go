@@ -0,0 +1,77 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// haltonPrimes are the bases used for each dimension of the Halton
+// sequence.  Beyond these, the sequence correlates badly between
+// dimensions, so the sampler falls back to hashed random numbers.
+var haltonPrimes = []int{
+	2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53,
+	59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131,
+}
+
+// HaltonSampler uses the Halton sequence, with each dimension using
+// the radical inverse in a different prime base.  Each pixel shifts the
+// sequence by a different random amount in every dimension, which
+// keeps the points well spread while hiding any pattern between pixels.
+type HaltonSampler struct {
+	pixelSample
+}
+
+// NewHaltonSampler returns a Halton sampler.
+func NewHaltonSampler(seed int64) *HaltonSampler {
+	return &HaltonSampler{pixelSample{seed: uint32(seed)}}
+}
+
+func radicalInverse(base int, index int) float64 {
+	invBase := 1 / float64(base)
+	invBaseN := 1.0
+	reversed := 0
+	for index > 0 {
+		next := index / base
+		digit := index - next*base
+		reversed = reversed*base + digit
+		invBaseN *= invBase
+		index = next
+	}
+	return float64(reversed) * invBaseN
+}
+
+// Get1D returns the next dimension of the sequence.
+func (s *HaltonSampler) Get1D() float64 {
+	d := s.next()
+	h := s.hash(d)
+	if d >= len(haltonPrimes) {
+		return toUnit(hashInts(int(h), s.index))
+	}
+	v := radicalInverse(haltonPrimes[d], s.index) + toUnit(h)
+	if v >= 1 {
+		v--
+	}
+	return v
+}
+
+// Get2D returns the next two dimensions of the sequence.
+func (s *HaltonSampler) Get2D() (float64, float64) {
+	return s.Get1D(), s.Get1D()
+}
+
+// Clone returns a new Halton sampler.  The numbers depend only on the
+// pixel and sample, so the seed is ignored, and every worker agrees.
+func (s *HaltonSampler) Clone(seed int64) Sampler {
+	return &HaltonSampler{pixelSample{seed: s.seed}}
+}
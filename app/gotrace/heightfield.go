@@ -0,0 +1,277 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"sort"
+)
+
+// heightNode covers the cells from X0, Z0 up to X1, Z1, and records the
+// lowest and highest sample among them, so a ray which passes above or
+// below can skip all of them at once.
+type heightNode struct {
+	X0, Z0, X1, Z1 int
+	Min, Max       float64
+	Children       []int
+}
+
+type heightfield struct {
+	Width    int
+	Depth    int
+	Heights  []float64
+	Normals  []Vector3
+	Origin   Vector3
+	Size     Vector3
+	Nodes    []heightNode
+	Material Material
+}
+
+// NewHeightfield returns terrain from a grid of width by depth height
+// samples, stored a row of constant Z at a time.  The grid is
+// stretched over size.X by size.Z starting at origin, and each height
+// is scaled by size.Y and raised by origin.Y.  Between samples the
+// surface is bilinear, with normals smoothly interpolated from the
+// samples, and U and V run from 0 to 1 across X and Z.
+func NewHeightfield(width int, depth int, heights []float64, origin Vector3, size Vector3, mat Material) (Hittable, error) {
+	if width < 2 || depth < 2 {
+		return nil, fmt.Errorf("heightfield must be at least 2x2 samples, not %dx%d", width, depth)
+	}
+	if len(heights) != width*depth {
+		return nil, fmt.Errorf("heightfield of %dx%d needs %d samples, not %d", width, depth, width*depth, len(heights))
+	}
+	h := &heightfield{
+		Width:    width,
+		Depth:    depth,
+		Heights:  make([]float64, len(heights)),
+		Origin:   origin,
+		Size:     size,
+		Material: mat,
+	}
+	for i, v := range heights {
+		h.Heights[i] = origin.Y + v*size.Y
+	}
+	h.Normals = h.sampleNormals()
+	h.build(0, 0, width-1, depth-1)
+	return h, nil
+}
+
+// NewImageHeightfield returns terrain from the brightness of an image,
+// with black at origin.Y and white at size.Y above it.  Image rows run
+// along +Z.  Sixteen bit grayscale images, as used for elevation
+// data, keep their full precision.
+func NewImageHeightfield(im image.Image, origin Vector3, size Vector3, mat Material) (Hittable, error) {
+	b := im.Bounds()
+	heights := make([]float64, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, _ := im.At(x, y).RGBA()
+			heights = append(heights, (0.2126*float64(r)+0.7152*float64(g)+0.0722*float64(b))/0xffff)
+		}
+	}
+	return NewHeightfield(b.Dx(), b.Dy(), heights, origin, size, mat)
+}
+
+// LoadHeightfield reads an image file as terrain.
+func LoadHeightfield(filename string, origin Vector3, size Vector3, mat Material) (Hittable, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	im, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return NewImageHeightfield(im, origin, size, mat)
+}
+
+func (h *heightfield) height(x int, z int) float64 {
+	return h.Heights[z*h.Width+x]
+}
+
+// cellSize returns the distance between samples along X and Z.
+func (h *heightfield) cellSize() (float64, float64) {
+	return h.Size.X / float64(h.Width-1), h.Size.Z / float64(h.Depth-1)
+}
+
+// sampleNormals estimates the normal at each sample from the slope to
+// its neighbours.
+func (h *heightfield) sampleNormals() []Vector3 {
+	dx, dz := h.cellSize()
+	ret := make([]Vector3, len(h.Heights))
+	for z := 0; z < h.Depth; z++ {
+		z0, z1 := clampIndex(z-1, h.Depth), clampIndex(z+1, h.Depth)
+		for x := 0; x < h.Width; x++ {
+			x0, x1 := clampIndex(x-1, h.Width), clampIndex(x+1, h.Width)
+			slopeX := (h.height(x1, z) - h.height(x0, z)) / (float64(x1-x0) * dx)
+			slopeZ := (h.height(x, z1) - h.height(x, z0)) / (float64(z1-z0) * dz)
+			ret[z*h.Width+x] = Vector3{-slopeX, 1, -slopeZ}.Normalize()
+		}
+	}
+	return ret
+}
+
+// clampIndex keeps i within a slice of length n.
+func clampIndex(i int, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// build adds the node for the cells from x0, z0 up to x1, z1, and
+// returns its index.
+func (h *heightfield) build(x0 int, z0 int, x1 int, z1 int) int {
+	index := len(h.Nodes)
+	h.Nodes = append(h.Nodes, heightNode{X0: x0, Z0: z0, X1: x1, Z1: z1})
+	if x1-x0 == 1 && z1-z0 == 1 {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range [4]float64{h.height(x0, z0), h.height(x1, z0), h.height(x0, z1), h.height(x1, z1)} {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		h.Nodes[index].Min, h.Nodes[index].Max = lo, hi
+		return index
+	}
+	xs := []int{x0, x1}
+	if x1-x0 > 1 {
+		xs = []int{x0, (x0 + x1) / 2, x1}
+	}
+	zs := []int{z0, z1}
+	if z1-z0 > 1 {
+		zs = []int{z0, (z0 + z1) / 2, z1}
+	}
+	var children []int
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := 0; i+1 < len(zs); i++ {
+		for j := 0; j+1 < len(xs); j++ {
+			child := h.build(xs[j], zs[i], xs[j+1], zs[i+1])
+			children = append(children, child)
+			lo, hi = math.Min(lo, h.Nodes[child].Min), math.Max(hi, h.Nodes[child].Max)
+		}
+	}
+	h.Nodes[index].Min, h.Nodes[index].Max, h.Nodes[index].Children = lo, hi, children
+	return index
+}
+
+// bounds returns the box around a node.
+func (h *heightfield) bounds(n heightNode) aabb {
+	dx, dz := h.cellSize()
+	return aabb{
+		Vector3{h.Origin.X + float64(n.X0)*dx, n.Min, h.Origin.Z + float64(n.Z0)*dz},
+		Vector3{h.Origin.X + float64(n.X1)*dx, n.Max, h.Origin.Z + float64(n.Z1)*dz},
+	}
+}
+
+func (h *heightfield) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return h.hitNode(0, r, tMin, tMax)
+}
+
+// hitNode walks down the quadtree, visiting children nearest first so
+// the search can stop at the first hit.
+func (h *heightfield) hitNode(index int, r Ray, tMin float64, tMax float64) *HitRecord {
+	n := h.Nodes[index]
+	if _, _, ok := h.bounds(n).hitRange(r, tMin, tMax); !ok {
+		return nil
+	}
+	if len(n.Children) == 0 {
+		return h.hitCell(n.X0, n.Z0, r, tMin, tMax)
+	}
+
+	type entry struct {
+		index int
+		t     float64
+	}
+	order := make([]entry, 0, len(n.Children))
+	for _, child := range n.Children {
+		if t, _, ok := h.bounds(h.Nodes[child]).hitRange(r, tMin, tMax); ok {
+			order = append(order, entry{child, t})
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].t < order[j].t })
+
+	var closest *HitRecord
+	for _, e := range order {
+		if e.t > tMax {
+			break
+		}
+		if hr := h.hitNode(e.index, r, tMin, tMax); hr != nil {
+			closest = hr
+			tMax = hr.T
+		}
+	}
+	return closest
+}
+
+// hitCell intersects the bilinear patch over one cell.  Along the ray
+// the patch's height is quadratic in t, so this is exact.
+func (h *heightfield) hitCell(x int, z int, r Ray, tMin float64, tMax float64) *HitRecord {
+	r.countIntersectionTest()
+	dx, dz := h.cellSize()
+	h00, h10 := h.height(x, z), h.height(x+1, z)
+	h01, h11 := h.height(x, z+1), h.height(x+1, z+1)
+	a, b, c := h10-h00, h01-h00, h00-h10-h01+h11
+
+	// The ray in the cell's own 0 to 1 coordinates.
+	u0 := (r.Origin.X - h.Origin.X - float64(x)*dx) / dx
+	v0 := (r.Origin.Z - h.Origin.Z - float64(z)*dz) / dz
+	du := r.Direction.X / dx
+	dv := r.Direction.Z / dz
+
+	const slack = 1e-9
+	for _, t := range solveQuadratic(
+		c*du*dv,
+		a*du+b*dv+c*(u0*dv+v0*du)-r.Direction.Y,
+		h00+a*u0+b*v0+c*u0*v0-r.Origin.Y,
+	) {
+		if t < tMin || t > tMax {
+			continue
+		}
+		u, v := u0+du*t, v0+dv*t
+		if u < -slack || u > 1+slack || v < -slack || v > 1+slack {
+			continue
+		}
+		u, v = clamp(u, 0, 1), clamp(v, 0, 1)
+		normal := h.Normals[z*h.Width+x].MultiplyScalar((1 - u) * (1 - v)).
+			Add(h.Normals[z*h.Width+x+1].MultiplyScalar(u * (1 - v))).
+			Add(h.Normals[(z+1)*h.Width+x].MultiplyScalar((1 - u) * v)).
+			Add(h.Normals[(z+1)*h.Width+x+1].MultiplyScalar(u * v)).
+			Normalize()
+		hr := &HitRecord{
+			T:        t,
+			P:        r.Point(t),
+			U:        (float64(x) + u) / float64(h.Width-1),
+			V:        (float64(z) + v) / float64(h.Depth-1),
+			DPDU:     Vector3{dx, a + c*v, 0}.MultiplyScalar(float64(h.Width - 1)),
+			DPDV:     Vector3{0, b + c*u, dz}.MultiplyScalar(float64(h.Depth - 1)),
+			Material: h.Material,
+		}
+		hr.SetFaceNormal(r, normal)
+		return hr
+	}
+	return nil
+}
+
+func (h *heightfield) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return h.bounds(h.Nodes[0]), true
+}
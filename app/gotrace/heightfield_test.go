@@ -0,0 +1,124 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestHeightfield_Hit(t *testing.T) {
+	// A ramp rising one unit per unit of X, over 4x4 units.
+	ramp := make([]float64, 5*5)
+	for z := 0; z < 5; z++ {
+		for x := 0; x < 5; x++ {
+			ramp[z*5+x] = float64(x)
+		}
+	}
+	obj, err := NewHeightfield(5, 5, ramp, Vector3{}, Vector3{4, 1, 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		r          Ray
+		wantT      float64
+		wantNormal Vector3
+		wantU      float64
+		wantV      float64
+	}{
+		{"down", NewRay(Vector3{1.5, 10, 2.5}, Vector3{0, -1, 0}, 0), 8.5, Vector3{-1, 1, 0}.Normalize(), 0.375, 0.625},
+		{"along Z", NewRay(Vector3{3, 2.5, -10}, Vector3{0, 0, 1}, 0), -1, Vector3{}, 0, 0},
+		{"into the slope", NewRay(Vector3{-10, 2, 1}, Vector3{1, 0, 0}, 0), 12, Vector3{-1, 1, 0}.Normalize(), 0.5, 0.25},
+		{"outside", NewRay(Vector3{5, 10, 2}, Vector3{0, -1, 0}, 0), -1, Vector3{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := obj.Hit(tt.r, 0.001, math.Inf(1))
+			if tt.wantT < 0 {
+				if hr != nil {
+					t.Fatalf("Hit() = %v, want miss", hr.T)
+				}
+				return
+			}
+			if hr == nil {
+				t.Fatalf("Hit() missed, want %v", tt.wantT)
+			}
+			if math.Abs(hr.T-tt.wantT) > 1e-9 {
+				t.Errorf("Hit().T = %v, want %v", hr.T, tt.wantT)
+			}
+			if hr.Normal.Subtract(tt.wantNormal).Length() > 1e-9 {
+				t.Errorf("Hit().Normal = %v, want %v", hr.Normal, tt.wantNormal)
+			}
+			if math.Abs(hr.U-tt.wantU) > 1e-9 || math.Abs(hr.V-tt.wantV) > 1e-9 {
+				t.Errorf("Hit() UV = %v, %v, want %v, %v", hr.U, hr.V, tt.wantU, tt.wantV)
+			}
+		})
+	}
+}
+
+func TestHeightfield_MatchesEveryCell(t *testing.T) {
+	const width, depth = 13, 9
+	rng := rand.New(rand.NewSource(1))
+	heights := make([]float64, width*depth)
+	for i := range heights {
+		heights[i] = rng.Float64()
+	}
+	obj, err := NewHeightfield(width, depth, heights, Vector3{-2, 0, -1}, Vector3{6, 2, 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := obj.(*heightfield)
+	for i := 0; i < 200; i++ {
+		r := NewRay(Vector3{rng.Float64()*10 - 5, 3, rng.Float64()*10 - 5}, RandomVector(), 0)
+		want := math.Inf(1)
+		for z := 0; z+1 < depth; z++ {
+			for x := 0; x+1 < width; x++ {
+				if hr := h.hitCell(x, z, r, 0.001, want); hr != nil {
+					want = hr.T
+				}
+			}
+		}
+		hr := obj.Hit(r, 0.001, math.Inf(1))
+		switch {
+		case hr == nil && !math.IsInf(want, 1):
+			t.Errorf("ray %d: Hit() missed, want %v", i, want)
+		case hr != nil && math.Abs(hr.T-want) > 1e-9:
+			t.Errorf("ray %d: Hit().T = %v, want %v", i, hr.T, want)
+		}
+	}
+}
+
+func TestNewImageHeightfield(t *testing.T) {
+	im := image.NewGray16(image.Rect(0, 0, 3, 2))
+	im.SetGray16(2, 1, color.Gray16{Y: 0xffff})
+	obj, err := NewImageHeightfield(im, Vector3{}, Vector3{2, 5, 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, _ := obj.BoundingBox(0, 1)
+	if want := (aabb{Vector3{}, Vector3{2, 5, 1}}); box != want {
+		t.Errorf("BoundingBox() = %v, want %v", box, want)
+	}
+
+	if _, err := NewImageHeightfield(image.NewGray(image.Rect(0, 0, 1, 4)), Vector3{}, Vector3{1, 1, 1}, nil); err == nil {
+		t.Error("NewImageHeightfield() of a single column succeeded")
+	}
+}
@@ -21,8 +21,36 @@ type HitRecord struct {
 	P         Vector3
 	Normal    Vector3
 	T         float64
+	U         float64
+	V         float64
 	FrontFace bool
 	Material  Material
+
+	// DPDU and DPDV are how P moves as U and V increase, such as
+	// along the length of a curve, or zero where the surface does
+	// not say.  Together with the normal they make the tangent frame
+	// which bump and normal maps are applied in.
+	DPDU Vector3
+	DPDV Vector3
+
+	// Velocity is how fast the surface at P is moving, in scene
+	// units per unit of time.
+	Velocity Vector3
+
+	// ObjectID is one more than the index of the object hit within
+	// World.Objects, so that zero means nothing was hit.
+	ObjectID int
+}
+
+// Tangents returns DPDU and DPDV, or where the surface does not give
+// them, two which are perpendicular to the normal.  Those follow no
+// parameterization, so a bump or normal map on such a surface is
+// turned arbitrarily.
+func (hr *HitRecord) Tangents() (Vector3, Vector3) {
+	if NearZeroVector(hr.DPDU.Cross(hr.DPDV)) {
+		return orthonormalBasis(hr.Normal)
+	}
+	return hr.DPDU, hr.DPDV
 }
 
 // SetFaceNormal will calculate the proper values for Normal and
@@ -35,3 +63,24 @@ func (hr *HitRecord) SetFaceNormal(r Ray, outwardNormal Vector3) {
 		hr.Normal = outwardNormal.Neg()
 	}
 }
+
+// outwardNormal returns the normal on the outside of the surface,
+// whichever side the ray hit.
+func (hr *HitRecord) outwardNormal() Vector3 {
+	if hr.FrontFace {
+		return hr.Normal
+	}
+	return hr.Normal.Neg()
+}
+
+// withShadingNormal returns a copy of the hit with its normal replaced
+// by n, for a material to shade with.  A normal which would face away
+// from the ray, showing the back of the surface, is ignored.
+func (hr *HitRecord) withShadingNormal(r Ray, n Vector3) *HitRecord {
+	if n.Dot(r.Direction) >= 0 {
+		return hr
+	}
+	shaded := *hr
+	shaded.Normal = n
+	return &shaded
+}
@@ -20,4 +20,8 @@ package main
 // find out what color it should be.
 type Hittable interface {
 	Hit(r Ray, tMin float64, tMax float64) *HitRecord
+
+	// BoundingBox returns a box which encloses the object for the
+	// whole of the time interval, or false if the object is unbounded.
+	BoundingBox(time0 float64, time1 float64) (aabb, bool)
 }
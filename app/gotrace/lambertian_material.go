@@ -28,10 +28,15 @@ func NewLambertianMaterial(color Vector3) LambertianMaterial {
 }
 
 // Scatter calculates how rays should scatter from this material.
-func (m LambertianMaterial) Scatter(r Ray, hr *HitRecord) (bool, Ray, Vector3) {
-	scatterDirection := hr.Normal.Add(RandomUnitSphere())
+func (m LambertianMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	scatterDirection := hr.Normal.Add(SampleUnitSphere(smp.Get2D()))
 	if NearZeroVector(scatterDirection) {
 		scatterDirection = hr.Normal
 	}
-	return true, Ray{hr.P, scatterDirection, r.Time}, m.albedo
+	return true, r.Spawn(hr.P, scatterDirection), m.albedo
+}
+
+// Albedo returns the color of the material.
+func (m LambertianMaterial) Albedo(hr *HitRecord) Vector3 {
+	return m.albedo
 }
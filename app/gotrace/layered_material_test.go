@@ -0,0 +1,133 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestMixMaterial(t *testing.T) {
+	red := NewLambertianMaterial(Vector3{1, 0, 0})
+	mirror := NewReflectiveMaterial(Vector3{0, 0, 1}, 0)
+	im := image.NewGray(image.Rect(0, 0, 2, 1))
+	im.Set(1, 0, color.Gray{255})
+	worn := NewMixMaterial(red, mirror, NewImageTexture(im, true)).(AlbedoMaterial)
+
+	if got := worn.Albedo(&HitRecord{U: 0.25, V: 0.5}); got != (Vector3{1, 0, 0}) {
+		t.Errorf("Albedo() = %v, want red", got)
+	}
+	if got := worn.Albedo(&HitRecord{U: 0.75, V: 0.5}); got != (Vector3{0, 0, 1}) {
+		t.Errorf("Albedo() = %v, want blue", got)
+	}
+
+	hr := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+	in := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+	smp := NewIndependentSampler(1)
+	half := NewMixMaterial(red, mirror, NewSolidValue(0.25))
+	const n = 20000
+	var total Vector3
+	for i := 0; i < n; i++ {
+		if ok, _, attenuation := half.Scatter(in, hr, smp); ok {
+			total = total.Add(attenuation)
+		}
+	}
+	if mean := total.DivideScalar(n); mean.Subtract(Vector3{0.75, 0, 0.25}).Length() > 0.02 {
+		t.Errorf("Scatter() averages %v, want %v", mean, Vector3{0.75, 0, 0.25})
+	}
+}
+
+func TestCoatedMaterial_Scatter(t *testing.T) {
+	hr := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+	smp := NewIndependentSampler(1)
+	black := NewLambertianMaterial(Vector3{})
+	white := NewLambertianMaterial(Vector3{1, 1, 1})
+
+	// Over black, all that comes back is what the coat reflects, which
+	// grows toward grazing angles.
+	for _, deg := range []float64{0, 45, 80} {
+		sin, cos := math.Sincos(deg * math.Pi / 180)
+		in := NewRay(Vector3{-sin, cos, 0}, Vector3{sin, -cos, 0}, 0)
+		m := NewCoatedMaterial(black, 1.5, 0, Vector3{1, 1, 1})
+		const n = 20000
+		total := 0.0
+		for i := 0; i < n; i++ {
+			ok, out, attenuation := m.Scatter(in, hr, smp)
+			if !ok || attenuation.Y == 0 {
+				continue
+			}
+			if want := (Vector3{sin, cos, 0}); out.Direction.Normalize().Subtract(want).Length() > 1e-9 {
+				t.Fatalf("Scatter() at %v° = %v, want the mirror direction", deg, out.Direction)
+			}
+			total += attenuation.Y
+		}
+		want := fresnelDielectric(cos, 1.5)
+		if mean := total / n; math.Abs(mean-want) > 0.01 {
+			t.Errorf("Scatter() at %v° reflects %v, want %v", deg, mean, want)
+		}
+	}
+
+	// Over white, the rough coat and base together should not make
+	// light.
+	in := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+	for _, roughness := range []float64{0, 0.3, 1} {
+		m := NewCoatedMaterial(white, 1.5, roughness, Vector3{0.8, 0.6, 0.4})
+		const n = 20000
+		var total Vector3
+		for i := 0; i < n; i++ {
+			if ok, _, attenuation := m.Scatter(in, hr, smp); ok {
+				total = total.Add(attenuation)
+			}
+		}
+		mean := total.DivideScalar(n)
+		if mean.X > 1 || mean.Z >= mean.X || mean.Z < 0.1 {
+			t.Errorf("Scatter() with roughness %v averages %v", roughness, mean)
+		}
+	}
+}
+
+func TestLayeredMaterial_Interior(t *testing.T) {
+	glass := Medium{IOR: 1.5, Priority: 1}
+	water := Medium{IOR: 1.33, Priority: 2}
+	paint := NewLambertianMaterial(Vector3{0.5, 0.5, 0.5})
+	tests := []struct {
+		name     string
+		material Material
+		want     *Medium
+	}{
+		{"coated paint", NewCoatedMaterial(paint, 1.5, 0, Vector3{1, 1, 1}), nil},
+		{"coated glass", NewCoatedMaterial(NewMediumDielectricMaterial(glass), 1.5, 0, Vector3{1, 1, 1}), &glass},
+		{"mixed paint", NewMixMaterial(paint, paint, NewSolidValue(0.5)), nil},
+		{"mixed into glass", NewMixMaterial(paint, NewMediumDielectricMaterial(glass), NewSolidValue(0.5)), &glass},
+		{"mixed glass and water", NewMixMaterial(NewMediumDielectricMaterial(water), NewMediumDielectricMaterial(glass), NewSolidValue(0.5)), &water},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm, ok := tt.material.(MediumMaterial)
+			switch {
+			case tt.want == nil && ok:
+				t.Errorf("Interior() = %v, want none", mm.Interior())
+			case tt.want != nil && !ok:
+				t.Errorf("no Interior(), want %v", *tt.want)
+			case ok && mm.Interior() != *tt.want:
+				t.Errorf("Interior() = %v, want %v", mm.Interior(), *tt.want)
+			}
+		})
+	}
+}
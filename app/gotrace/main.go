@@ -17,16 +17,21 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image"
 	"image/png"
+	"io"
 	"log"
 	"math"
 	"math/rand"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
-	"sync"
+	"strings"
 
 	"github.com/pkg/profile"
 )
@@ -39,10 +44,8 @@ func check(e error, s string) {
 }
 
 const (
-	aspectRatio     = 16.0 / 9.0
-	imageWidth      = 1200
-	imageHeight     = int(float64(imageWidth) / aspectRatio)
-	samplesPerPixel = 50
+	defaultAspectRatio = 16.0 / 9.0
+	samplesPerPixel    = 50
 )
 
 var (
@@ -53,12 +56,34 @@ var (
 	r = math.Cos(math.Pi / 4)
 
 	world = World{
-		Camera:   NewCamera(lookFrom, lookAt, vup, 20, aspectRatio, 0.1, 10, 0.0, 1.0),
 		TMin:     0.001,
 		TMax:     math.MaxFloat64,
 		MaxDepth: 500,
 		Objects:  makeObjects(),
 	}
+
+	cameraAnimation = AnimatedCamera{
+		LookFrom: NewTrack(InterpolateSpline,
+			Keyframe{0, lookFrom},
+			Keyframe{1, Vector3{9, 2.5, 9}},
+			Keyframe{2, Vector3{-3, 3, 13}},
+		),
+		LookAt: NewTrack(InterpolateLinear,
+			Keyframe{0, lookAt},
+			Keyframe{2, Vector3{0, 1, 0}},
+		),
+		Vup: StaticTrack(vup),
+	}
+
+	// animateScene sets the objects moving for an animation.  Scenes
+	// loaded from a file hold still, so it is nil for them.
+	animateScene = animateObjects
+
+	pixelFilter  Filter
+	pixelSampler Sampler
+	aovList      []AOV
+	renderAOVs   []AOV
+	heatMaps     []CostKind
 )
 
 func makeObjects() []Hittable {
@@ -110,74 +135,67 @@ func makeObjects() []Hittable {
 	return objects
 }
 
-type processedLine struct {
-	y      int
-	colors []Vector3
-}
-
-type workItem struct {
-	y               int
-	imageHeight     int
-	imageWidth      int
-	samplesPerPixel int
-}
-
-func absorbLines(im *image.NRGBA, c chan processedLine) {
-	for line := range c {
-		log.Printf("Line %d of %d", line.y, imageHeight)
-		y := im.Rect.Dy() - line.y - 1
-		pixelOffset := (y-im.Rect.Min.Y)*im.Stride + (-im.Rect.Min.X)*4
-		for _, color := range line.colors {
-			im.Pix[pixelOffset] = uint8(color.X)
-			pixelOffset++
-			im.Pix[pixelOffset] = uint8(color.Y)
-			pixelOffset++
-			im.Pix[pixelOffset] = uint8(color.Z)
-			pixelOffset++
-			im.Pix[pixelOffset] = 0xff
-			pixelOffset++
-		}
-	}
-}
-
-func worker(workerID int, world World, wg *sync.WaitGroup, w chan workItem, c chan processedLine) {
-	defer wg.Done()
-	log.Printf("Worker %d starting...", workerID)
-	for work := range w {
-		renderLine(world, work, c)
+// animateObjects bounces the three large spheres, which are always the
+// last objects in the scene.
+func animateObjects(objects []Hittable) []Hittable {
+	ret := make([]Hittable, len(objects))
+	copy(ret, objects)
+	for i := len(ret) - 3; i < len(ret); i++ {
+		phase := float64(i-len(ret)+3) / 3
+		bounce := NewTrack(InterpolateSpline,
+			Keyframe{phase, Vector3{}},
+			Keyframe{phase + 0.5, Vector3{0, 1, 0}},
+			Keyframe{phase + 1, Vector3{}},
+		)
+		ret[i] = NewAnimatedObject(ret[i], bounce)
 	}
-	log.Printf("Worker %d ended.", workerID)
-}
-
-func renderLine(world World, work workItem, c chan processedLine) {
-	colors := make([]Vector3, 0, work.imageWidth)
-	for i := 0; i < work.imageWidth; i++ {
-		rgb := Vector3{}
-		for s := 0; s < work.samplesPerPixel; s++ {
-			v := (float64(work.y) + rand.Float64()) / float64(work.imageHeight-1)
-			u := (float64(i) + rand.Float64()) / float64(work.imageWidth-1)
-			ray := world.Camera.GetRay(u, v)
-			rgb = rgb.Add(world.Cast(ray, world.MaxDepth))
-		}
-		pixelColor := rgb.
-			DivideScalar(float64(work.samplesPerPixel)).
-			Gamma2().
-			Clamp(0, 0.999).
-			MultiplyScalar(256)
-
-		colors = append(colors, pixelColor)
-	}
-	c <- processedLine{work.y, colors}
+	return ret
 }
 
 var (
 	profileMemory = flag.Bool("profileMemory", false, "enable memory profiling")
 	profileCPU    = flag.Bool("profileCPU", false, "enable CPU profiling")
 	nCPU          = flag.Int("ncpu", runtime.NumCPU(), "Number of CPU cores to run on")
+	imageWidth    = flag.Int("width", 1200, "image width in pixels")
+	imageHeight   = flag.Int("height", 0, "image height in pixels, or 0 for a 16:9 image")
+	projection    = flag.String("projection", "perspective", "camera projection: perspective, orthographic, fisheye, equirectangular, or cubemap")
+	animate       = flag.Bool("animate", false, "render an animated sequence of frames")
+	frameStart    = flag.Int("frameStart", 1, "first frame to render when animating")
+	frameEnd      = flag.Int("frameEnd", 48, "last frame to render when animating")
+	frameRate     = flag.Float64("fps", 24, "frames per second when animating")
+	shutterAngle  = flag.Float64("shutterAngle", 180, "shutter angle in degrees when animating")
+	focalLength   = flag.Float64("focalLength", 0, "lens focal length in mm, which enables the physical camera")
+	fStop         = flag.Float64("fstop", 2.8, "lens f-number for the physical camera")
+	sensorWidth   = flag.Float64("sensorWidth", 36, "sensor width in mm for the physical camera")
+	bokeh         = flag.String("bokeh", "circle", "aperture shape: circle, polygon, or the name of an image file")
+	bokehBlades   = flag.Int("blades", 6, "number of aperture blades for polygon bokeh")
+	autoFocus     = flag.Bool("autofocus", false, "focus on the first object hit through the center of the image")
+	stereo        = flag.String("stereo", "none", "stereo layout: none, sbs (side-by-side), or ou (over-under)")
+	ipd           = flag.Float64("ipd", 0.064, "interpupillary distance for stereo, in scene units")
+	convergence   = flag.String("convergence", "off-axis", "stereo convergence: parallel, toe-in, or off-axis")
+	convergeAt    = flag.Float64("convergenceDistance", 10, "distance at which the stereo eyes converge")
+	filterName    = flag.String("filter", "box", "reconstruction filter: box, tent, gaussian, mitchell, or lanczos")
+	filterRadius  = flag.Float64("filterRadius", 0, "reconstruction filter radius in pixels, or 0 for the filter's usual radius")
+	samplerName   = flag.String("sampler", "sobol", "sample generator: independent, stratified, halton, sobol, or bluenoise")
+	aovNamesFlag  = flag.String("aov", "", "comma separated AOVs to write: depth, normal, albedo, objectid, materialid, uv, or motion")
+	aovFormat     = flag.String("aovFormat", "png", "AOV output format: png, one file per AOV, or exr, one multi-layer file")
+	denoise       = flag.Bool("denoise", false, "denoise the image, guided by albedo, normal, and depth")
+	denoisePasses = flag.Int("denoiseIterations", 5, "number of denoising passes, each reaching twice as far as the last")
+	heatMapNames  = flag.String("heatmap", "", "comma separated per-pixel cost heat maps to write: bounces, tests, or time")
+	statsFile     = flag.String("stats", "", "file to write the final statistics to as JSON")
+	serveAddr     = flag.String("serve", "", "address, such as :8080, to serve a live preview of the render on")
+	spectral      = flag.Bool("spectral", false, "trace wavelengths of light rather than RGB, so dispersion shows")
+	sceneFile     = flag.String("scene", "", "JSON scene file to render, rather than the built in scene")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "denoise" {
+		check(denoiseCommand(os.Args[2:]), "denoise: %v\n")
+		return
+	}
+
 	flag.Parse()
+	stopSetup := phaseTimer.Start("setup")
 
 	if *profileCPU && *profileMemory {
 		log.Fatal("Only one of -profileMemory or -profileCPU can be selected")
@@ -191,27 +209,338 @@ func main() {
 
 	log.Println("NumCPU", *nCPU)
 
-	im := image.NewNRGBA(image.Rect(0, 0, imageWidth, imageHeight))
+	if *imageHeight <= 0 {
+		*imageHeight = int(float64(*imageWidth) / defaultAspectRatio)
+	}
+
+	world.Spectral = *spectral
+	if *sceneFile != "" {
+		loadScene(*sceneFile)
+	}
+
+	pixelFilter = NewFilter(*filterName, *filterRadius)
+	if pixelFilter == nil {
+		log.Fatalf("Unknown filter %q", *filterName)
+	}
+
+	pixelSampler = NewSampler(*samplerName, samplesPerPixel)
+	if pixelSampler == nil {
+		log.Fatalf("Unknown sampler %q", *samplerName)
+	}
+
+	var err error
+	aovList, err = ParseAOVs(*aovNamesFlag)
+	check(err, "Error parsing -aov: %v\n")
+	heatMaps, err = ParseCostKinds(*heatMapNames)
+	check(err, "Error parsing -heatmap: %v\n")
+	if (len(aovList) > 0 || len(heatMaps) > 0) && *stereo != "none" {
+		log.Printf("AOVs and heat maps are not written for stereo renders")
+	}
+	if *stereo != "none" && *convergence == "off-axis" && *projection != "perspective" {
+		// Only a perspective lens can be shifted, so the eyes stay
+		// parallel.
+		log.Printf("Off-axis convergence needs the perspective projection; using parallel for %s", *projection)
+		*convergence = "parallel"
+	}
+	renderAOVs = aovList
+	if *denoise {
+		renderAOVs = addAOVs(renderAOVs, AOVAlbedo, AOVNormal, AOVDepth)
+	}
+
+	aperture, err := makeAperture()
+	check(err, "Error loading aperture image: %v\n")
+	cameraAnimation.Make = cameraMaker(aperture)
+
+	stopSetup()
+
+	if *serveAddr != "" {
+		go func() {
+			log.Fatal(http.ListenAndServe(*serveAddr, NewPreviewServer(renderProgress).Handler()))
+		}()
+		log.Printf("Serving the preview on http://%s/", *serveAddr)
+	}
+
+	if *animate {
+		renderAnimation()
+	} else {
+		im, linear, frame := renderView(world, lookFrom, lookAt, vup, 0, 1)
+		checkCancelled()
+		err = writeOutputs("out", im, linear, frame)
+		check(err, "Error writing to file: %v\n")
+	}
 
-	resultChan := make(chan processedLine, imageHeight)
-	workChan := make(chan workItem, imageHeight)
-	wg := sync.WaitGroup{}
-	for i := 0; i < *nCPU; i++ {
-		wg.Add(1)
-		go worker(i, world, &wg, workChan, resultChan)
+	report := NewRenderReport(phaseTimer.Phases(), renderProgress.WorkerStats())
+	fmt.Fprint(os.Stderr, report)
+	if *statsFile != "" {
+		err := writeFile(*statsFile, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		})
+		check(err, "Error writing statistics: %v\n")
 	}
 
-	go absorbLines(im, resultChan)
-	for j := 0; j < imageHeight; j++ {
-		workChan <- workItem{j, imageHeight, imageWidth, samplesPerPixel}
+	if *serveAddr != "" {
+		log.Printf("Render done, still serving the preview; interrupt to exit")
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		<-interrupt
 	}
-	close(workChan)
-	log.Printf("Waiting for workers to complete...")
-	wg.Wait()
-	close(resultChan)
+}
 
-	out, err := os.Create("out.png")
-	check(err, "Error writing to file: %v\n")
-	defer out.Close()
-	png.Encode(out, im)
+// checkCancelled exits if the render was cancelled, rather than write
+// out a partial image.
+func checkCancelled() {
+	if renderProgress.Cancelled() {
+		log.Fatal("Render cancelled")
+	}
+}
+
+// loadScene replaces the built in scene with the one in filename.
+func loadScene(filename string) {
+	scene, err := LoadScene(filename)
+	check(err, "Error reading scene: %v\n")
+	world.Objects, err = scene.Build(filepath.Dir(filename))
+	check(err, "Error building scene: %v\n")
+	if c := scene.Camera; c != nil {
+		lookFrom, lookAt, vup = sceneVector(c.LookFrom), sceneVector(c.LookAt), sceneVector(c.Up)
+		cameraAnimation.LookFrom = StaticTrack(lookFrom)
+		cameraAnimation.LookAt = StaticTrack(lookAt)
+		cameraAnimation.Vup = StaticTrack(vup)
+	}
+	animateScene = nil
+}
+
+// cameraMaker returns a CameraMaker for the camera selected by the
+// command line.
+func cameraMaker(aperture ApertureShape) CameraMaker {
+	aspectRatio := float64(*imageWidth) / float64(*imageHeight)
+	return func(from Vector3, at Vector3, up Vector3, time0 float64, time1 float64) Camera {
+		switch *projection {
+		case "orthographic":
+			// Show about what the perspective camera shows at the
+			// distance it is looking at.
+			height := 2 * math.Tan(10*math.Pi/180) * from.Subtract(at).Length()
+			return NewOrthographicCamera(from, at, up, height*aspectRatio, height, time0, time1)
+		case "fisheye":
+			return NewFisheyeCamera(from, at, up, 180, aspectRatio, time0, time1)
+		case "equirectangular":
+			return NewEquirectangularCamera(from, at, up, time0, time1)
+		case "cubemap":
+			return NewCubemapCamera(from, at, up, time0, time1)
+		case "perspective":
+		default:
+			log.Fatalf("Unknown projection %q", *projection)
+		}
+
+		var c PerspectiveCamera
+		if *focalLength > 0 {
+			c = PhysicalCamera{
+				LookFrom:      from,
+				LookAt:        at,
+				Vup:           up,
+				SensorWidth:   *sensorWidth,
+				SensorHeight:  *sensorWidth / aspectRatio,
+				FocalLength:   *focalLength,
+				FStop:         *fStop,
+				FocusDistance: 10,
+				Time0:         time0,
+				Time1:         time1,
+			}.Camera()
+		} else {
+			c = NewCamera(from, at, up, 20, aspectRatio, 0.1, 10, time0, time1)
+		}
+		c.Aperture = aperture
+		return c
+	}
+}
+
+func makeAperture() (ApertureShape, error) {
+	switch *bokeh {
+	case "circle":
+		return CircularAperture{}, nil
+	case "polygon":
+		return NewPolygonalAperture(*bokehBlades, 0), nil
+	default:
+		return LoadImageAperture(*bokeh)
+	}
+}
+
+// focusCamera focuses the camera on the scene if asked.  Only the
+// perspective camera has a focus to set.
+func focusCamera(c Camera, w World) Camera {
+	pc, ok := c.(PerspectiveCamera)
+	if !*autoFocus || !ok {
+		return c
+	}
+	if pc.AutoFocus(w) {
+		log.Printf("Focused at %.3f", pc.FocusDistance)
+	} else {
+		log.Printf("Nothing to focus on, focus left at %.3f", pc.FocusDistance)
+	}
+	return pc
+}
+
+// renderView renders the world as seen from lookFrom, either as one
+// image or as a stereo pair packed into one image.  The linear image
+// and the frame, with its AOVs and costs, are only returned for a
+// single view.
+func renderView(w World, lookFrom Vector3, lookAt Vector3, vup Vector3, time0 float64, time1 float64) (*image.NRGBA, []EXRChannel, *Frame) {
+	w = w.WithBVH(time0, time1)
+	if *stereo == "none" {
+		w.Camera = focusCamera(cameraAnimation.Make(lookFrom, lookAt, vup, time0, time1), w)
+		frame := renderImage(w)
+		if *denoise {
+			defer phaseTimer.Start("denoise")()
+			b := NewDenoiser(*denoisePasses).Denoise(NewDenoiseBuffers(frame))
+			return b.Image(), b.EXRChannels(), frame
+		}
+		return frame.Film.Image(), frame.Film.EXRChannels(), frame
+	}
+
+	rig := StereoRig{
+		InterpupillaryDistance: *ipd,
+		ConvergenceDistance:    *convergeAt,
+	}
+	switch *convergence {
+	case "parallel":
+		rig.Convergence = ConvergeParallel
+	case "toe-in":
+		rig.Convergence = ConvergeToeIn
+	case "off-axis":
+		rig.Convergence = ConvergeOffAxis
+	default:
+		log.Fatalf("Unknown convergence %q", *convergence)
+	}
+	layout := StereoSideBySide
+	switch *stereo {
+	case "sbs":
+	case "ou":
+		layout = StereoOverUnder
+	default:
+		log.Fatalf("Unknown stereo layout %q", *stereo)
+	}
+
+	leftCamera, rightCamera := rig.Eyes(cameraAnimation.Make, lookFrom, lookAt, vup, time0, time1)
+	log.Printf("Rendering left eye")
+	w.Camera = focusCamera(leftCamera, w)
+	left := renderEye(w)
+	log.Printf("Rendering right eye")
+	w.Camera = focusCamera(rightCamera, w)
+	right := renderEye(w)
+	return ComposeStereo(left, right, layout), nil, nil
+}
+
+func renderEye(w World) *image.NRGBA {
+	frame := renderImage(w)
+	if *denoise {
+		defer phaseTimer.Start("denoise")()
+		return NewDenoiser(*denoisePasses).Denoise(NewDenoiseBuffers(frame)).Image()
+	}
+	return frame.Film.Image()
+}
+
+func renderAnimation() {
+	if *frameRate <= 0 {
+		log.Fatal("-fps must be greater than zero")
+	}
+	if *shutterAngle < 0 || *shutterAngle > 360 {
+		log.Fatal("-shutterAngle must be between 0 and 360")
+	}
+	anim := Animation{
+		FrameStart:   *frameStart,
+		FrameEnd:     *frameEnd,
+		FrameRate:    *frameRate,
+		ShutterAngle: *shutterAngle,
+	}
+
+	frameWorld := animationWorld(world)
+	for frame := anim.FrameStart; frame <= anim.FrameEnd; frame++ {
+		filename := FrameFilename("out", frame)
+		if _, err := os.Stat(filename); err == nil {
+			log.Printf("Skipping frame %d, %s already exists", frame, filename)
+			continue
+		}
+		log.Printf("Rendering frame %d of %d", frame, anim.FrameEnd)
+		time0, time1 := anim.ShutterInterval(frame)
+		from, at, up := cameraAnimation.Placement((time0 + time1) / 2)
+		im, linear, f := renderView(frameWorld, from, at, up, time0, time1)
+		checkCancelled()
+		err := writeOutputs(strings.TrimSuffix(filename, ".png"), im, linear, f)
+		check(err, "Error writing to file: %v\n")
+	}
+}
+
+// animationWorld returns w with its objects set moving, if the scene
+// is animated.
+func animationWorld(w World) World {
+	if animateScene != nil {
+		w.Objects = animateScene(w.Objects)
+	}
+	return w
+}
+
+// writeOutputs writes the image to base.png, and any AOVs either as
+// one PNG each or along with the image into base.exr, and any heat
+// maps as base_heat_tests.png and so on.  The image is written last,
+// as its presence is what marks an animation frame done.
+func writeOutputs(base string, im *image.NRGBA, linear []EXRChannel, frame *Frame) error {
+	defer phaseTimer.Start("write")()
+	if frame != nil && frame.Costs != nil {
+		for _, k := range heatMaps {
+			if err := writePNG(base+"_heat_"+k.String()+".png", frame.Costs.HeatMap(k)); err != nil {
+				return err
+			}
+		}
+	}
+	if frame != nil && frame.AOVs != nil && len(aovList) > 0 {
+		aovs := frame.AOVs
+		switch *aovFormat {
+		case "exr":
+			b := aovs.Bounds
+			channels := linear
+			for _, a := range aovList {
+				channels = append(channels, aovs.EXRLayer(a)...)
+			}
+			err := writeFile(base+".exr", func(w io.Writer) error {
+				return WriteEXR(w, b.Dx(), b.Dy(), channels)
+			})
+			if err != nil {
+				return err
+			}
+		case "png":
+			for _, a := range aovList {
+				if err := writePNG(base+"_"+a.String()+".png", aovs.Image(a)); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unknown AOV format %q", *aovFormat)
+		}
+	}
+	return writePNG(base+".png", im)
+}
+
+func writePNG(filename string, im image.Image) error {
+	return writeFile(filename, func(w io.Writer) error {
+		return png.Encode(w, im)
+	})
+}
+
+// writeFile writes to a temporary file first, so an interrupted render
+// never leaves behind a partial image which would be skipped on rerun.
+func writeFile(filename string, encode func(w io.Writer) error) error {
+	tmp := filename + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := encode(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
 }
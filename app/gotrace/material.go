@@ -16,8 +16,74 @@
 
 package main
 
+import (
+	"reflect"
+	"sync"
+)
+
 // Material defines a generic material type that we can apply
 // to objects.
 type Material interface {
-	Scatter(r Ray, hr *HitRecord) (bool, Ray, Vector3)
+	Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3)
+}
+
+// AlbedoMaterial is implemented by materials which can say what their
+// base color is at a hit, for the albedo AOV.
+type AlbedoMaterial interface {
+	Albedo(hr *HitRecord) Vector3
+}
+
+var (
+	materialIDs     sync.Map
+	lastMaterialID  int64
+	materialIDMutex sync.Mutex
+)
+
+// MaterialID returns a number which identifies a material, for the
+// material ID AOV.  Materials which are equal get the same ID, and
+// IDs are handed out from 1 in the order materials are first seen.
+func MaterialID(m Material) int {
+	if m == nil {
+		return 0
+	}
+	var key interface{} = m
+	if !hashable(reflect.ValueOf(m)) {
+		key = reflect.TypeOf(m)
+	}
+	if id, ok := materialIDs.Load(key); ok {
+		return id.(int)
+	}
+	materialIDMutex.Lock()
+	defer materialIDMutex.Unlock()
+	if id, ok := materialIDs.Load(key); ok {
+		return id.(int)
+	}
+	lastMaterialID++
+	materialIDs.Store(key, int(lastMaterialID))
+	return int(lastMaterialID)
+}
+
+// hashable returns true if v can be used as a map key.  Unlike the
+// type's Comparable, this looks at what interface fields hold, such as
+// a material's textures.
+func hashable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Func:
+		return false
+	case reflect.Interface:
+		return v.IsNil() || hashable(v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !hashable(v.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !hashable(v.Field(i)) {
+				return false
+			}
+		}
+	}
+	return true
 }
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// Medium is what fills the inside of a closed dielectric object, such
+// as glass or water.
+type Medium struct {
+	IOR float64
+	// Dispersion, if set, replaces IOR in spectral mode.
+	Dispersion Dispersion
+	// Absorption is how much of each color is absorbed per unit of
+	// distance travelled through the medium.  Light is left with
+	// exp(-Absorption * distance) of each color, by the Beer-Lambert
+	// law.
+	Absorption Vector3
+	// Priority decides which medium fills space where objects
+	// overlap, such as where liquid is modelled slightly larger than
+	// the inside of its glass so that the two cannot leave a gap.
+	// Where they overlap, the medium with the higher priority wins,
+	// and the surfaces of the other are ignored.
+	Priority int
+}
+
+// AbsorptionFor returns the absorption which leaves light with the
+// given color after travelling distance through a medium, which is
+// easier to choose than the absorption itself.
+func AbsorptionFor(color Vector3, distance float64) Vector3 {
+	absorb := func(c float64) float64 {
+		return -math.Log(clamp(c, 1e-6, 1)) / distance
+	}
+	return Vector3{absorb(color.X), absorb(color.Y), absorb(color.Z)}
+}
+
+// dispersive reports whether the medium's index of refraction depends
+// on wavelength, for paths carrying w.
+func (m Medium) dispersive(w Wavelengths) bool {
+	return w.Active && !w.Collapsed && m.Dispersion.Model != DispersionNone
+}
+
+// iorFor returns the index of refraction at the hero wavelength of w
+// in spectral mode, and IOR otherwise.
+func (m Medium) iorFor(w Wavelengths) float64 {
+	if w.Active && m.Dispersion.Model != DispersionNone {
+		return m.Dispersion.IOR(w.Lambda[0])
+	}
+	return m.IOR
+}
+
+// transmittance returns how much of each color is left after light
+// travels distance through the medium.
+func (m Medium) transmittance(distance float64) Vector3 {
+	return Vector3{
+		math.Exp(-m.Absorption.X * distance),
+		math.Exp(-m.Absorption.Y * distance),
+		math.Exp(-m.Absorption.Z * distance),
+	}
+}
+
+// MediumMaterial is implemented by materials which bound a Medium, so
+// rays can track which media they are inside.
+type MediumMaterial interface {
+	Material
+	Interior() Medium
+}
+
+// interiorList is the media a ray is inside, most recently entered
+// first.  It is never changed, only replaced, so rays can share it.
+type interiorList struct {
+	Medium Medium
+	Next   *interiorList
+}
+
+// with returns the list after entering m.
+func (l *interiorList) with(m Medium) *interiorList {
+	return &interiorList{m, l}
+}
+
+// without returns the list after leaving m.
+func (l *interiorList) without(m Medium) *interiorList {
+	if l == nil {
+		return nil
+	}
+	if l.Medium == m {
+		return l.Next
+	}
+	return &interiorList{l.Medium, l.Next.without(m)}
+}
+
+// current returns the medium which fills the space the ray is in: the
+// one with the highest priority, or of those the most recently
+// entered.  It returns false in empty space.
+func (l *interiorList) current() (Medium, bool) {
+	if l == nil {
+		return Medium{}, false
+	}
+	best := l.Medium
+	for n := l.Next; n != nil; n = n.Next {
+		if n.Medium.Priority > best.Priority {
+			best = n.Medium
+		}
+	}
+	return best, true
+}
+
+// ior returns the index of refraction of the space the ray is in,
+// and whether it depends on wavelength.
+func (l *interiorList) ior(w Wavelengths) (float64, bool) {
+	if m, ok := l.current(); ok {
+		return m.iorFor(w), m.dispersive(w)
+	}
+	return 1, false
+}
+
+// falseHit reports whether a surface of m is inside a medium of higher
+// priority, and so should be passed through as if it were not there.
+func (l *interiorList) falseHit(m Medium) bool {
+	other, ok := l.without(m).current()
+	return ok && other.Priority > m.Priority
+}
+
+// cross returns the list after passing through a surface of m, into it
+// if entering is true.
+func (l *interiorList) cross(m Medium, entering bool) *interiorList {
+	if entering {
+		return l.with(m)
+	}
+	return l.without(m)
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInteriorList(t *testing.T) {
+	glass := Medium{IOR: 1.5, Priority: 1}
+	water := Medium{IOR: 1.33, Priority: 2}
+	air := Medium{IOR: 1}
+
+	var l *interiorList
+	if got, _ := l.ior(Wavelengths{}); got != 1 {
+		t.Errorf("ior() of empty space = %v, want 1", got)
+	}
+	l = l.with(glass).with(water)
+	if got, _ := l.current(); got != water {
+		t.Errorf("current() = %v, want water", got)
+	}
+	if !l.falseHit(air) {
+		t.Error("falseHit() of a bubble of lower priority in the water = false")
+	}
+	if l.falseHit(water) {
+		t.Error("falseHit() of the water's own surface = true")
+	}
+	if !l.falseHit(glass) {
+		t.Error("falseHit() of the glass within the water = false")
+	}
+	l = l.without(water)
+	if got, _ := l.current(); got != glass {
+		t.Errorf("current() after leaving the water = %v, want glass", got)
+	}
+	if l.without(glass) != nil {
+		t.Error("without() the last medium is not empty")
+	}
+}
+
+func TestAbsorptionFor(t *testing.T) {
+	color := Vector3{0.9, 0.5, 0.1}
+	m := Medium{IOR: 1.5, Absorption: AbsorptionFor(color, 2)}
+	if got := m.transmittance(2); got.Subtract(color).Length() > 1e-12 {
+		t.Errorf("transmittance(2) = %v, want %v", got, color)
+	}
+	if got := m.transmittance(4); got.Subtract(color.Multiply(color)).Length() > 1e-12 {
+		t.Errorf("transmittance(4) = %v, want %v", got, color.Multiply(color))
+	}
+}
+
+func TestWorld_CastThroughMedia(t *testing.T) {
+	// With an index of refraction of one, rays pass straight through
+	// the center without reflecting, so all they see is the sky
+	// behind, dimmed by what they pass through.
+	outer := Medium{IOR: 1, Absorption: Vector3{0.1, 0.2, 0.3}, Priority: 1}
+	inner := Medium{IOR: 1, Absorption: Vector3{0.3, 0.2, 0.1}, Priority: 2}
+	bubble := Medium{IOR: 1, Absorption: Vector3{1, 1, 1}, Priority: 0}
+	sky := Vector3{0.75, 0.85, 1}
+	tests := []struct {
+		name    string
+		objects []Hittable
+		want    Vector3
+	}{
+		{
+			"one",
+			[]Hittable{NewSphere(Vector3{}, 2, NewMediumDielectricMaterial(outer))},
+			sky.Multiply(outer.transmittance(4)),
+		},
+		{
+			"nested",
+			[]Hittable{
+				NewSphere(Vector3{}, 2, NewMediumDielectricMaterial(outer)),
+				NewSphere(Vector3{}, 1.5, NewMediumDielectricMaterial(inner)),
+			},
+			sky.Multiply(outer.transmittance(1)).Multiply(inner.transmittance(3)),
+		},
+		{
+			"lower priority ignored",
+			[]Hittable{
+				NewSphere(Vector3{}, 2, NewMediumDielectricMaterial(outer)),
+				NewSphere(Vector3{}, 1.5, NewMediumDielectricMaterial(bubble)),
+			},
+			sky.Multiply(outer.transmittance(4)),
+		},
+		{
+			"rough",
+			[]Hittable{
+				NewSphere(Vector3{}, 2, NewMediumRoughDielectricMaterial(outer, 0)),
+				NewSphere(Vector3{}, 1.5, NewMediumRoughDielectricMaterial(inner, 0)),
+			},
+			sky.Multiply(outer.transmittance(1)).Multiply(inner.transmittance(3)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := World{Objects: tt.objects, MaxDepth: 10, TMin: 0.001, TMax: math.Inf(1)}
+			got := w.Cast(NewRay(Vector3{0, 0, 5}, Vector3{0, 0, -1}, 0), w.MaxDepth, NewIndependentSampler(1))
+			if got.Subtract(tt.want).Length() > 1e-9 {
+				t.Errorf("Cast() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDielectricMaterial_NestedRefraction(t *testing.T) {
+	glass := Medium{IOR: 1.5, Priority: 1}
+	water := Medium{IOR: 1.33, Priority: 2}
+	// Inside the glass, entering the water at 45 degrees.
+	r := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+	r.interior = r.interior.with(glass)
+	hr := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+	want := math.Sqrt2 / 2 * 1.5 / 1.33
+	smp := NewIndependentSampler(1)
+	for i := 0; i < 100; i++ {
+		_, out, _ := NewMediumDielectricMaterial(water).Scatter(r, hr, smp)
+		d := out.Direction.Normalize()
+		if d.Y > 0 {
+			continue
+		}
+		if math.Abs(d.X-want) > 1e-9 {
+			t.Fatalf("Scatter() = %v, want sin %v", d, want)
+		}
+		if got, _ := out.interior.current(); got != water {
+			t.Fatalf("Scatter() into %v, want water", got)
+		}
+		return
+	}
+	t.Error("Scatter() never refracted")
+}
+
+func TestRoughRefraction_NestedMedia(t *testing.T) {
+	water := NewPrincipledMaterial(Vector3{1, 1, 1})
+	water.Roughness = NewSolidValue(0)
+	water.Transmission = NewSolidValue(1)
+	water.IOR = 1.33
+	tests := []struct {
+		name     string
+		material MediumMaterial
+	}{
+		{"rough dielectric", NewMediumRoughDielectricMaterial(Medium{IOR: 1.33}, 0)},
+		{"principled", water},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Inside glass, entering the water at 45 degrees.
+			r := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+			r.interior = r.interior.with(Medium{IOR: 1.5})
+			hr := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+			want := math.Sqrt2 / 2 * 1.5 / 1.33
+			smp := NewIndependentSampler(1)
+			for i := 0; i < 100; i++ {
+				_, out, _ := tt.material.Scatter(r, hr, smp)
+				d := out.Direction.Normalize()
+				if d.Y > 0 {
+					continue
+				}
+				if math.Abs(d.X-want) > 1e-9 {
+					t.Fatalf("Scatter() = %v, want sin %v", d, want)
+				}
+				if got, _ := out.interior.current(); got != tt.material.Interior() {
+					t.Fatalf("Scatter() into %v, want %v", got, tt.material.Interior())
+				}
+				return
+			}
+			t.Error("Scatter() never refracted")
+		})
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Metaball is one source of a blobby field.  Its influence falls
+// smoothly from Strength at the center to nothing at Radius.  A
+// negative strength carves into the other balls.
+type Metaball struct {
+	Center   Vector3
+	Radius   float64
+	Strength float64
+}
+
+type metaballs struct {
+	Balls     []Metaball
+	Threshold float64
+	Material  Material
+}
+
+// NewMetaballs returns the surface where the summed field of the balls
+// reaches threshold.  Each ball's field is (1 - r^2/R^2)^2, so along a
+// ray the sum is a quartic between the points where balls start or
+// stop contributing, and is solved exactly rather than marched.
+func NewMetaballs(balls []Metaball, threshold float64, mat Material) Solid {
+	box := aabb{Vector3{}, Vector3{}}
+	first := true
+	for _, b := range balls {
+		if b.Strength <= 0 {
+			continue
+		}
+		r := Vector3{b.Radius, b.Radius, b.Radius}
+		ballBox := aabb{b.Center.Subtract(r), b.Center.Add(r)}
+		if first {
+			box, first = ballBox, false
+		} else {
+			box = surroundingBox(box, ballBox)
+		}
+	}
+	m := metaballs{append([]Metaball(nil), balls...), threshold, mat}
+	return closedSurface{Parts: []crosser{m}, Box: box}
+}
+
+func (m metaballs) crossings(r Ray) []*HitRecord {
+	r.countIntersectionTest()
+	length := r.Direction.Length()
+	d := r.Direction.DivideScalar(length)
+
+	// Find the stretch of the line, in distance along d, each ball
+	// affects.
+	type span struct {
+		ball       Metaball
+		enter, out float64
+	}
+	var spans []span
+	var events []float64
+	for _, b := range m.Balls {
+		oc := r.Origin.Subtract(b.Center)
+		bHalf := oc.Dot(d)
+		discriminant := bHalf*bHalf - oc.LengthSquared() + b.Radius*b.Radius
+		if discriminant <= 0 {
+			continue
+		}
+		sqrtd := math.Sqrt(discriminant)
+		spans = append(spans, span{b, -bHalf - sqrtd, -bHalf + sqrtd})
+		events = append(events, -bHalf-sqrtd, -bHalf+sqrtd)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Float64s(events)
+
+	// Work from the middle of the affected stretch, so the quartics
+	// are well conditioned however far away the ray starts.
+	mid := (events[0] + events[len(events)-1]) / 2
+	o := r.Origin.Add(d.MultiplyScalar(mid))
+
+	var ret []*HitRecord
+	for i := 0; i+1 < len(events); i++ {
+		lo, hi := events[i], events[i+1]
+		if lo == hi {
+			continue
+		}
+		// Sum the quartics of every ball active over this stretch.
+		var c [5]float64
+		c[0] = -m.Threshold
+		for _, s := range spans {
+			if s.enter > lo || s.out < hi {
+				continue
+			}
+			rr := s.ball.Radius * s.ball.Radius
+			oc := o.Subtract(s.ball.Center)
+			qa := -1 / rr
+			qb := -2 * oc.Dot(d) / rr
+			qc := 1 - oc.LengthSquared()/rr
+			c[4] += s.ball.Strength * qa * qa
+			c[3] += s.ball.Strength * 2 * qa * qb
+			c[2] += s.ball.Strength * (qb*qb + 2*qa*qc)
+			c[1] += s.ball.Strength * 2 * qb * qc
+			c[0] += s.ball.Strength * qc * qc
+		}
+		var roots []float64
+		switch {
+		case c[4] != 0:
+			roots = solveQuartic(c[4], c[3], c[2], c[1], c[0])
+		case c[3] != 0:
+			roots = solveCubic(c[2]/c[3], c[1]/c[3], c[0]/c[3])
+		default:
+			roots = solveQuadratic(c[2], c[1], c[0])
+		}
+		for _, root := range roots {
+			s := root + mid
+			if s < lo || s >= hi {
+				continue
+			}
+			p := r.Origin.Add(d.MultiplyScalar(s))
+			normal := m.normal(p)
+			// U and V come from the direction of the normal, which has
+			// no tangents to give, so DPDU and DPDV are left zero and
+			// Tangents falls back to an arbitrary frame.
+			u, v := sphereUV(normal)
+			ret = append(ret, &HitRecord{
+				T:        s / length,
+				P:        p,
+				Normal:   normal,
+				U:        u,
+				V:        v,
+				Material: m.Material,
+			})
+		}
+	}
+	return ret
+}
+
+// normal returns the outward normal at p, down the slope of the field.
+func (m metaballs) normal(p Vector3) Vector3 {
+	var gradient Vector3
+	for _, b := range m.Balls {
+		rr := b.Radius * b.Radius
+		pc := p.Subtract(b.Center)
+		q := 1 - pc.LengthSquared()/rr
+		if q <= 0 {
+			continue
+		}
+		gradient = gradient.Add(pc.MultiplyScalar(4 * b.Strength * q / rr))
+	}
+	return gradient.Normalize()
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMetaballs_Hit(t *testing.T) {
+	fromX := func(y float64) Ray {
+		return NewRay(Vector3{-10, y, 0}, Vector3{2, 0, 0}, 0)
+	}
+	// A lone ball of radius 2 at threshold 1/4 has its surface at
+	// sqrt(2).
+	lone := []Metaball{{Vector3{}, 2, 1}}
+	// A ball of strength -1 carves the near side of the lone ball back
+	// to where (1 - x^2/4)^2 - (1 - (x+1)^2)^2 = 1/4, found by
+	// bisection.
+	lo, hi := -1.0, 0.0
+	for i := 0; i < 100; i++ {
+		x := (lo + hi) / 2
+		a, b := 1-x*x/4, 1-(x+1)*(x+1)
+		if a*a-b*b < 0.25 {
+			lo = x
+		} else {
+			hi = x
+		}
+	}
+	carved := lo
+	// Two balls each too weak to reach threshold 1 alone, but which
+	// reach it together between them.
+	pair := []Metaball{{Vector3{0, -1, 0}, 2, 0.8}, {Vector3{0, 1, 0}, 2, 0.8}}
+	tests := []struct {
+		name       string
+		balls      []Metaball
+		threshold  float64
+		r          Ray
+		wantT      float64
+		wantNormal Vector3
+	}{
+		{"lone", lone, 0.25, fromX(0), (10 - math.Sqrt2) / 2, Vector3{-1, 0, 0}},
+		{"lone misses", lone, 0.25, fromX(1.5), -1, Vector3{}},
+		{"pair joins", pair, 0.5, fromX(0), (10 - math.Sqrt(4*(1-math.Sqrt(0.3125))-1)) / 2, Vector3{-1, 0, 0}},
+		{"pair too weak", pair, 1, fromX(0), -1, Vector3{}},
+		{"carved", append(lone, Metaball{Vector3{-1, 0, 0}, 1, -1}), 0.25, fromX(0), (10 + carved) / 2, Vector3{-1, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := NewMetaballs(tt.balls, tt.threshold, nil).Hit(tt.r, 0.001, math.Inf(1))
+			if tt.wantT < 0 {
+				if hr != nil {
+					t.Fatalf("Hit() = %v, want miss", hr.T)
+				}
+				return
+			}
+			if hr == nil {
+				t.Fatalf("Hit() missed, want %v", tt.wantT)
+			}
+			if math.Abs(hr.T-tt.wantT) > 1e-9 {
+				t.Errorf("Hit().T = %v, want %v", hr.T, tt.wantT)
+			}
+			if hr.Normal.Subtract(tt.wantNormal).Length() > 1e-9 {
+				t.Errorf("Hit().Normal = %v, want %v", hr.Normal, tt.wantNormal)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// The microfacet materials work in a local shading frame, where the
+// surface normal is +Z.
+
+// shadingFrame returns a frame around the unit normal n.
+type shadingFrame struct {
+	S, T, N Vector3
+}
+
+func newShadingFrame(n Vector3) shadingFrame {
+	s, t := orthonormalBasis(n)
+	return shadingFrame{s, t, n}
+}
+
+// toLocal returns v in the frame.
+func (f shadingFrame) toLocal(v Vector3) Vector3 {
+	return Vector3{v.Dot(f.S), v.Dot(f.T), v.Dot(f.N)}
+}
+
+// fromLocal returns the world vector for v given in the frame.
+func (f shadingFrame) fromLocal(v Vector3) Vector3 {
+	return f.S.MultiplyScalar(v.X).Add(f.T.MultiplyScalar(v.Y)).Add(f.N.MultiplyScalar(v.Z))
+}
+
+// roughnessToAlpha maps a perceptual roughness, from 0 for a mirror to
+// 1 for very rough, to GGX alpha, so equal steps in roughness look
+// like equal steps in blur.
+func roughnessToAlpha(roughness float64) float64 {
+	r := clamp(roughness, 0, 1)
+	return r * r
+}
+
+// smoothAlpha is the alpha below which a surface is treated as
+// perfectly smooth, as GGX sampling breaks down there.
+const smoothAlpha = 1e-4
+
+// ggxLambda is Smith's Lambda for GGX, for the local direction w.
+func ggxLambda(w Vector3, alpha float64) float64 {
+	cos2 := w.Z * w.Z
+	if cos2 == 0 {
+		return math.Inf(1)
+	}
+	tan2 := (1 - cos2) / cos2
+	return (math.Sqrt(1+alpha*alpha*tan2) - 1) / 2
+}
+
+// ggxG1 is the fraction of microfacets visible from w.
+func ggxG1(w Vector3, alpha float64) float64 {
+	return 1 / (1 + ggxLambda(w, alpha))
+}
+
+// ggxG2 is the fraction of microfacets visible from both wo and wi.
+func ggxG2(wo Vector3, wi Vector3, alpha float64) float64 {
+	return 1 / (1 + ggxLambda(wo, alpha) + ggxLambda(wi, alpha))
+}
+
+// sampleGGXVisibleNormal picks a microfacet normal in proportion to
+// how much of the surface it covers as seen from wo, which must be
+// above the surface, following Heitz, "Sampling the GGX Distribution
+// of Visible Normals".  Scattering off the chosen facet then only
+// needs weighting by G2 / G1, which stays close to one.
+func sampleGGXVisibleNormal(wo Vector3, alpha float64, u1 float64, u2 float64) Vector3 {
+	// Stretch the view so the facets form a hemisphere.
+	vh := Vector3{alpha * wo.X, alpha * wo.Y, wo.Z}.Normalize()
+	lensq := vh.X*vh.X + vh.Y*vh.Y
+	t1 := Vector3{1, 0, 0}
+	if lensq > 0 {
+		t1 = Vector3{-vh.Y, vh.X, 0}.DivideScalar(math.Sqrt(lensq))
+	}
+	t2 := vh.Cross(t1)
+
+	// Pick a point on the projected hemisphere.
+	r := math.Sqrt(u1)
+	phi := 2 * math.Pi * u2
+	p1 := r * math.Cos(phi)
+	p2 := r * math.Sin(phi)
+	s := 0.5 * (1 + vh.Z)
+	p2 = (1-s)*math.Sqrt(1-p1*p1) + s*p2
+
+	nh := t1.MultiplyScalar(p1).Add(t2.MultiplyScalar(p2)).
+		Add(vh.MultiplyScalar(math.Sqrt(math.Max(0, 1-p1*p1-p2*p2))))
+	// Unstretch.
+	return Vector3{alpha * nh.X, alpha * nh.Y, math.Max(1e-6, nh.Z)}.Normalize()
+}
+
+// reflectLocal mirrors w about the facet normal m.
+func reflectLocal(w Vector3, m Vector3) Vector3 {
+	return m.MultiplyScalar(2 * w.Dot(m)).Subtract(w)
+}
+
+// refractLocal bends w, leaving from the side m faces, through a facet
+// with normal m, where eta is the index of refraction on the far side
+// over that on w's side.  It returns false on total internal
+// reflection.
+func refractLocal(w Vector3, m Vector3, eta float64) (Vector3, bool) {
+	cosI := w.Dot(m)
+	sin2T := (1 - cosI*cosI) / (eta * eta)
+	if sin2T >= 1 {
+		return Vector3{}, false
+	}
+	cosT := math.Sqrt(1 - sin2T)
+	return w.Neg().DivideScalar(eta).Add(m.MultiplyScalar(cosI/eta - cosT)), true
+}
+
+// fresnelDielectric is the exact fraction of unpolarized light
+// reflected by a boundary, where cosI is the cosine of the incident
+// angle and eta is the index of refraction on the far side over that
+// on the near side.
+func fresnelDielectric(cosI float64, eta float64) float64 {
+	cosI = clamp(cosI, 0, 1)
+	sin2T := (1 - cosI*cosI) / (eta * eta)
+	if sin2T >= 1 {
+		return 1
+	}
+	cosT := math.Sqrt(1 - sin2T)
+	rs := (cosI - eta*cosT) / (cosI + eta*cosT)
+	rp := (eta*cosI - cosT) / (eta*cosI + cosT)
+	return (rs*rs + rp*rp) / 2
+}
+
+// fresnelConductor is the fraction of unpolarized light reflected by a
+// metal with complex index of refraction eta + ik, per channel.
+func fresnelConductor(cosI float64, eta Vector3, k Vector3) Vector3 {
+	return Vector3{
+		fresnelConductor1(cosI, eta.X, k.X),
+		fresnelConductor1(cosI, eta.Y, k.Y),
+		fresnelConductor1(cosI, eta.Z, k.Z),
+	}
+}
+
+func fresnelConductor1(cosI float64, eta float64, k float64) float64 {
+	cosI = clamp(cosI, 0, 1)
+	cos2 := cosI * cosI
+	sin2 := 1 - cos2
+	t0 := eta*eta - k*k - sin2
+	a2b2 := math.Sqrt(t0*t0 + 4*eta*eta*k*k)
+	a := math.Sqrt(math.Max(0, (a2b2+t0)/2))
+	t1 := a2b2 + cos2
+	t2 := 2 * cosI * a
+	rs := (t1 - t2) / (t1 + t2)
+	t3 := cos2*a2b2 + sin2*sin2
+	t4 := t2 * sin2
+	rp := rs * (t3 - t4) / (t3 + t4)
+	return (rs + rp) / 2
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFresnel(t *testing.T) {
+	if got := fresnelDielectric(1, 1.5); math.Abs(got-0.04) > 1e-12 {
+		t.Errorf("fresnelDielectric(1, 1.5) = %v, want 0.04", got)
+	}
+	if got := fresnelDielectric(0.1, 1/1.5); got != 1 {
+		t.Errorf("fresnelDielectric() past the critical angle = %v, want 1", got)
+	}
+	if got := fresnelDielectric(0, 1.5); got != 1 {
+		t.Errorf("fresnelDielectric() at grazing = %v, want 1", got)
+	}
+	for _, ior := range []ComplexIOR{Gold, Copper, Aluminum, Silver} {
+		got := fresnelConductor(1, ior.Eta, ior.K)
+		n, k := ior.Eta.Y, ior.K.Y
+		want := ((n-1)*(n-1) + k*k) / ((n+1)*(n+1) + k*k)
+		if math.Abs(got.Y-want) > 1e-9 {
+			t.Errorf("fresnelConductor(%v) head on = %v, want %v", ior, got.Y, want)
+		}
+		if g := fresnelConductor(0, ior.Eta, ior.K); math.Abs(g.X-1) > 1e-9 {
+			t.Errorf("fresnelConductor(%v) at grazing = %v, want 1", ior, g)
+		}
+	}
+}
+
+// ggxD is the GGX distribution of microfacet normals.
+func ggxD(m Vector3, alpha float64) float64 {
+	if m.Z <= 0 {
+		return 0
+	}
+	cos2 := m.Z * m.Z
+	tan2 := (1 - cos2) / cos2
+	x := alpha*alpha + tan2
+	return alpha * alpha / (math.Pi * cos2 * cos2 * x * x)
+}
+
+// TestSampleGGXVisibleNormal checks that the reflectance of a perfect
+// mirror microsurface found by visible normal sampling, the way the
+// materials use it, agrees with brute force integration of the BRDF.
+func TestSampleGGXVisibleNormal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 200000
+	for _, alpha := range []float64{0.1, 0.5, 0.9} {
+		for _, theta := range []float64{0, 0.7, 1.4} {
+			wo := Vector3{math.Sin(theta), 0, math.Cos(theta)}
+
+			sampled := 0.0
+			for i := 0; i < n; i++ {
+				m := sampleGGXVisibleNormal(wo, alpha, rng.Float64(), rng.Float64())
+				if m.Z <= 0 || wo.Dot(m) < -1e-9 {
+					t.Fatalf("sampleGGXVisibleNormal(%v) = %v, facing away", wo, m)
+				}
+				if wi := reflectLocal(wo, m); wi.Z > 0 {
+					sampled += ggxG2(wo, wi, alpha) / ggxG1(wo, alpha)
+				}
+			}
+			sampled /= n
+
+			// Integrate over the hemisphere, uniformly.
+			brute := 0.0
+			for i := 0; i < n; i++ {
+				wi := SampleUnitSphere(rng.Float64(), rng.Float64())
+				wi.Z = math.Abs(wi.Z)
+				h := wo.Add(wi).Normalize()
+				f := ggxD(h, alpha) * ggxG2(wo, wi, alpha) / (4 * wo.Z * wi.Z)
+				brute += f * wi.Z * 2 * math.Pi
+			}
+			brute /= n
+
+			if math.Abs(sampled-brute) > 0.02 {
+				t.Errorf("alpha %v, theta %v: sampled reflectance %v, integrated %v", alpha, theta, sampled, brute)
+			}
+		}
+	}
+}
+
+func TestConductorMaterial_Scatter(t *testing.T) {
+	hr := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+	in := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+	smp := NewIndependentSampler(1)
+
+	ok, out, attenuation := NewConductorMaterial(Gold, 0).Scatter(in, hr, smp)
+	if !ok || out.Direction.Subtract(Vector3{1, 1, 0}.Normalize()).Length() > 1e-9 {
+		t.Errorf("Scatter() of polished gold = %v, want the mirror direction", out.Direction)
+	}
+	if want := fresnelConductor(math.Sqrt2/2, Gold.Eta, Gold.K); attenuation.Subtract(want).Length() > 1e-9 {
+		t.Errorf("Scatter() of polished gold = %v, want %v", attenuation, want)
+	}
+
+	rough := NewConductorMaterial(Aluminum, 0.6)
+	for i := 0; i < 1000; i++ {
+		ok, out, attenuation := rough.Scatter(in, hr, smp)
+		if !ok {
+			continue
+		}
+		if out.Direction.Y <= 0 {
+			t.Fatalf("Scatter() = %v, into the surface", out.Direction)
+		}
+		if attenuation.X > 1 || attenuation.Y > 1 || attenuation.Z > 1 {
+			t.Fatalf("Scatter() = %v, more than arrived", attenuation)
+		}
+	}
+}
+
+func TestRoughDielectricMaterial_Scatter(t *testing.T) {
+	in := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+	smp := NewIndependentSampler(1)
+
+	// Smooth glass refracts by Snell's law.
+	entering := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+	clear := NewRoughDielectricMaterial(1.5, 0)
+	refracted := 0
+	for i := 0; i < 100; i++ {
+		_, out, _ := clear.Scatter(in, entering, smp)
+		d := out.Direction.Normalize()
+		if d.Y < 0 {
+			refracted++
+			if sinT := d.X; math.Abs(sinT-math.Sqrt2/2/1.5) > 1e-9 {
+				t.Fatalf("Scatter() = %v, want sin %v", d, math.Sqrt2/2/1.5)
+			}
+		}
+	}
+	if refracted < 80 {
+		t.Errorf("Scatter() refracted %d of 100, want most", refracted)
+	}
+
+	// Leaving beyond the critical angle always reflects.
+	leaving := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: false}
+	grazing := NewRay(Vector3{-1, 0.2, 0}, Vector3{1, -0.2, 0}, 0)
+	for i := 0; i < 100; i++ {
+		if _, out, _ := clear.Scatter(grazing, leaving, smp); out.Direction.Y <= 0 {
+			t.Fatalf("Scatter() = %v, want total internal reflection", out.Direction)
+		}
+	}
+
+	frosted := NewRoughDielectricMaterial(1.5, 0.5)
+	for i := 0; i < 1000; i++ {
+		if ok, _, attenuation := frosted.Scatter(in, entering, smp); ok && attenuation.X > 1 {
+			t.Fatalf("Scatter() = %v, more than arrived", attenuation)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// MixMaterial is a blend of two materials, such as worn paint with
+// metal showing through.  Each scatter uses one or the other, picked
+// at random in proportion to the weight, so on average the surface
+// looks like the blend.
+type MixMaterial struct {
+	a      Material
+	b      Material
+	weight Texture
+}
+
+// mixMedium is a blend in which a material has a medium inside, which
+// it keeps, so that it still nests with other media.
+type mixMedium struct {
+	MixMaterial
+	medium Medium
+}
+
+// NewMixMaterial returns a blend which is all a where the first channel
+// of weight is 0, and all b where it is 1.  If either material has a
+// medium inside, the blend has it too, and if both do, a's.
+func NewMixMaterial(a Material, b Material, weight Texture) Material {
+	m := MixMaterial{a: a, b: b, weight: weight}
+	for _, mat := range []Material{a, b} {
+		if mm, ok := mat.(MediumMaterial); ok {
+			return mixMedium{m, mm.Interior()}
+		}
+	}
+	return m
+}
+
+// amount returns how much of b there is at the hit.
+func (m MixMaterial) amount(hr *HitRecord) float64 {
+	return clamp(m.weight.Value(hr.U, hr.V, hr.P).X, 0, 1)
+}
+
+// Scatter scatters off one of the materials.
+func (m MixMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	if smp.Get1D() < m.amount(hr) {
+		return m.b.Scatter(r, hr, smp)
+	}
+	return m.a.Scatter(r, hr, smp)
+}
+
+// Albedo returns the blend of the materials' colors.  A material which
+// has no albedo counts as black.
+func (m MixMaterial) Albedo(hr *HitRecord) Vector3 {
+	albedo := func(mat Material) Vector3 {
+		if am, ok := mat.(AlbedoMaterial); ok {
+			return am.Albedo(hr)
+		}
+		return Vector3{}
+	}
+	return albedo(m.a).Lerp(albedo(m.b), m.amount(hr))
+}
+
+// OneSided returns true if either material is one-sided, since both
+// are the same surface.
+func (m MixMaterial) OneSided() bool {
+	return oneSided(m.a) || oneSided(m.b)
+}
+
+// Interior returns the medium inside the blend.
+func (m mixMedium) Interior() Medium {
+	return m.medium
+}
@@ -16,62 +16,89 @@
 
 package main
 
-import (
-	"math"
-)
-
 type movingSphere struct {
-	Center0   Vector3
-	Center1   Vector3
-	Time0     float64
-	Time1     float64
-	midcenter Vector3
-	Radius    float64
-	Material  Material
+	Center0  Vector3
+	Center1  Vector3
+	Time0    float64
+	Time1    float64
+	Radius   float64
+	Material Material
+
+	// displacement is how far the center moves from Time0 to Time1.
+	displacement Vector3
 }
 
 // NewMovingSphere returns a well constructed MovingSphere with some small speed improvements.
-func NewMovingSphere(c0 Vector3, c1 Vector3, t0 float64, t1 float64, r float64, mat Material) Hittable {
+func NewMovingSphere(c0 Vector3, c1 Vector3, t0 float64, t1 float64, r float64, mat Material) Solid {
 	return movingSphere{
-		Center0:   c0,
-		Center1:   c1,
-		midcenter: c1.Subtract(c0),
-		Time0:     t0,
-		Time1:     t1,
-		Radius:    r,
-		Material:  mat,
+		Center0:      c0,
+		Center1:      c1,
+		displacement: c1.Subtract(c0),
+		Time0:        t0,
+		Time1:        t1,
+		Radius:       r,
+		Material:     mat,
 	}
 }
 
+// center returns where the sphere is at time t.  It rests at either
+// end outside Time0 to Time1, so that animation frames past the end of
+// the motion do not carry it off through the scene.
 func (s movingSphere) center(t float64) Vector3 {
-	tt := (t - s.Time0) / (s.Time1 - s.Time0)
-	return s.Center0.Add(s.midcenter.MultiplyScalar(tt))
+	tt := clamp((t-s.Time0)/(s.Time1-s.Time0), 0, 1)
+	return s.Center0.Add(s.displacement.MultiplyScalar(tt))
 }
 
-func (s movingSphere) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
-	oct := s.center(r.Time)
-	oc := r.Origin.Subtract(oct)
-	a := r.Direction.LengthSquared()
-	bHalf := oc.Dot(r.Direction)
-	c := oc.LengthSquared() - s.Radius*s.Radius
-	discriminant := bHalf*bHalf - a*c
+// surface returns the hit at root, with the outward normal.
+func (s movingSphere) surface(r Ray, root float64, center Vector3) *HitRecord {
+	hitPoint := r.Point(root)
+	outwardNormal := hitPoint.Subtract(center).DivideScalar(s.Radius)
+	hr := &HitRecord{T: root, P: hitPoint, Normal: outwardNormal, Material: s.Material}
+	hr.U, hr.V = sphereUV(outwardNormal)
+	hr.DPDU, hr.DPDV = sphereTangents(outwardNormal, s.Radius)
+	if r.Time >= s.Time0 && r.Time <= s.Time1 {
+		hr.Velocity = s.displacement.DivideScalar(s.Time1 - s.Time0)
+	}
+	return hr
+}
 
-	if discriminant < 0 {
+func (s movingSphere) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	r.countIntersectionTest()
+	center := s.center(r.Time)
+	t0, t1, ok := sphereRoots(r, center, s.Radius)
+	if !ok {
 		return nil
 	}
-	sqrtd := math.Sqrt(discriminant)
-
-	root := (-bHalf - sqrtd) / a
+	root := t0
 	if root < tMin || root > tMax {
-		root = (-bHalf + sqrtd) / a
+		root = t1
 		if root < tMin || root > tMax {
 			return nil
 		}
 	}
 
-	hitPoint := r.Point(root)
-	outwardNormal := hitPoint.Subtract(oct).DivideScalar(s.Radius)
-	hr := &HitRecord{T: root, P: hitPoint, Material: s.Material}
-	hr.SetFaceNormal(r, outwardNormal)
+	hr := s.surface(r, root, center)
+	hr.SetFaceNormal(r, hr.Normal)
 	return hr
 }
+
+func (s movingSphere) Intervals(r Ray) []Interval {
+	r.countIntersectionTest()
+	center := s.center(r.Time)
+	t0, t1, ok := sphereRoots(r, center, s.Radius)
+	if !ok {
+		return nil
+	}
+	return []Interval{{s.surface(r, t0, center), s.surface(r, t1, center)}}
+}
+
+func (s movingSphere) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	// The center moves in a straight line, so the boxes at either end
+	// of the interval enclose the whole sweep.
+	r := Vector3{s.Radius, s.Radius, s.Radius}
+	c0 := s.center(time0)
+	c1 := s.center(time1)
+	box0 := aabb{c0.Subtract(r), c0.Add(r)}
+	box1 := aabb{c1.Subtract(r), c1.Add(r)}
+	return surroundingBox(box0, box1), true
+}
@@ -0,0 +1,284 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mtlSetting is a setting of PrincipledMaterial as Wavefront MTL
+// files give it, with the PBR extensions: a keyword for the value and
+// map_ and the keyword for an image it multiplies.
+type mtlSetting struct {
+	keyword string
+	color   bool
+	field   func(m *PrincipledMaterial) *Texture
+}
+
+var mtlSettings = []mtlSetting{
+	{"Kd", true, func(m *PrincipledMaterial) *Texture { return &m.BaseColor }},
+	{"Ks", false, func(m *PrincipledMaterial) *Texture { return &m.Specular }},
+	{"Pr", false, func(m *PrincipledMaterial) *Texture { return &m.Roughness }},
+	{"Pm", false, func(m *PrincipledMaterial) *Texture { return &m.Metallic }},
+	{"Ps", false, func(m *PrincipledMaterial) *Texture { return &m.Sheen }},
+	{"Pc", false, func(m *PrincipledMaterial) *Texture { return &m.Clearcoat }},
+	{"Pcr", false, func(m *PrincipledMaterial) *Texture { return &m.ClearcoatRoughness }},
+	{"Tr", false, func(m *PrincipledMaterial) *Texture { return &m.Transmission }},
+}
+
+// mtlChannels are the names -imfchan gives the channels of a map.
+const mtlChannels = "rgb"
+
+// ReadMTL reads the materials of a Wavefront MTL file, by name, as
+// principled materials.  Ni gives the index of refraction, and Kd,
+// Ks, Tr and the PBR extensions Pr, Pm, Ps, Pc and Pcr the textures,
+// each a value which multiplies the map of the same name, if there is
+// one.  Without Pr, the roughness comes from the Phong exponent Ns,
+// and without Tr, the transmission is one less the dissolve d.
+// Relative map file names are found in dir.  SheenTint has no MTL
+// setting, and is left as NewPrincipledMaterial sets it.
+func ReadMTL(r io.Reader, dir string) (map[string]PrincipledMaterial, error) {
+	return readMTL(r, newTextureFiles(dir))
+}
+
+// LoadMTL reads an MTL file.
+func LoadMTL(filename string) (map[string]PrincipledMaterial, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMTL(f, filepath.Dir(filename))
+}
+
+// mtlMaterial is a material while its MTL settings are being read.
+type mtlMaterial struct {
+	parts map[string]*textureParts
+	ns    float64
+	d     float64
+	ior   float64
+}
+
+func readMTL(r io.Reader, files *textureFiles) (map[string]PrincipledMaterial, error) {
+	ret := map[string]PrincipledMaterial{}
+	var name string
+	var cur *mtlMaterial
+	finish := func() {
+		if cur != nil {
+			ret[name] = cur.material()
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		keyword, args := fields[0], fields[1:]
+		if keyword == "newmtl" {
+			finish()
+			name = strings.Join(args, " ")
+			cur = &mtlMaterial{parts: map[string]*textureParts{}, ns: -1, d: -1, ior: 1.5}
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("line %d: %s before newmtl", line, keyword)
+		}
+		if err := cur.set(keyword, args, files); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+	return ret, nil
+}
+
+// set applies one line of settings.  Keywords which do not affect a
+// principled material are skipped.
+func (m *mtlMaterial) set(keyword string, args []string, files *textureFiles) error {
+	numbers := func() ([]float64, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s has no value", keyword)
+		}
+		var ret []float64
+		for _, a := range args {
+			v, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", keyword, err)
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	}
+	switch keyword {
+	case "Ns", "d", "Ni":
+		v, err := numbers()
+		if err != nil {
+			return err
+		}
+		switch keyword {
+		case "Ns":
+			m.ns = v[0]
+		case "d":
+			m.d = v[0]
+		case "Ni":
+			m.ior = v[0]
+		}
+		return nil
+	}
+	for _, s := range mtlSettings {
+		switch keyword {
+		case s.keyword:
+			v, err := numbers()
+			if err != nil {
+				return err
+			}
+			factor := Vector3{v[0], v[0], v[0]}
+			if s.color && len(v) == 3 {
+				factor = Vector3{v[0], v[1], v[2]}
+			}
+			m.part(s.keyword).factor = factor
+			return nil
+		case "map_" + s.keyword:
+			path, channel, err := parseMTLMap(args)
+			if err != nil {
+				return fmt.Errorf("%s: %w", keyword, err)
+			}
+			im, err := files.load(path, !s.color)
+			if err != nil {
+				return err
+			}
+			p := m.part(s.keyword)
+			p.image, p.channel = im, channel
+			return nil
+		}
+	}
+	return nil
+}
+
+// part returns the parts of the setting with the keyword, which start
+// as a factor of one.
+func (m *mtlMaterial) part(keyword string) *textureParts {
+	p, ok := m.parts[keyword]
+	if !ok {
+		p = &textureParts{factor: Vector3{1, 1, 1}, channel: -1}
+		m.parts[keyword] = p
+	}
+	return p
+}
+
+// parseMTLMap returns the file name and channel of a map line, skipping
+// the other options, which all take numbers or on or off.
+func parseMTLMap(args []string) (string, int, error) {
+	channel := -1
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		option := args[0]
+		args = args[1:]
+		if option == "-imfchan" {
+			if len(args) == 0 || len(args[0]) != 1 || !strings.Contains(mtlChannels, args[0]) {
+				return "", 0, fmt.Errorf("bad -imfchan")
+			}
+			channel = strings.Index(mtlChannels, args[0])
+			args = args[1:]
+			continue
+		}
+		for len(args) > 1 {
+			if _, err := strconv.ParseFloat(args[0], 64); err != nil && args[0] != "on" && args[0] != "off" {
+				break
+			}
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return "", 0, fmt.Errorf("no file name")
+	}
+	return strings.Join(args, " "), channel, nil
+}
+
+// material returns the principled material the settings describe.
+func (m *mtlMaterial) material() PrincipledMaterial {
+	ret := NewPrincipledMaterial(Vector3{0.8, 0.8, 0.8})
+	if _, ok := m.parts["Pr"]; !ok && m.ns >= 0 {
+		// The usual match of a Blinn-Phong exponent to GGX.
+		ret.Roughness = NewSolidValue(math.Sqrt(2 / (m.ns + 2)))
+	}
+	if _, ok := m.parts["Tr"]; !ok && m.d >= 0 {
+		ret.Transmission = NewSolidValue(1 - m.d)
+	}
+	ret.IOR = m.ior
+	for _, s := range mtlSettings {
+		if p, ok := m.parts[s.keyword]; ok {
+			*s.field(&ret) = p.texture()
+		}
+	}
+	return ret
+}
+
+// WriteMTL writes materials as an MTL file which ReadMTL reads back
+// the same, but for SheenTint.  Image textures are written by the
+// names they were loaded with, so relative names stay relative to the
+// same directory.
+func WriteMTL(w io.Writer, materials map[string]PrincipledMaterial) error {
+	names := make([]string, 0, len(materials))
+	for name := range materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for i, name := range names {
+		m := materials[name]
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "newmtl %s\n", name)
+		for _, s := range mtlSettings {
+			parts, err := partsOf(*s.field(&m))
+			if err != nil {
+				return fmt.Errorf("material %s %s: %w", name, s.keyword, err)
+			}
+			if s.color {
+				fmt.Fprintf(bw, "%s %g %g %g\n", s.keyword, parts.factor.X, parts.factor.Y, parts.factor.Z)
+			} else {
+				fmt.Fprintf(bw, "%s %g\n", s.keyword, parts.factor.X)
+			}
+			if parts.image != nil {
+				if parts.image.linear == s.color {
+					return fmt.Errorf("material %s %s: image is not linear as MTL reads it", name, s.keyword)
+				}
+				fmt.Fprintf(bw, "map_%s ", s.keyword)
+				if parts.channel >= 0 {
+					fmt.Fprintf(bw, "-imfchan %c ", mtlChannels[parts.channel])
+				}
+				fmt.Fprintln(bw, parts.image.path)
+			}
+		}
+		fmt.Fprintf(bw, "Ni %g\n", m.IOR)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReadMTL(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImage(t, dir, "wood.png", false)
+	writeTestImage(t, dir, "wood_rough.png", true)
+	materials, err := ReadMTL(strings.NewReader(`# exported
+newmtl wood
+Ns 98
+Kd 0.8 0.6 0.4
+map_Kd wood.png
+map_Pr -bm 1 -imfchan g wood_rough.png
+Ni 1.4
+
+newmtl glass
+Ns 900
+d 0.1
+`), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := newTextureFiles(dir)
+	color, _ := files.load("wood.png", false)
+	rough, _ := files.load("wood_rough.png", true)
+	wood := NewPrincipledMaterial(Vector3{})
+	wood.BaseColor = NewScaledTexture(color, Vector3{0.8, 0.6, 0.4})
+	wood.Roughness = NewChannelTexture(rough, 1)
+	wood.IOR = 1.4
+	sameMaterial(t, "wood", materials["wood"], wood)
+
+	glass := NewPrincipledMaterial(Vector3{0.8, 0.8, 0.8})
+	glass.Roughness = NewSolidValue(math.Sqrt(2.0 / 902))
+	glass.Transmission = NewSolidValue(0.9)
+	sameMaterial(t, "glass", materials["glass"], glass)
+
+	for _, bad := range []string{"Kd 1 1 1\n", "newmtl x\nKd red\n", "newmtl x\nmap_Kd -imfchan q a.png\n", "newmtl x\nmap_Kd nothing.png\n"} {
+		if _, err := ReadMTL(strings.NewReader(bad), dir); err == nil {
+			t.Errorf("ReadMTL(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMTL_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	want := map[string]PrincipledMaterial{
+		"paint":   carPaint(t, dir),
+		"plastic": NewPrincipledMaterial(Vector3{0.2, 0.4, 0.6}),
+	}
+	var b bytes.Buffer
+	if err := WriteMTL(&b, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadMTL(&b, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadMTL() = %d materials, want %d", len(got), len(want))
+	}
+	for name := range want {
+		sameMaterial(t, name, got[name], want[name])
+	}
+
+	bad := NewPrincipledMaterial(Vector3{})
+	bad.BaseColor = writeTestImage(t, dir, "linear.png", true)
+	if err := WriteMTL(&b, map[string]PrincipledMaterial{"bad": bad}); err == nil {
+		t.Error("WriteMTL() of a linear base color succeeded")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// NormalMapMaterial tilts the normals of another material by a
+// tangent-space normal map, as baked from a detailed model.  Red runs
+// along U, green along V and blue out of the surface, each mapped from
+// 0 to 1 onto -1 to 1.
+type NormalMapMaterial struct {
+	base     Material
+	normals  Texture
+	strength float64
+}
+
+// NewNormalMapMaterial returns base with the normals from the map,
+// which should be loaded as linear.  A strength of 1 uses the map as
+// is; less flattens it and more exaggerates it.
+func NewNormalMapMaterial(base Material, normals Texture, strength float64) NormalMapMaterial {
+	return NormalMapMaterial{base: base, normals: normals, strength: strength}
+}
+
+// normal returns the mapped normal at the hit, on the same side as the
+// hit's normal.
+func (m NormalMapMaterial) normal(hr *HitRecord) Vector3 {
+	outward := hr.outwardNormal()
+	dpdu, dpdv := hr.Tangents()
+	tangent := dpdu.Subtract(outward.MultiplyScalar(outward.Dot(dpdu))).Normalize()
+	bitangent := outward.Cross(tangent)
+	if bitangent.Dot(dpdv) < 0 {
+		bitangent = bitangent.Neg()
+	}
+	c := m.normals.Value(hr.U, hr.V, hr.P).MultiplyScalar(2).SubtractScalar(1)
+	n := tangent.MultiplyScalar(c.X * m.strength).
+		Add(bitangent.MultiplyScalar(c.Y * m.strength)).
+		Add(outward.MultiplyScalar(c.Z))
+	if NearZeroVector(n) {
+		return hr.Normal
+	}
+	n = n.Normalize()
+	if !hr.FrontFace {
+		n = n.Neg()
+	}
+	return n
+}
+
+// Scatter scatters off the base with the mapped normal.
+func (m NormalMapMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	return m.base.Scatter(r, hr.withShadingNormal(r, m.normal(hr)), smp)
+}
+
+// Albedo returns the base's color.
+func (m NormalMapMaterial) Albedo(hr *HitRecord) Vector3 {
+	if am, ok := m.base.(AlbedoMaterial); ok {
+		return am.Albedo(hr)
+	}
+	return Vector3{}
+}
+
+// OneSided returns true if the base is one-sided.
+func (m NormalMapMaterial) OneSided() bool {
+	return oneSided(m.base)
+}
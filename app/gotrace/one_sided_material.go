@@ -0,0 +1,82 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// SidedMaterial is implemented by materials which say whether their
+// surface is seen from both sides, as materials are unless they say
+// otherwise, or only from the front, the side its outward normal
+// points to.  Rays reaching the back of a one-sided surface pass
+// through as though it were not there, as with backface culling.
+// Materials which wrap others pass on what those say.
+type SidedMaterial interface {
+	OneSided() bool
+}
+
+// oneSided returns true if m is seen only from the front.
+func oneSided(m Material) bool {
+	sm, ok := m.(SidedMaterial)
+	return ok && sm.OneSided()
+}
+
+// culled returns true if the hit is on the back of a one-sided surface.
+func culled(hr *HitRecord) bool {
+	return !hr.FrontFace && oneSided(hr.Material)
+}
+
+// OneSidedMaterial makes any material one-sided.
+type OneSidedMaterial struct {
+	base Material
+}
+
+// oneSidedMedium is a one-sided material with a medium inside, which
+// it keeps, so that it still nests with other media.
+type oneSidedMedium struct {
+	OneSidedMaterial
+}
+
+// NewOneSidedMaterial returns base, seen only from the front.
+func NewOneSidedMaterial(base Material) Material {
+	m := OneSidedMaterial{base: base}
+	if _, ok := base.(MediumMaterial); ok {
+		return oneSidedMedium{m}
+	}
+	return m
+}
+
+// OneSided returns true.
+func (m OneSidedMaterial) OneSided() bool {
+	return true
+}
+
+// Scatter scatters off the base.  The back is never hit, so this is
+// only ever the front.
+func (m OneSidedMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	return m.base.Scatter(r, hr, smp)
+}
+
+// Albedo returns the base's color.
+func (m OneSidedMaterial) Albedo(hr *HitRecord) Vector3 {
+	if am, ok := m.base.(AlbedoMaterial); ok {
+		return am.Albedo(hr)
+	}
+	return Vector3{}
+}
+
+// Interior returns the base's medium.
+func (m oneSidedMedium) Interior() Medium {
+	return m.base.(MediumMaterial).Interior()
+}
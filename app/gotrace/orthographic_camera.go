@@ -0,0 +1,50 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// OrthographicCamera sends parallel rays, so distant objects do not
+// shrink.  This is useful for technical drawings.
+type OrthographicCamera struct {
+	cameraBasis
+	Width  float64
+	Height float64
+}
+
+// NewOrthographicCamera returns a camera which sees a width by height
+// window of the world, centered on the line from lookFrom to lookAt.
+func NewOrthographicCamera(lookFrom Vector3, lookAt Vector3, vup Vector3, width float64, height float64, time0 float64, time1 float64) OrthographicCamera {
+	return OrthographicCamera{
+		cameraBasis: newCameraBasis(lookFrom, lookAt, vup, time0, time1),
+		Width:       width,
+		Height:      height,
+	}
+}
+
+// GetRay returns a ray starting on the image window at s, t, pointing
+// straight ahead.
+func (c OrthographicCamera) GetRay(s float64, t float64, smp Sampler) (bool, Ray) {
+	origin := c.origin.
+		Add(c.u.MultiplyScalar((s - 0.5) * c.Width)).
+		Add(c.v.MultiplyScalar((t - 0.5) * c.Height))
+	return true, NewRay(origin, c.w.Neg(), c.time(smp))
+}
+
+// Project returns where p appears on the image.
+func (c OrthographicCamera) Project(p Vector3) (bool, float64, float64) {
+	d := p.Subtract(c.origin)
+	return true, d.Dot(c.u)/c.Width + 0.5, d.Dot(c.v)/c.Height + 0.5
+}
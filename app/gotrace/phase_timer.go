@@ -0,0 +1,66 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// PhaseTime is the time spent in one phase of the program.
+type PhaseTime struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+}
+
+// PhaseTimer adds up the time spent in each phase of the program, such
+// as building the scene or rendering.  Phases entered more than once,
+// like rendering each frame of an animation, are added together.
+type PhaseTimer struct {
+	mu     sync.Mutex
+	phases []PhaseTime
+}
+
+// Start starts timing a phase, and returns a function which stops it.
+func (t *PhaseTimer) Start(name string) func() {
+	start := time.Now()
+	return func() {
+		t.add(name, time.Since(start))
+	}
+}
+
+func (t *PhaseTimer) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.phases {
+		if t.phases[i].Name == name {
+			t.phases[i].Duration += d
+			t.phases[i].Seconds = t.phases[i].Duration.Seconds()
+			return
+		}
+	}
+	t.phases = append(t.phases, PhaseTime{name, d, d.Seconds()})
+}
+
+// Phases returns the time spent in each phase, in the order they were
+// first entered.
+func (t *PhaseTimer) Phases() []PhaseTime {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]PhaseTime{}, t.phases...)
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// solveQuadratic returns the real roots of a x^2 + b x + c, in order.
+func solveQuadratic(a float64, b float64, c float64) []float64 {
+	if a == 0 {
+		if b == 0 {
+			return nil
+		}
+		return []float64{-c / b}
+	}
+	discriminant := b*b - 4*a*c
+	if discriminant < 0 {
+		return nil
+	}
+	// Avoid cancellation by never subtracting nearly equal values.
+	q := -0.5 * (b + math.Copysign(math.Sqrt(discriminant), b))
+	if q == 0 {
+		return []float64{0, 0}
+	}
+	r0, r1 := q/a, c/q
+	if r0 > r1 {
+		r0, r1 = r1, r0
+	}
+	return []float64{r0, r1}
+}
+
+// solveCubic returns the real roots of x^3 + a x^2 + b x + c.
+func solveCubic(a float64, b float64, c float64) []float64 {
+	q := (a*a - 3*b) / 9
+	r := (2*a*a*a - 9*a*b + 27*c) / 54
+	shift := a / 3
+	if r*r < q*q*q {
+		// Three real roots.
+		theta := math.Acos(clamp(r/math.Sqrt(q*q*q), -1, 1))
+		s := -2 * math.Sqrt(q)
+		return []float64{
+			s*math.Cos(theta/3) - shift,
+			s*math.Cos((theta+2*math.Pi)/3) - shift,
+			s*math.Cos((theta-2*math.Pi)/3) - shift,
+		}
+	}
+	aa := -math.Cbrt(r + math.Copysign(math.Sqrt(r*r-q*q*q), r))
+	bb := 0.0
+	if aa != 0 {
+		bb = q / aa
+	}
+	return []float64{aa + bb - shift}
+}
+
+// solveQuartic returns the real roots of
+// c4 x^4 + c3 x^3 + c2 x^2 + c1 x + c0, in order, by Ferrari's method.
+// Each root is polished with Newton's method, as the closed form loses
+// precision.
+func solveQuartic(c4 float64, c3 float64, c2 float64, c1 float64, c0 float64) []float64 {
+	if c4 == 0 {
+		return nil
+	}
+	a, b, c, d := c3/c4, c2/c4, c1/c4, c0/c4
+
+	// Substitute x = y - a/4 to lose the cubic term, leaving
+	// y^4 + p y^2 + q y + r.
+	aa := a * a
+	p := b - 3*aa/8
+	q := c - a*b/2 + aa*a/8
+	r := d - a*c/4 + aa*b/16 - 3*aa*aa/256
+
+	var ys []float64
+	if math.Abs(q) < 1e-12 {
+		// Biquadratic, a quadratic in y^2.
+		for _, z := range solveQuadratic(1, p, r) {
+			if z >= 0 {
+				ys = append(ys, -math.Sqrt(z), math.Sqrt(z))
+			}
+		}
+	} else {
+		// Split into two quadratics using a positive root m of the
+		// resolvent cubic.
+		m := 0.0
+		for _, root := range solveCubic(p, p*p/4-r, -q*q/8) {
+			m = math.Max(m, root)
+		}
+		if m <= 0 {
+			return nil
+		}
+		s := math.Sqrt(2 * m)
+		ys = append(ys, solveQuadratic(1, s, p/2+m-q/(2*s))...)
+		ys = append(ys, solveQuadratic(1, -s, p/2+m+q/(2*s))...)
+	}
+
+	roots := make([]float64, len(ys))
+	for i, y := range ys {
+		x := y - a/4
+		for step := 0; step < 3; step++ {
+			f := (((x+a)*x+b)*x+c)*x + d
+			df := ((4*x+3*a)*x+2*b)*x + c
+			if df == 0 {
+				break
+			}
+			x -= f / df
+		}
+		roots[i] = x
+	}
+	sort.Float64s(roots)
+	return roots
+}
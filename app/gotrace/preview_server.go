@@ -0,0 +1,164 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"time"
+)
+
+const previewPage = `<!DOCTYPE html>
+<html>
+<head><title>gotrace</title></head>
+<body style="background: #222; color: #ddd; font-family: sans-serif">
+<img src="/stream.mjpeg" style="max-width: 100%; image-rendering: pixelated"><br>
+<button onclick="post('/pause')">Pause</button>
+<button onclick="post('/resume')">Resume</button>
+<button onclick="post('/cancel')">Cancel</button>
+<span id="status"></span>
+<script>
+function post(path) { fetch(path, {method: 'POST'}); }
+async function poll() {
+	try {
+		const s = await (await fetch('/status')).json();
+		document.getElementById('status').textContent =
+			s.state + ': ' + s.rowsDone + ' of ' + s.rows + ' lines, ' +
+			Math.round(s.samplesPerSecond) + ' samples/s' +
+			(s.eta >= 0 ? ', ' + Math.round(s.eta) + 's to go' : '');
+	} catch (e) {}
+	setTimeout(poll, 1000);
+}
+poll();
+</script>
+</body>
+</html>
+`
+
+// PreviewServer serves a live view of a render over HTTP:
+//
+//	/              a page showing the stream, status, and controls
+//	/stream.mjpeg  the image as it renders, as a motion JPEG
+//	/image.png     the image as rendered so far
+//	/status        the progress, as JSON
+//	/pause         POST to pause the render
+//	/resume        POST to resume it
+//	/cancel        POST to cancel it
+type PreviewServer struct {
+	Progress *RenderProgress
+	// Interval is how often the stream checks for a changed image.
+	Interval time.Duration
+}
+
+// NewPreviewServer returns a server for the render progress tracks.
+func NewPreviewServer(progress *RenderProgress) *PreviewServer {
+	return &PreviewServer{
+		Progress: progress,
+		Interval: time.Second,
+	}
+}
+
+// Handler returns the handler for all of the server's endpoints.
+func (s *PreviewServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.servePage)
+	mux.HandleFunc("/stream.mjpeg", s.serveStream)
+	mux.HandleFunc("/image.png", s.serveImage)
+	mux.HandleFunc("/status", s.serveStatus)
+	mux.HandleFunc("/pause", s.control(s.Progress.Pause))
+	mux.HandleFunc("/resume", s.control(s.Progress.Resume))
+	mux.HandleFunc("/cancel", s.control(s.Progress.Cancel))
+	return mux
+}
+
+func (s *PreviewServer) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, previewPage)
+}
+
+func (s *PreviewServer) serveImage(w http.ResponseWriter, r *http.Request) {
+	im, _ := s.Progress.Image()
+	if im == nil {
+		http.Error(w, "nothing rendered yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	png.Encode(w, im)
+}
+
+// serveStream sends a new JPEG whenever the image has changed, until
+// the client goes away.
+func (s *PreviewServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	const boundary = "frame"
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
+	w.Header().Set("Cache-Control", "no-store")
+	flusher, _ := w.(http.Flusher)
+
+	sent := -1
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if im, version := s.Progress.Image(); im != nil && version != sent {
+			fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\n\r\n", boundary)
+			if err := jpeg.Encode(w, im, &jpeg.Options{Quality: 90}); err != nil {
+				return
+			}
+			if _, err := fmt.Fprint(w, "\r\n"); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			sent = version
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PreviewServer) serveStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.Progress.Status())
+}
+
+// control returns a handler which runs action on a POST, and replies
+// with the status afterwards.
+func (s *PreviewServer) control(action func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		log.Printf("%s requested by %s", r.URL.Path[1:], r.RemoteAddr)
+		action()
+		s.serveStatus(w, r)
+	}
+}
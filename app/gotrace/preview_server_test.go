@@ -0,0 +1,120 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPreviewServer(t *testing.T) {
+	progress := NewRenderProgress()
+	server := httptest.NewServer(NewPreviewServer(progress).Handler())
+	defer server.Close()
+
+	status := func(method string, path string) RenderStatus {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s = %s", method, path, resp.Status)
+		}
+		var s RenderStatus
+		if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	resp, err := http.Get(server.URL + "/image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("image before rendering = %s, want 503", resp.Status)
+	}
+
+	bounds := image.Rect(0, 0, 4, 2)
+	progress.Start(&Frame{Film: NewFilm(bounds, BoxFilter{0.5})}, 4)
+	progress.absorb(processedLine{y: 0, film: NewFilm(image.Rect(0, 0, 4, 1), BoxFilter{0.5})})
+	if s := status("GET", "/status"); s.State != "rendering" || s.RowsDone != 1 || s.Rows != 2 || s.Samples != 4 {
+		t.Errorf("status = %+v, want 1 of 2 rows and 4 samples", s)
+	}
+
+	if s := status("POST", "/pause"); s.State != "paused" {
+		t.Errorf("state after pause = %q", s.State)
+	}
+	if s := status("POST", "/resume"); s.State != "rendering" {
+		t.Errorf("state after resume = %q", s.State)
+	}
+	if s := status("POST", "/cancel"); s.State != "cancelled" {
+		t.Errorf("state after cancel = %q", s.State)
+	}
+	if progress.Wait() {
+		t.Errorf("Wait() after cancel = true, want false")
+	}
+
+	resp, err = http.Get(server.URL + "/cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /cancel = %s, want 405", resp.Status)
+	}
+
+	resp, err = http.Get(server.URL + "/image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("image = %s %q, want a PNG", resp.Status, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestRenderProgress_WaitBlocksWhilePaused(t *testing.T) {
+	progress := NewRenderProgress()
+	progress.Pause()
+	done := make(chan bool)
+	go func() {
+		done <- progress.Wait()
+	}()
+	select {
+	case <-done:
+		t.Fatal("Wait() returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	progress.Resume()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Errorf("Wait() after resume = false, want true")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() still blocked after resume")
+	}
+}
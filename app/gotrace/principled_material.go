@@ -0,0 +1,210 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// PrincipledMaterial is a Disney style material, described by the
+// handful of settings artists use rather than by the physics, which
+// covers most real surfaces from plastic and paint to metal and
+// glass.  Every setting but IOR is a texture, so it can vary over the
+// surface.  Apart from BaseColor, settings run from 0 to 1, and use
+// the texture's first channel.
+//
+// It is built from lobes: a diffuse base with sheen, a GGX specular
+// layer which becomes the whole surface as Metallic rises, rough glass
+// for Transmission, and a clear coat on top.  Each scatter picks one
+// lobe, in proportion to how much light it is likely to reflect, and
+// weights it to make up for the others.  The layering is approximate:
+// light the specular layer reflects is taken from the diffuse base,
+// but the clear coat takes nothing from what is below it.
+type PrincipledMaterial struct {
+	BaseColor          Texture
+	Metallic           Texture
+	Roughness          Texture
+	Specular           Texture
+	Clearcoat          Texture
+	ClearcoatRoughness Texture
+	Sheen              Texture
+	SheenTint          Texture
+	Transmission       Texture
+	IOR                float64
+}
+
+// NewPrincipledMaterial returns a grey plastic, with settings which
+// can then be changed.
+func NewPrincipledMaterial(baseColor Vector3) PrincipledMaterial {
+	return PrincipledMaterial{
+		BaseColor:          NewSolidColor(baseColor),
+		Metallic:           NewSolidValue(0),
+		Roughness:          NewSolidValue(0.5),
+		Specular:           NewSolidValue(0.5),
+		Clearcoat:          NewSolidValue(0),
+		ClearcoatRoughness: NewSolidValue(0.03),
+		Sheen:              NewSolidValue(0),
+		SheenTint:          NewSolidValue(0.5),
+		Transmission:       NewSolidValue(0),
+		IOR:                1.5,
+	}
+}
+
+// principledLobe is one layer of the material.
+type principledLobe int
+
+const (
+	lobeDiffuse principledLobe = iota
+	lobeSpecular
+	lobeTransmission
+	lobeClearcoat
+	lobeCount
+)
+
+// luminance returns the brightness of a linear color.
+func luminance(c Vector3) float64 {
+	return 0.2126*c.X + 0.7152*c.Y + 0.0722*c.Z
+}
+
+// schlick approximates Fresnel reflectance rising from f0 head on to
+// one at grazing angles.
+func schlick(f0 Vector3, cosI float64) Vector3 {
+	f := math.Pow(1-clamp(cosI, 0, 1), 5)
+	return f0.Add(Vector3{1, 1, 1}.Subtract(f0).MultiplyScalar(f))
+}
+
+// Scatter samples one lobe.
+func (m PrincipledMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	value := func(t Texture) Vector3 {
+		return t.Value(hr.U, hr.V, hr.P)
+	}
+	scalar := func(t Texture) float64 {
+		return clamp(value(t).X, 0, 1)
+	}
+	base := value(m.BaseColor)
+	metallic := scalar(m.Metallic)
+	roughness := scalar(m.Roughness)
+	transmission := scalar(m.Transmission)
+
+	// Inside glass, only the glass matters.
+	if !hr.FrontFace {
+		if transmission == 0 {
+			return false, r, Vector3{}
+		}
+		return NewMediumRoughDielectricMaterial(m.Interior(), roughness).Scatter(r, hr, smp)
+	}
+
+	frame := newShadingFrame(hr.Normal)
+	wo := frame.toLocal(r.Direction.Normalize().Neg())
+	if wo.Z <= 0 {
+		return false, r, Vector3{}
+	}
+
+	// Dielectrics reflect up to 8% head on, depending on Specular.
+	f0 := Vector3{1, 1, 1}.MultiplyScalar(0.08*scalar(m.Specular)).Lerp(base, metallic)
+	clearcoat := 0.25 * scalar(m.Clearcoat)
+
+	var weights [lobeCount]float64
+	weights[lobeDiffuse] = (1 - metallic) * (1 - transmission)
+	weights[lobeSpecular] = 1 - (1-metallic)*transmission
+	weights[lobeTransmission] = (1 - metallic) * transmission
+	weights[lobeClearcoat] = clearcoat
+
+	var probabilities [lobeCount]float64
+	probabilities[lobeDiffuse] = weights[lobeDiffuse] * (luminance(base) + scalar(m.Sheen))
+	probabilities[lobeSpecular] = weights[lobeSpecular] * luminance(schlick(f0, wo.Z))
+	probabilities[lobeTransmission] = weights[lobeTransmission]
+	probabilities[lobeClearcoat] = clearcoat * schlick(Vector3{0.04, 0.04, 0.04}, wo.Z).X
+	total := 0.0
+	for _, p := range probabilities {
+		total += p
+	}
+	if total <= 0 {
+		return false, r, Vector3{}
+	}
+
+	lobe := lobeDiffuse
+	pick := smp.Get1D() * total
+	for l, p := range probabilities {
+		if p == 0 {
+			continue
+		}
+		lobe = principledLobe(l)
+		if pick < p {
+			break
+		}
+		pick -= p
+	}
+	scale := weights[lobe] * total / probabilities[lobe]
+
+	switch lobe {
+	case lobeDiffuse:
+		direction := hr.Normal.Add(SampleUnitSphere(smp.Get2D()))
+		if NearZeroVector(direction) {
+			direction = hr.Normal
+		}
+		wi := frame.toLocal(direction.Normalize())
+		// What the specular layer reflects never reaches the base.
+		color := base.MultiplyScalar(1 - fresnelDielectric(wo.Z, m.IOR))
+		if sheen := scalar(m.Sheen); sheen > 0 {
+			tint := Vector3{1, 1, 1}
+			if lum := luminance(base); lum > 0 {
+				tint = base.DivideScalar(lum)
+			}
+			half := wo.Add(wi).Normalize()
+			sheenColor := Vector3{1, 1, 1}.Lerp(tint, scalar(m.SheenTint))
+			color = color.Add(sheenColor.MultiplyScalar(sheen * math.Pow(1-clamp(wi.Dot(half), 0, 1), 5)))
+		}
+		return true, r.Spawn(hr.P, direction), color.MultiplyScalar(scale)
+
+	case lobeSpecular, lobeClearcoat:
+		alpha := roughnessToAlpha(roughness)
+		lobeF0 := f0
+		if lobe == lobeClearcoat {
+			alpha = roughnessToAlpha(scalar(m.ClearcoatRoughness))
+			lobeF0 = Vector3{0.04, 0.04, 0.04}
+		}
+		facet := Vector3{0, 0, 1}
+		if alpha >= smoothAlpha {
+			u1, u2 := smp.Get2D()
+			facet = sampleGGXVisibleNormal(wo, alpha, u1, u2)
+		}
+		wi := reflectLocal(wo, facet)
+		if wi.Z <= 0 {
+			return false, r, Vector3{}
+		}
+		weight := 1.0
+		if alpha >= smoothAlpha {
+			weight = ggxG2(wo, wi, alpha) / ggxG1(wo, alpha)
+		}
+		attenuation := schlick(lobeF0, wo.Dot(facet)).MultiplyScalar(weight * scale)
+		return true, r.Spawn(hr.P, frame.fromLocal(wi)), attenuation
+
+	default:
+		ok, scattered, attenuation := NewMediumRoughDielectricMaterial(m.Interior(), roughness).Scatter(r, hr, smp)
+		return ok, scattered, attenuation.Multiply(base).MultiplyScalar(scale)
+	}
+}
+
+// Interior returns the clear medium which Transmission lets light
+// into.
+func (m PrincipledMaterial) Interior() Medium {
+	return Medium{IOR: m.IOR}
+}
+
+// Albedo returns the base color at the hit.
+func (m PrincipledMaterial) Albedo(hr *HitRecord) Vector3 {
+	return m.BaseColor.Value(hr.U, hr.V, hr.P)
+}
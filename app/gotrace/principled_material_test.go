@@ -0,0 +1,85 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestPrincipledMaterial_Scatter(t *testing.T) {
+	hr := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+	in := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+	smp := NewIndependentSampler(1)
+
+	mirror := NewPrincipledMaterial(Vector3{0.9, 0.6, 0.2})
+	mirror.Metallic = NewSolidValue(1)
+	mirror.Roughness = NewSolidValue(0)
+	ok, out, attenuation := mirror.Scatter(in, hr, smp)
+	if !ok || out.Direction.Subtract(Vector3{1, 1, 0}.Normalize()).Length() > 1e-9 {
+		t.Errorf("Scatter() of polished metal = %v, want the mirror direction", out.Direction)
+	}
+	if want := schlick(Vector3{0.9, 0.6, 0.2}, math.Sqrt2/2); attenuation.Subtract(want).Length() > 1e-9 {
+		t.Errorf("Scatter() of polished metal = %v, want %v", attenuation, want)
+	}
+
+	// However it is set up, a white surface should reflect no more
+	// light than arrives, on average.
+	settings := map[string]func(m *PrincipledMaterial){
+		"plastic":    func(m *PrincipledMaterial) {},
+		"metal":      func(m *PrincipledMaterial) { m.Metallic = NewSolidValue(1) },
+		"half":       func(m *PrincipledMaterial) { m.Metallic = NewSolidValue(0.5) },
+		"coated":     func(m *PrincipledMaterial) { m.Clearcoat = NewSolidValue(1) },
+		"velvet":     func(m *PrincipledMaterial) { m.Sheen = NewSolidValue(1) },
+		"glass":      func(m *PrincipledMaterial) { m.Transmission = NewSolidValue(1) },
+		"very rough": func(m *PrincipledMaterial) { m.Roughness = NewSolidValue(1) },
+	}
+	for name, set := range settings {
+		t.Run(name, func(t *testing.T) {
+			m := NewPrincipledMaterial(Vector3{1, 1, 1})
+			set(&m)
+			const n = 20000
+			total := 0.0
+			for i := 0; i < n; i++ {
+				if ok, _, attenuation := m.Scatter(in, hr, smp); ok {
+					if math.IsNaN(attenuation.X) || math.IsInf(attenuation.X, 0) {
+						t.Fatalf("Scatter() = %v", attenuation)
+					}
+					total += attenuation.Y
+				}
+			}
+			if mean := total / n; mean > 1.1 || mean < 0.5 {
+				t.Errorf("Scatter() reflects %v on average, want about 1", mean)
+			}
+		})
+	}
+}
+
+func TestPrincipledMaterial_Textured(t *testing.T) {
+	im := image.NewGray(image.Rect(0, 0, 2, 1))
+	im.Set(1, 0, color.Gray{255})
+	m := NewPrincipledMaterial(Vector3{})
+	m.BaseColor = NewImageTexture(im, false)
+	if got := m.Albedo(&HitRecord{U: 0.75, V: 0.5}); got != (Vector3{1, 1, 1}) {
+		t.Errorf("Albedo() = %v, want white", got)
+	}
+	if got := m.Albedo(&HitRecord{U: 0.25, V: 0.5}); got != (Vector3{}) {
+		t.Errorf("Albedo() = %v, want black", got)
+	}
+}
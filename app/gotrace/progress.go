@@ -0,0 +1,267 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// progressLogInterval is how often progress is logged when the
+	// output is not a terminal.
+	progressLogInterval = 5 * time.Second
+	// progressLineInterval is how often the progress line is updated
+	// on a terminal.
+	progressLineInterval = 250 * time.Millisecond
+)
+
+// RenderProgress tracks how far along the image being rendered is,
+// and lets the render be paused, resumed, or cancelled from another
+// goroutine.  Pausing and cancelling take effect between lines.
+//
+// Progress is shown as a single line which updates in place when
+// Output is a terminal, and is logged every few seconds otherwise.
+type RenderProgress struct {
+	Output      io.Writer
+	Interactive bool
+
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	frame         *Frame
+	version       int
+	rows          int
+	rowsDone      int
+	samplesPerRow int
+	imageStats    RayStats
+	workerStats   []RayStats
+
+	started   time.Time
+	pausedAt  time.Time
+	pausedFor time.Duration
+	lastShown time.Time
+
+	paused    bool
+	cancelled bool
+}
+
+// RenderStatus is a snapshot of a render's progress.  Times are in
+// seconds, and ETA is -1 until there is enough to go on.
+type RenderStatus struct {
+	State            string  `json:"state"`
+	Rows             int     `json:"rows"`
+	RowsDone         int     `json:"rowsDone"`
+	Samples          int     `json:"samples"`
+	SamplesPerSecond float64 `json:"samplesPerSecond"`
+	RaysPerSecond    float64 `json:"raysPerSecond"`
+	Elapsed          float64 `json:"elapsed"`
+	ETA              float64 `json:"eta"`
+}
+
+// NewRenderProgress returns a tracker with nothing rendering yet,
+// showing progress on stderr.
+func NewRenderProgress() *RenderProgress {
+	p := &RenderProgress{Output: os.Stderr}
+	if fi, err := os.Stderr.Stat(); err == nil {
+		p.Interactive = fi.Mode()&os.ModeCharDevice != 0
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Start begins tracking a new image, which lines will be merged into.
+// Pausing carries over from the image before.
+func (p *RenderProgress) Start(frame *Frame, samplesPerRow int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.frame = frame
+	p.version++
+	p.rows = frame.Film.Bounds.Dy()
+	p.rowsDone = 0
+	p.samplesPerRow = samplesPerRow
+	p.imageStats = RayStats{}
+	p.started = time.Now()
+	p.pausedAt = p.started
+	p.pausedFor = 0
+	p.lastShown = p.started
+}
+
+// absorb merges a finished line into the image, and adds up the work
+// which went into it.
+func (p *RenderProgress) absorb(line processedLine) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.frame.Film.Merge(line.film)
+	if p.frame.AOVs != nil {
+		p.frame.AOVs.Merge(line.aovs)
+	}
+	if p.frame.Costs != nil {
+		p.frame.Costs.Merge(line.costs)
+	}
+	p.rowsDone++
+	p.version++
+	p.imageStats.Add(line.stats)
+	for len(p.workerStats) <= line.worker {
+		p.workerStats = append(p.workerStats, RayStats{})
+	}
+	p.workerStats[line.worker].Add(line.stats)
+	p.show()
+}
+
+// show shows the progress if it is time to.
+func (p *RenderProgress) show() {
+	done := p.rowsDone == p.rows
+	interval := progressLogInterval
+	if p.Interactive {
+		interval = progressLineInterval
+	}
+	if !done && time.Since(p.lastShown) < interval {
+		return
+	}
+	p.lastShown = time.Now()
+
+	s := p.status()
+	eta := "?"
+	if s.ETA >= 0 {
+		eta = (time.Duration(s.ETA) * time.Second).String()
+	}
+	msg := fmt.Sprintf("%5.1f%% %d/%d lines, %s rays/s, %s samples/s, ETA %s",
+		100*float64(s.RowsDone)/float64(s.Rows), s.RowsDone, s.Rows,
+		siCount(s.RaysPerSecond), siCount(s.SamplesPerSecond), eta)
+	if !p.Interactive {
+		log.Print(msg)
+		return
+	}
+	// Clear whatever is left of the last, longer, line.
+	fmt.Fprintf(p.Output, "\r%s\x1b[K", msg)
+	if done {
+		fmt.Fprintln(p.Output)
+	}
+}
+
+// WorkerStats returns the work done by each worker, over every image
+// rendered so far.
+func (p *RenderProgress) WorkerStats() []RayStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]RayStats{}, p.workerStats...)
+}
+
+// Wait blocks while the render is paused.  It returns false once the
+// render has been cancelled, and the rest of the work should be skipped.
+func (p *RenderProgress) Wait() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.paused && !p.cancelled {
+		p.cond.Wait()
+	}
+	return !p.cancelled
+}
+
+// Pause stops workers from starting new lines.
+func (p *RenderProgress) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		p.paused = true
+		p.pausedAt = time.Now()
+	}
+}
+
+// Resume lets paused workers carry on.
+func (p *RenderProgress) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		p.paused = false
+		p.pausedFor += time.Since(p.pausedAt)
+		p.cond.Broadcast()
+	}
+}
+
+// Cancel skips the rest of the render.
+func (p *RenderProgress) Cancel() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cancelled = true
+	p.cond.Broadcast()
+}
+
+// Cancelled is true once the render has been cancelled.
+func (p *RenderProgress) Cancelled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cancelled
+}
+
+// Status returns the progress of the image being rendered.
+func (p *RenderProgress) Status() RenderStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status()
+}
+
+func (p *RenderProgress) status() RenderStatus {
+	s := RenderStatus{
+		State:    "rendering",
+		Rows:     p.rows,
+		RowsDone: p.rowsDone,
+		Samples:  p.rowsDone * p.samplesPerRow,
+		ETA:      -1,
+	}
+	switch {
+	case p.frame == nil:
+		s.State = "waiting"
+		return s
+	case p.cancelled:
+		s.State = "cancelled"
+	case p.rowsDone == p.rows:
+		s.State = "done"
+	case p.paused:
+		s.State = "paused"
+	}
+
+	elapsed := time.Since(p.started) - p.pausedFor
+	if p.paused {
+		elapsed -= time.Since(p.pausedAt)
+	}
+	s.Elapsed = elapsed.Seconds()
+	if s.Samples > 0 && s.Elapsed > 0 {
+		s.SamplesPerSecond = float64(s.Samples) / s.Elapsed
+		s.RaysPerSecond = float64(p.imageStats.Rays()) / s.Elapsed
+		s.ETA = float64((p.rows-p.rowsDone)*p.samplesPerRow) / s.SamplesPerSecond
+	}
+	return s
+}
+
+// Image returns the image as rendered so far, and a version number
+// which changes whenever the image does.  The image is nil before
+// anything has started rendering.
+func (p *RenderProgress) Image() (*image.NRGBA, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.frame == nil {
+		return nil, p.version
+	}
+	return p.frame.Film.Image(), p.version
+}
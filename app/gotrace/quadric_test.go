@@ -0,0 +1,179 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSolveQuartic(t *testing.T) {
+	tests := []struct {
+		name  string
+		roots []float64
+	}{
+		{"four", []float64{-3, -1, 0.5, 2}},
+		{"biquadratic", []float64{-2, -1, 1, 2}},
+		{"repeated", []float64{1, 1, 4, 4}},
+		{"wide", []float64{-100, 0.001, 0.002, 350}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Expand (x - r0)(x - r1)(x - r2)(x - r3).
+			c := []float64{1}
+			for _, r := range tt.roots {
+				next := make([]float64, len(c)+1)
+				for i, v := range c {
+					next[i] += v
+					next[i+1] -= v * r
+				}
+				c = next
+			}
+			got := solveQuartic(c[0], c[1], c[2], c[3], c[4])
+			if len(got) != len(tt.roots) {
+				t.Fatalf("solveQuartic() = %v, want %v", got, tt.roots)
+			}
+			for i := range got {
+				if math.Abs(got[i]-tt.roots[i]) > 1e-6*math.Max(1, math.Abs(tt.roots[i])) {
+					t.Errorf("solveQuartic() = %v, want %v", got, tt.roots)
+				}
+			}
+		})
+	}
+
+	// x^4 + 1 has no real roots.
+	if got := solveQuartic(1, 0, 0, 0, 1); len(got) != 0 {
+		t.Errorf("solveQuartic(x^4 + 1) = %v, want none", got)
+	}
+}
+
+func TestQuadrics_Hit(t *testing.T) {
+	mat := NewLambertianMaterial(Vector3{0.5, 0.5, 0.5})
+	down := func(x float64, z float64) Ray {
+		return NewRay(Vector3{x, 10, z}, Vector3{0, -1, 0}, 0)
+	}
+	fromX := func(y float64) Ray {
+		return NewRay(Vector3{-10, y, 0}, Vector3{1, 0, 0}, 0)
+	}
+	tests := []struct {
+		name       string
+		obj        Hittable
+		r          Ray
+		wantT      float64
+		wantNormal Vector3
+	}{
+		{"cylinder side", NewCylinder(Vector3{}, 1, 2, 360, mat), fromX(1), 9, Vector3{-1, 0, 0}},
+		{"cylinder misses above", NewCylinder(Vector3{}, 1, 2, 360, mat), fromX(3), -1, Vector3{}},
+		// Half a cylinder, swept from +X through +Z to -X, is missing
+		// its -Z side, so a ray from -Z hits the inside of the +Z side.
+		{"cylinder sweep", NewCylinder(Vector3{}, 1, 2, 180, mat), NewRay(Vector3{0, 1, -10}, Vector3{0, 0, 1}, 0), 11, Vector3{0, 0, -1}},
+		{"capped cylinder top", NewCappedCylinder(Vector3{0, 1, 0}, 1, 2, mat), down(0.5, 0), 7, Vector3{0, 1, 0}},
+		{"disk", NewDisk(Vector3{0, 1, 0}, 2, 0, 360, mat), down(1, 1), 9, Vector3{0, 1, 0}},
+		{"annulus hole", NewDisk(Vector3{0, 1, 0}, 2, 0.5, 360, mat), down(0.2, 0), -1, Vector3{}},
+		{"cone side", NewCone(Vector3{}, 1, 1, 360, mat), fromX(0.5), 9.5, Vector3{-1, 1, 0}.Normalize()},
+		{"capped cone base", NewCappedCone(Vector3{}, 1, 1, mat), NewRay(Vector3{0.2, -5, 0}, Vector3{0, 1, 0}, 0), 5, Vector3{0, -1, 0}},
+		{"torus", NewTorus(Vector3{}, 2, 0.5, 360, mat), fromX(0), 7.5, Vector3{-1, 0, 0}},
+		{"torus hole", NewTorus(Vector3{}, 2, 0.5, 360, mat), down(0, 0), -1, Vector3{}},
+		{"torus tube from above", NewTorus(Vector3{}, 2, 0.5, 360, mat), down(2, 0), 9.5, Vector3{0, 1, 0}},
+		{"torus sweep", NewTorus(Vector3{}, 2, 0.5, 90, mat), down(-2, 0), -1, Vector3{}},
+		{"capsule side", NewCapsule(Vector3{0, 0, 0}, Vector3{0, 2, 0}, 0.5, mat), fromX(1), 9.5, Vector3{-1, 0, 0}},
+		{"capsule end", NewCapsule(Vector3{0, 0, 0}, Vector3{0, 2, 0}, 0.5, mat), down(0, 0), 7.5, Vector3{0, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := tt.obj.Hit(tt.r, 0.001, math.MaxFloat64)
+			if tt.wantT < 0 {
+				if hr != nil {
+					t.Errorf("hit at %v, want a miss", hr.T)
+				}
+				return
+			}
+			if hr == nil {
+				t.Fatal("missed")
+			}
+			if math.Abs(hr.T-tt.wantT) > 1e-6 {
+				t.Errorf("T = %v, want %v", hr.T, tt.wantT)
+			}
+			if !nearVector(hr.Normal, tt.wantNormal) {
+				t.Errorf("Normal = %v, want %v", hr.Normal, tt.wantNormal)
+			}
+			if hr.U < 0 || hr.U > 1 || hr.V < 0 || hr.V > 1 {
+				t.Errorf("UV = %v, %v, want both within 0..1", hr.U, hr.V)
+			}
+		})
+	}
+}
+
+// Closed quadrics are solids, so they can be carved with CSG.
+func TestQuadrics_CSG(t *testing.T) {
+	mat := NewLambertianMaterial(Vector3{0.5, 0.5, 0.5})
+	tube := NewDifference(
+		NewCappedCylinder(Vector3{}, 1, 2, mat),
+		NewCappedCylinder(Vector3{0, -1, 0}, 0.5, 4, mat),
+	)
+	intervals := tube.Intervals(NewRay(Vector3{-10, 1, 0}, Vector3{1, 0, 0}, 0))
+	if len(intervals) != 2 {
+		t.Fatalf("got %d intervals through the tube wall, want 2", len(intervals))
+	}
+	want := [][2]float64{{9, 9.5}, {10.5, 11}}
+	for i, iv := range intervals {
+		if math.Abs(iv.Enter.T-want[i][0]) > 1e-9 || math.Abs(iv.Exit.T-want[i][1]) > 1e-9 {
+			t.Errorf("interval %d = %v..%v, want %v", i, iv.Enter.T, iv.Exit.T, want[i])
+		}
+	}
+}
+
+// crossingList is a surface with crossings set up by a test, each at
+// a t along a ray running along +X, with a normal whose X is how
+// directly it faces out along the ray.
+type crossingList [][2]float64
+
+func (c crossingList) crossings(r Ray) []*HitRecord {
+	var ret []*HitRecord
+	for _, cr := range c {
+		ret = append(ret, &HitRecord{T: cr[0], Normal: Vector3{cr[1], math.Sqrt(1 - cr[1]*cr[1]), 0}})
+	}
+	return ret
+}
+
+func TestClosedSurface_IntervalsSkipGrazes(t *testing.T) {
+	tests := []struct {
+		name      string
+		crossings crossingList
+		want      [][2]float64
+	}{
+		{"in and out", crossingList{{1, -1}, {2, 1}}, [][2]float64{{1, 2}}},
+		{"graze inside", crossingList{{1, -1}, {1.5, -0.1}, {2, 1}}, [][2]float64{{1, 2}}},
+		{"graze outside", crossingList{{1, -1}, {2, 1}, {2.5, 0.1}, {4, -1}, {5, 1}}, [][2]float64{{1, 2}, {4, 5}}},
+		{"tangent", crossingList{{0.5, 0}, {1, -1}, {2, 1}}, [][2]float64{{1, 2}}},
+		{"never out", crossingList{{1, -1}}, nil},
+	}
+	r := NewRay(Vector3{}, Vector3{1, 0, 0}, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intervals := closedSurface{Parts: []crosser{tt.crossings}}.Intervals(r)
+			var got [][2]float64
+			for _, iv := range intervals {
+				got = append(got, [2]float64{iv.Enter.T, iv.Exit.T})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Intervals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -21,6 +21,47 @@ type Ray struct {
 	Origin    Vector3
 	Direction Vector3
 	Time      float64
+	// stats, if set, counts the work done tracing the path this ray
+	// is part of.
+	stats *RayStats
+	// interior is the media the ray is travelling through.
+	interior *interiorList
+	// wavelengths are what the ray's path carries in spectral mode.
+	wavelengths Wavelengths
+}
+
+// NewRay returns a ray starting a new path.
+func NewRay(origin Vector3, direction Vector3, time float64) Ray {
+	return Ray{Origin: origin, Direction: direction, Time: time}
+}
+
+// Spawn returns a ray which carries on r's path, such as a scattered
+// or transformed ray.  It keeps r's time, statistics, the media it is
+// inside, and its wavelengths.
+func (r Ray) Spawn(origin Vector3, direction Vector3) Ray {
+	return Ray{
+		Origin:      origin,
+		Direction:   direction,
+		Time:        r.Time,
+		stats:       r.stats,
+		interior:    r.interior,
+		wavelengths: r.wavelengths,
+	}
+}
+
+// countSecondaryRay records that the ray was scattered from a hit.
+func (r Ray) countSecondaryRay() {
+	if r.stats != nil {
+		r.stats.SecondaryRays++
+	}
+}
+
+// countIntersectionTest records that something tested the ray for an
+// intersection.
+func (r Ray) countIntersectionTest() {
+	if r.stats != nil {
+		r.stats.IntersectionTests++
+	}
 }
 
 // Point returns the point the ray points to.
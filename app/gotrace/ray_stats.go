@@ -0,0 +1,47 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// RayStats counts the work done tracing rays.
+type RayStats struct {
+	PrimaryRays       int64 `json:"primaryRays"`
+	SecondaryRays     int64 `json:"secondaryRays"`
+	IntersectionTests int64 `json:"intersectionTests"`
+	Pixels            int64 `json:"pixels"`
+}
+
+// Add adds the counts in o to s.
+func (s *RayStats) Add(o RayStats) {
+	s.PrimaryRays += o.PrimaryRays
+	s.SecondaryRays += o.SecondaryRays
+	s.IntersectionTests += o.IntersectionTests
+	s.Pixels += o.Pixels
+}
+
+// Rays is the number of rays of all kinds.
+func (s RayStats) Rays() int64 {
+	return s.PrimaryRays + s.SecondaryRays
+}
+
+// AveragePathLength is the average number of rays in a path from the
+// camera.
+func (s RayStats) AveragePathLength() float64 {
+	if s.PrimaryRays == 0 {
+		return 0
+	}
+	return float64(s.Rays()) / float64(s.PrimaryRays)
+}
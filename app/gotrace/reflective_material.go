@@ -16,7 +16,10 @@
 
 package main
 
-// ReflectiveMaterial defines a matt material.
+// ReflectiveMaterial is a mirror blurred by adding a random offset of
+// up to fuzz to the reflected ray.  It is not physically based, and
+// is kept so existing scenes look the same; ConductorMaterial is the
+// physically based metal.
 type ReflectiveMaterial struct {
 	albedo Vector3
 	fuzz   float64
@@ -29,13 +32,18 @@ func NewReflectiveMaterial(color Vector3, fuzz float64) ReflectiveMaterial {
 }
 
 // Scatter calculates how rays should scatter from this material.
-func (m ReflectiveMaterial) Scatter(r Ray, hr *HitRecord) (bool, Ray, Vector3) {
+func (m ReflectiveMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
 	reflected := reflectRay(r.Direction.Normalize(), hr.Normal).
-		Add(RandomUnitSphere().MultiplyScalar(m.fuzz))
-	scattered := Ray{hr.P, reflected, r.Time}
+		Add(SampleUnitSphere(smp.Get2D()).MultiplyScalar(m.fuzz))
+	scattered := r.Spawn(hr.P, reflected)
 	return scattered.Direction.Dot(hr.Normal) > 0, scattered, m.albedo
 }
 
+// Albedo returns the color of the material.
+func (m ReflectiveMaterial) Albedo(hr *HitRecord) Vector3 {
+	return m.albedo
+}
+
 func reflectRay(v Vector3, n Vector3) Vector3 {
 	return v.Subtract(n.MultiplyScalar(v.Dot(n) * 2))
 }
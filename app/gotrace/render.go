@@ -0,0 +1,165 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+	"sync"
+	"time"
+)
+
+// Frame is a rendered image, along with its AOVs and what each pixel
+// cost to render, if they were asked for.
+type Frame struct {
+	Film  *Film
+	AOVs  *AOVFilm
+	Costs *CostMap
+}
+
+type processedLine struct {
+	y      int
+	film   *Film
+	aovs   *AOVFilm
+	costs  *CostMap
+	worker int
+	stats  RayStats
+}
+
+type workItem struct {
+	y               int
+	imageHeight     int
+	imageWidth      int
+	samplesPerPixel int
+	filter          Filter
+	aovs            []AOV
+	costs           bool
+}
+
+var (
+	// renderProgress tracks the image being rendered, for logging and
+	// for the preview server.
+	renderProgress = NewRenderProgress()
+	// phaseTimer adds up the time spent in each phase of the program.
+	phaseTimer = &PhaseTimer{}
+)
+
+func absorbLines(c chan processedLine) {
+	for line := range c {
+		renderProgress.absorb(line)
+	}
+}
+
+func worker(workerID int, world World, smp Sampler, wg *sync.WaitGroup, w chan workItem, c chan processedLine) {
+	defer wg.Done()
+	for work := range w {
+		if renderProgress.Wait() {
+			renderLine(workerID, world, smp, work, c)
+		}
+	}
+}
+
+// renderLine renders one row of pixels, counted down from the top of
+// the image.  Samples spill onto neighboring rows as far as the filter
+// reaches, so the line's film covers those rows too.
+func renderLine(workerID int, world World, smp Sampler, work workItem, c chan processedLine) {
+	reach := int(math.Ceil(work.filter.Radius()))
+	bounds := image.Rect(0, work.y-reach, work.imageWidth, work.y+reach+1).
+		Intersect(image.Rect(0, 0, work.imageWidth, work.imageHeight))
+	film := NewFilm(bounds, work.filter)
+	var aovs *AOVFilm
+	if len(work.aovs) > 0 {
+		aovs = NewAOVFilm(image.Rect(0, work.y, work.imageWidth, work.y+1), work.aovs)
+	}
+	var costs *CostMap
+	if work.costs {
+		costs = NewCostMap(image.Rect(0, work.y, work.imageWidth, work.y+1))
+	}
+
+	var stats RayStats
+	width := float64(work.imageWidth)
+	height := float64(work.imageHeight)
+	for i := 0; i < work.imageWidth; i++ {
+		var pixel RayStats
+		start := time.Now()
+		for s := 0; s < work.samplesPerPixel; s++ {
+			smp.StartPixelSample(i, work.y, s)
+			dx, dy := smp.Get2D()
+			x := float64(i) + dx
+			y := float64(work.y) + dy
+			color := Vector3{}
+			if ok, ray := world.Camera.GetRay(x/width, 1-y/height, smp); ok {
+				pixel.PrimaryRays++
+				ray.stats = &pixel
+				if world.Spectral {
+					ray.wavelengths = SampleWavelengths(smp.Get1D())
+				}
+				var hr *HitRecord
+				color, hr = world.CastFirst(ray, world.MaxDepth, smp)
+				color = ray.wavelengths.ToRGB(color)
+				if aovs != nil && hr != nil {
+					a := firstHitAOVs(world.Camera, ray, hr, work.imageWidth, work.imageHeight)
+					aovs.AddSample(i, work.y, &a)
+				}
+			}
+			film.AddSample(x, y, color)
+		}
+		pixel.Pixels++
+		if costs != nil {
+			costs.AddPixel(i, work.y, pixel, time.Since(start))
+		}
+		stats.Add(pixel)
+	}
+	c <- processedLine{work.y, film, aovs, costs, workerID, stats}
+}
+
+// renderImage renders the world, one line at a time, on as many
+// workers as asked for.
+func renderImage(world World) *Frame {
+	defer phaseTimer.Start("render")()
+	bounds := image.Rect(0, 0, *imageWidth, *imageHeight)
+	frame := &Frame{Film: NewFilm(bounds, pixelFilter)}
+	if len(renderAOVs) > 0 {
+		frame.AOVs = NewAOVFilm(bounds, renderAOVs)
+	}
+	if len(heatMaps) > 0 {
+		frame.Costs = NewCostMap(bounds)
+	}
+	renderProgress.Start(frame, *imageWidth*samplesPerPixel)
+
+	resultChan := make(chan processedLine, *imageHeight)
+	workChan := make(chan workItem, *imageHeight)
+	wg := sync.WaitGroup{}
+	for i := 0; i < *nCPU; i++ {
+		wg.Add(1)
+		go worker(i, world, pixelSampler.Clone(int64(i)), &wg, workChan, resultChan)
+	}
+
+	absorbed := make(chan struct{})
+	go func() {
+		absorbLines(resultChan)
+		close(absorbed)
+	}()
+	for j := 0; j < *imageHeight; j++ {
+		workChan <- workItem{j, *imageHeight, *imageWidth, samplesPerPixel, pixelFilter, renderAOVs, frame.Costs != nil}
+	}
+	close(workChan)
+	wg.Wait()
+	close(resultChan)
+	<-absorbed
+	return frame
+}
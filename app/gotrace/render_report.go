@@ -0,0 +1,89 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderReport sums up a whole run of the renderer.
+type RenderReport struct {
+	Phases            []PhaseTime `json:"phases"`
+	Totals            RayStats    `json:"totals"`
+	Workers           []RayStats  `json:"workers"`
+	RenderSeconds     float64     `json:"renderSeconds"`
+	RaysPerSecond     float64     `json:"raysPerSecond"`
+	AveragePathLength float64     `json:"averagePathLength"`
+	TestsPerRay       float64     `json:"intersectionTestsPerRay"`
+}
+
+// NewRenderReport sums up the work each worker did, and the time spent
+// in each phase.  Throughput is measured over the "render" phase.
+func NewRenderReport(phases []PhaseTime, workers []RayStats) RenderReport {
+	r := RenderReport{
+		Phases:  phases,
+		Workers: workers,
+	}
+	for _, w := range workers {
+		r.Totals.Add(w)
+	}
+	for _, p := range phases {
+		if p.Name == "render" {
+			r.RenderSeconds = p.Seconds
+		}
+	}
+	if r.RenderSeconds > 0 {
+		r.RaysPerSecond = float64(r.Totals.Rays()) / r.RenderSeconds
+	}
+	r.AveragePathLength = r.Totals.AveragePathLength()
+	if rays := r.Totals.Rays(); rays > 0 {
+		r.TestsPerRay = float64(r.Totals.IntersectionTests) / float64(rays)
+	}
+	return r
+}
+
+// String returns the report as a table for people to read.
+func (r RenderReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rays:               %d primary, %d secondary\n", r.Totals.PrimaryRays, r.Totals.SecondaryRays)
+	fmt.Fprintf(&b, "Rays per second:    %s\n", siCount(r.RaysPerSecond))
+	fmt.Fprintf(&b, "Average path:       %.2f rays\n", r.AveragePathLength)
+	fmt.Fprintf(&b, "Intersection tests: %d, %.1f per ray\n", r.Totals.IntersectionTests, r.TestsPerRay)
+	fmt.Fprintf(&b, "Pixels:             %d\n", r.Totals.Pixels)
+	for i, w := range r.Workers {
+		fmt.Fprintf(&b, "  worker %-3d        %d pixels, %d rays, %d tests\n", i, w.Pixels, w.Rays(), w.IntersectionTests)
+	}
+	b.WriteString("Time:\n")
+	for _, p := range r.Phases {
+		fmt.Fprintf(&b, "  %-17s %v\n", p.Name, p.Duration.Round(1e6))
+	}
+	return b.String()
+}
+
+// siCount formats a count with an SI suffix, such as 1.25M.
+func siCount(n float64) string {
+	for _, unit := range []struct {
+		scale  float64
+		suffix string
+	}{{1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "k"}} {
+		if n >= unit.scale {
+			return fmt.Sprintf("%.2f%s", n/unit.scale, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%.0f", n)
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWorld_CastCountsWork(t *testing.T) {
+	w := World{
+		Objects: []Hittable{
+			NewSphere(Vector3{0, 0, -2}, 1, NewReflectiveMaterial(Vector3{1, 1, 1}, 0)),
+			NewSphere(Vector3{10, 0, 0}, 1, NewLambertianMaterial(Vector3{1, 1, 1})),
+		},
+		MaxDepth: 10,
+		TMin:     0.001,
+		TMax:     math.MaxFloat64,
+	}
+	var stats RayStats
+	r := NewRay(Vector3{}, Vector3{0, 0, -1}, 0)
+	r.stats = &stats
+	w.Cast(r, w.MaxDepth, NewSampler("independent", 1))
+
+	// The ray bounces straight back off the mirror, and misses.
+	want := RayStats{SecondaryRays: 1, IntersectionTests: 4}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestNewRenderReport(t *testing.T) {
+	phases := []PhaseTime{
+		{"setup", time.Second, 1},
+		{"render", 2 * time.Second, 2},
+	}
+	workers := []RayStats{
+		{PrimaryRays: 10, SecondaryRays: 20, IntersectionTests: 300, Pixels: 5},
+		{PrimaryRays: 10, SecondaryRays: 10, IntersectionTests: 100, Pixels: 5},
+	}
+	r := NewRenderReport(phases, workers)
+	if want := (RayStats{20, 30, 400, 10}); r.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", r.Totals, want)
+	}
+	if r.RaysPerSecond != 25 {
+		t.Errorf("RaysPerSecond = %v, want 25", r.RaysPerSecond)
+	}
+	if r.AveragePathLength != 2.5 {
+		t.Errorf("AveragePathLength = %v, want 2.5", r.AveragePathLength)
+	}
+	if r.TestsPerRay != 8 {
+		t.Errorf("TestsPerRay = %v, want 8", r.TestsPerRay)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// RoughDielectricMaterial is frosted glass: GGX microfacets which each
+// reflect or refract by the Fresnel equations, blurring both the
+// reflection and what is seen through it.
+type RoughDielectricMaterial struct {
+	medium Medium
+	alpha  float64
+}
+
+// NewRoughDielectricMaterial returns a rough glass, with roughness from
+// 0 for clear to 1.
+func NewRoughDielectricMaterial(indexOfRefraction float64, roughness float64) RoughDielectricMaterial {
+	return NewMediumRoughDielectricMaterial(Medium{IOR: indexOfRefraction}, roughness)
+}
+
+// NewMediumRoughDielectricMaterial returns a rough surface of the
+// medium, which may be colored by absorption, or nested within others.
+func NewMediumRoughDielectricMaterial(medium Medium, roughness float64) RoughDielectricMaterial {
+	return RoughDielectricMaterial{medium: medium, alpha: roughnessToAlpha(roughness)}
+}
+
+// Scatter picks a microfacet by visible normal sampling, then reflects
+// or refracts off it in proportion to its Fresnel reflectance.  As
+// with DielectricMaterial, the ratio of the indexes of the media on
+// either side is what bends the light.
+func (m RoughDielectricMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	outside, outsideDispersive := r.interior.without(m.medium).ior(r.wavelengths)
+	inside := m.medium.iorFor(r.wavelengths)
+	eta := inside / outside
+	if !hr.FrontFace {
+		eta = outside / inside
+	}
+	wavelengths := r.wavelengths
+	if outsideDispersive || m.medium.dispersive(r.wavelengths) {
+		wavelengths = wavelengths.collapse()
+	}
+	frame := newShadingFrame(hr.Normal)
+	wo := frame.toLocal(r.Direction.Normalize().Neg())
+	if wo.Z <= 0 {
+		return false, r, Vector3{}
+	}
+
+	facet := Vector3{0, 0, 1}
+	if m.alpha >= smoothAlpha {
+		u1, u2 := smp.Get2D()
+		facet = sampleGGXVisibleNormal(wo, m.alpha, u1, u2)
+	}
+	cosI := wo.Dot(facet)
+	var wi Vector3
+	reflected := smp.Get1D() < fresnelDielectric(cosI, eta)
+	if !reflected {
+		var ok bool
+		if wi, ok = refractLocal(wo, facet, eta); !ok {
+			reflected = true
+		}
+	}
+	if reflected {
+		wi = reflectLocal(wo, facet)
+		if wi.Z <= 0 {
+			return false, r, Vector3{}
+		}
+	} else if wi.Z >= 0 {
+		return false, r, Vector3{}
+	}
+
+	weight := 1.0
+	if m.alpha >= smoothAlpha {
+		weight = ggxG2(wo, wi, m.alpha) / ggxG1(wo, m.alpha)
+	}
+	scattered := r.Spawn(hr.P, frame.fromLocal(wi))
+	if !reflected {
+		scattered.interior = r.interior.cross(m.medium, hr.FrontFace)
+	}
+	scattered.wavelengths = wavelengths
+	return true, scattered, Vector3{weight, weight, weight}
+}
+
+// Interior returns the medium inside the surface.
+func (m RoughDielectricMaterial) Interior() Medium {
+	return m.medium
+}
+
+// Albedo returns white, since clear glass absorbs nothing.
+func (m RoughDielectricMaterial) Albedo(hr *HitRecord) Vector3 {
+	return Vector3{1, 1, 1}
+}
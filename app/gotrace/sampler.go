@@ -0,0 +1,216 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Sampler provides the random numbers used to render.  Before each
+// sample of a pixel, StartPixelSample is called, and then every number
+// the sample needs is asked for in turn, one or two dimensions at a
+// time.  Samplers which know which pixel, which sample, and which
+// dimension a number is for can spread the numbers far more evenly
+// than independent random numbers, which makes images converge faster.
+//
+// A Sampler is not safe for concurrent use, so each worker uses
+// its own Clone.
+type Sampler interface {
+	StartPixelSample(x int, y int, index int)
+	Get1D() float64
+	Get2D() (float64, float64)
+	Clone(seed int64) Sampler
+}
+
+// NewSampler returns the named sampler, or nil for an unknown name.
+func NewSampler(name string, samplesPerPixel int) Sampler {
+	switch name {
+	case "independent":
+		return NewIndependentSampler(0)
+	case "stratified":
+		return NewStratifiedSampler(samplesPerPixel, 0)
+	case "halton":
+		return NewHaltonSampler(0)
+	case "sobol":
+		return NewSobolSampler(0)
+	case "bluenoise":
+		return NewBlueNoiseSampler(0)
+	}
+	return nil
+}
+
+// pixelSample tracks which number of which pixel sample is being asked
+// for next.
+type pixelSample struct {
+	seed      uint32
+	x, y      int
+	index     int
+	dimension int
+}
+
+// StartPixelSample starts handing out numbers for a new sample.
+func (p *pixelSample) StartPixelSample(x int, y int, index int) {
+	p.x, p.y, p.index, p.dimension = x, y, index, 0
+}
+
+// next returns the current dimension, and moves on to the next one.
+func (p *pixelSample) next() int {
+	d := p.dimension
+	p.dimension++
+	return d
+}
+
+// hash returns a hash of the pixel, dimension, and seed.
+func (p *pixelSample) hash(dimension int) uint32 {
+	return hashInts(p.x, p.y, dimension, int(p.seed))
+}
+
+// mixBits is a 64-bit finalizer which spreads every input bit across
+// the whole output.
+func mixBits(v uint64) uint64 {
+	v ^= v >> 31
+	v *= 0x7fb5d329728ea185
+	v ^= v >> 27
+	v *= 0x81dadef4bc2dd44d
+	v ^= v >> 33
+	return v
+}
+
+func hashInts(values ...int) uint32 {
+	h := uint64(0x9e3779b97f4a7c15)
+	for _, v := range values {
+		h = mixBits(h ^ uint64(v))
+	}
+	return uint32(h)
+}
+
+// toUnit converts 32 random bits into a float64 in [0, 1).
+func toUnit(v uint32) float64 {
+	return float64(v) / (1 << 32)
+}
+
+// permute returns element i of a pseudorandom permutation of [0, l)
+// chosen by p, without storing the permutation.  This is Kensler's
+// hash from "Correlated Multi-Jittered Sampling".
+func permute(i uint32, l uint32, p uint32) uint32 {
+	w := l - 1
+	w |= w >> 1
+	w |= w >> 2
+	w |= w >> 4
+	w |= w >> 8
+	w |= w >> 16
+	for {
+		i ^= p
+		i *= 0xe170893d
+		i ^= p >> 16
+		i ^= (i & w) >> 4
+		i ^= p >> 8
+		i *= 0x0929eb3f
+		i ^= p >> 23
+		i ^= (i & w) >> 1
+		i *= 1 | p>>27
+		i *= 0x6935fa69
+		i ^= (i & w) >> 11
+		i *= 0x74dcb303
+		i ^= (i & w) >> 2
+		i *= 0x9e501cc3
+		i ^= (i & w) >> 2
+		i *= 0xc860a3df
+		i &= w
+		i ^= i >> 5
+		if i < l {
+			break
+		}
+	}
+	return (i + p) % l
+}
+
+// IndependentSampler returns uniform random numbers, with no regard
+// to where they are used.
+type IndependentSampler struct {
+	rng *rand.Rand
+}
+
+// NewIndependentSampler returns an independent sampler.
+func NewIndependentSampler(seed int64) *IndependentSampler {
+	return &IndependentSampler{rng: rand.New(rand.NewSource(seed))}
+}
+
+// StartPixelSample does nothing, since every number is independent.
+func (s *IndependentSampler) StartPixelSample(x int, y int, index int) {}
+
+// Get1D returns a random number in [0, 1).
+func (s *IndependentSampler) Get1D() float64 {
+	return s.rng.Float64()
+}
+
+// Get2D returns two random numbers in [0, 1).
+func (s *IndependentSampler) Get2D() (float64, float64) {
+	return s.rng.Float64(), s.rng.Float64()
+}
+
+// Clone returns a new sampler with its own random number generator.
+func (s *IndependentSampler) Clone(seed int64) Sampler {
+	return NewIndependentSampler(seed)
+}
+
+// StratifiedSampler divides each dimension of each pixel into as many
+// strata as there are samples, and places one jittered sample in each.
+// The strata are visited in a different order for every pixel and
+// dimension, so dimensions are not correlated with each other.
+type StratifiedSampler struct {
+	pixelSample
+	SamplesPerPixel int
+	rng             *rand.Rand
+}
+
+// NewStratifiedSampler returns a stratified sampler for the given
+// number of samples per pixel.
+func NewStratifiedSampler(samplesPerPixel int, seed int64) *StratifiedSampler {
+	return &StratifiedSampler{
+		pixelSample:     pixelSample{seed: uint32(seed)},
+		SamplesPerPixel: samplesPerPixel,
+		rng:             rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Get1D returns a jittered number from this sample's stratum.
+func (s *StratifiedSampler) Get1D() float64 {
+	n := uint32(s.SamplesPerPixel)
+	stratum := permute(uint32(s.index)%n, n, s.hash(s.next()))
+	return (float64(stratum) + s.rng.Float64()) / float64(n)
+}
+
+// Get2D returns a jittered point from this sample's cell of a grid as
+// close to square as the number of samples allows.
+func (s *StratifiedSampler) Get2D() (float64, float64) {
+	nx := int(math.Sqrt(float64(s.SamplesPerPixel)))
+	if nx < 1 {
+		nx = 1
+	}
+	ny := (s.SamplesPerPixel + nx - 1) / nx
+	n := uint32(nx * ny)
+	stratum := int(permute(uint32(s.index)%n, n, s.hash(s.next())))
+	return (float64(stratum%nx) + s.rng.Float64()) / float64(nx),
+		(float64(stratum/nx) + s.rng.Float64()) / float64(ny)
+}
+
+// Clone returns a new sampler with its own random number generator.
+func (s *StratifiedSampler) Clone(seed int64) Sampler {
+	return NewStratifiedSampler(s.SamplesPerPixel, seed)
+}
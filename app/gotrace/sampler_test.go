@@ -0,0 +1,142 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+// strataHit returns how many of n strata along one dimension hold
+// exactly one sample.
+func strataHit(values []float64) int {
+	n := len(values)
+	counts := make([]int, n)
+	for _, v := range values {
+		counts[int(v*float64(n))]++
+	}
+	ret := 0
+	for _, c := range counts {
+		if c == 1 {
+			ret++
+		}
+	}
+	return ret
+}
+
+func TestSamplers_Stratify(t *testing.T) {
+	const spp = 16
+	for _, name := range []string{"stratified", "halton", "sobol", "bluenoise"} {
+		t.Run(name, func(t *testing.T) {
+			smp := NewSampler(name, spp).Clone(1)
+			for dimension := 0; dimension < 4; dimension++ {
+				values := []float64{}
+				for i := 0; i < spp; i++ {
+					smp.StartPixelSample(3, 7, i)
+					for d := 0; d < dimension; d++ {
+						smp.Get1D()
+					}
+					v := smp.Get1D()
+					if v < 0 || v >= 1 {
+						t.Fatalf("dimension %d: %v out of range", dimension, v)
+					}
+					values = append(values, v)
+				}
+				// Halton's later dimensions use bases other than two,
+				// so only stratify at powers of those.  Blue noise
+				// shifts every pixel's points, which moves them off
+				// the strata boundaries.
+				if (name == "halton" && dimension != 0) || name == "bluenoise" {
+					continue
+				}
+				if got := strataHit(values); got != spp {
+					t.Errorf("dimension %d: %d of %d strata hit once", dimension, got, spp)
+				}
+			}
+		})
+	}
+}
+
+func TestSobolSampler_Get2DIsANet(t *testing.T) {
+	const spp = 16
+	smp := NewSobolSampler(0)
+	xs := []float64{}
+	ys := []float64{}
+	cells := map[[2]int]bool{}
+	for i := 0; i < spp; i++ {
+		smp.StartPixelSample(10, 20, i)
+		x, y := smp.Get2D()
+		xs = append(xs, x)
+		ys = append(ys, y)
+		cells[[2]int{int(x * 4), int(y * 4)}] = true
+	}
+	if strataHit(xs) != spp || strataHit(ys) != spp || len(cells) != spp {
+		t.Errorf("points are not stratified: %v %v", xs, ys)
+	}
+}
+
+func TestSamplers_CloneAgrees(t *testing.T) {
+	for _, name := range []string{"halton", "sobol", "bluenoise"} {
+		proto := NewSampler(name, 4)
+		a, b := proto.Clone(1), proto.Clone(2)
+		a.StartPixelSample(5, 6, 2)
+		b.StartPixelSample(5, 6, 2)
+		for d := 0; d < 10; d++ {
+			if va, vb := a.Get1D(), b.Get1D(); va != vb {
+				t.Errorf("%s: dimension %d differs between clones: %v, %v", name, d, va, vb)
+			}
+		}
+	}
+}
+
+func TestMakeBlueNoise(t *testing.T) {
+	const size = 16
+	mask := makeBlueNoise(size, 1.5, 1)
+	sorted := append([]float64(nil), mask...)
+	sort.Float64s(sorted)
+	for i, v := range sorted {
+		if want := (float64(i) + 0.5) / (size * size); v != want {
+			t.Fatalf("rank %d has value %v, want %v", i, v, want)
+		}
+	}
+
+	// The darkest quarter of the mask should be spread out, so no
+	// two of its points are next to each other.
+	for p, v := range mask {
+		if v >= 0.1 {
+			continue
+		}
+		x, y := p%size, p/size
+		for _, d := range [][2]int{{1, 0}, {0, 1}, {1, 1}, {1, -1}} {
+			q := ((y+d[1]+size)%size)*size + (x+d[0])%size
+			if mask[q] < 0.1 {
+				t.Fatalf("dark points at %d and %d are adjacent", p, q)
+			}
+		}
+	}
+}
+
+func TestSampleUnitDisk(t *testing.T) {
+	for i := 0; i <= 10; i++ {
+		for j := 0; j <= 10; j++ {
+			p := SampleUnitDisk(float64(i)/10, float64(j)/10)
+			if p.LengthSquared() > 1+1e-12 {
+				t.Fatalf("SampleUnitDisk() = %v, outside the disk", p)
+			}
+		}
+	}
+}
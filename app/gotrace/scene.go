@@ -0,0 +1,317 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Scene is a scene file, in JSON: where the camera is, the materials
+// by name, and the objects, each of which names its material.  More
+// materials can come from the MTL and glTF files listed in Libraries,
+// which those in Materials override.
+type Scene struct {
+	Camera    *SceneCamera             `json:"camera,omitempty"`
+	Libraries []string                 `json:"libraries,omitempty"`
+	Materials map[string]SceneMaterial `json:"materials,omitempty"`
+	Objects   []SceneObject            `json:"objects"`
+}
+
+// SceneCamera places the camera.
+type SceneCamera struct {
+	LookFrom [3]float64 `json:"lookFrom"`
+	LookAt   [3]float64 `json:"lookAt"`
+	Up       [3]float64 `json:"up"`
+}
+
+// SceneMaterial describes a material.  Type is principled, the
+// default, which uses all the settings, or lambertian, which uses
+// BaseColor, metal, which uses BaseColor and Roughness as its fuzz, or
+// dielectric, which uses IOR.  Only principled materials may use
+// images.
+type SceneMaterial struct {
+	Type               string        `json:"type,omitempty"`
+	BaseColor          *SceneTexture `json:"baseColor,omitempty"`
+	Metallic           *SceneTexture `json:"metallic,omitempty"`
+	Roughness          *SceneTexture `json:"roughness,omitempty"`
+	Specular           *SceneTexture `json:"specular,omitempty"`
+	Clearcoat          *SceneTexture `json:"clearcoat,omitempty"`
+	ClearcoatRoughness *SceneTexture `json:"clearcoatRoughness,omitempty"`
+	Sheen              *SceneTexture `json:"sheen,omitempty"`
+	SheenTint          *SceneTexture `json:"sheenTint,omitempty"`
+	Transmission       *SceneTexture `json:"transmission,omitempty"`
+	IOR                float64       `json:"ior,omitempty"`
+}
+
+// SceneTexture describes a texture: a value, a color, or an image file,
+// which Scale, if given, multiplies.  Images are gamma encoded unless
+// Linear, and Channel, if given, picks r, g or b.
+type SceneTexture struct {
+	Value   *float64    `json:"value,omitempty"`
+	Color   *[3]float64 `json:"color,omitempty"`
+	Image   string      `json:"image,omitempty"`
+	Linear  bool        `json:"linear,omitempty"`
+	Channel string      `json:"channel,omitempty"`
+	Scale   *[3]float64 `json:"scale,omitempty"`
+}
+
+// SceneObject describes an object: a sphere, with the center as its
+// one point, and a radius, a box between two corners, or a triangle.
+type SceneObject struct {
+	Type     string       `json:"type"`
+	Material string       `json:"material"`
+	Points   [][3]float64 `json:"points"`
+	Radius   float64      `json:"radius,omitempty"`
+}
+
+// ReadScene reads a scene file.
+func ReadScene(r io.Reader) (*Scene, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	var s Scene
+	if err := d.Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LoadScene reads a scene from a file.
+func LoadScene(filename string) (*Scene, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadScene(f)
+}
+
+// Write writes the scene as JSON which ReadScene reads back the same.
+func (s *Scene) Write(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(s)
+}
+
+// Build returns the scene's objects, finding the files it names in dir.
+func (s *Scene) Build(dir string) ([]Hittable, error) {
+	files := newTextureFiles(dir)
+	materials := map[string]Material{}
+	for _, lib := range s.Libraries {
+		f, err := os.Open(filepath.Join(dir, lib))
+		if err != nil {
+			return nil, err
+		}
+		var found map[string]PrincipledMaterial
+		switch strings.ToLower(filepath.Ext(lib)) {
+		case ".mtl":
+			found, err = readMTL(f, files)
+		case ".gltf":
+			found, err = readGLTFMaterials(f, files)
+		default:
+			err = fmt.Errorf("unknown kind of material library")
+		}
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", lib, err)
+		}
+		for name, m := range found {
+			materials[name] = m
+		}
+	}
+	names := make([]string, 0, len(s.Materials))
+	for name := range s.Materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m, err := s.Materials[name].material(files)
+		if err != nil {
+			return nil, fmt.Errorf("material %s: %w", name, err)
+		}
+		materials[name] = m
+	}
+
+	ret := make([]Hittable, 0, len(s.Objects))
+	for i, o := range s.Objects {
+		obj, err := o.object(materials)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", i+1, err)
+		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func sceneVector(v [3]float64) Vector3 {
+	return Vector3{v[0], v[1], v[2]}
+}
+
+// object returns the object the description gives.
+func (o SceneObject) object(materials map[string]Material) (Hittable, error) {
+	mat, ok := materials[o.Material]
+	if !ok {
+		return nil, fmt.Errorf("no material %q", o.Material)
+	}
+	points := map[string]int{"sphere": 1, "box": 2, "triangle": 3}
+	n, ok := points[o.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", o.Type)
+	}
+	if len(o.Points) != n {
+		return nil, fmt.Errorf("%s has %d points, want %d", o.Type, len(o.Points), n)
+	}
+	switch o.Type {
+	case "sphere":
+		return NewSphere(sceneVector(o.Points[0]), o.Radius, mat), nil
+	case "box":
+		return NewBox(sceneVector(o.Points[0]), sceneVector(o.Points[1]), mat), nil
+	}
+	return NewTriangle(sceneVector(o.Points[0]), sceneVector(o.Points[1]), sceneVector(o.Points[2]), mat), nil
+}
+
+// material returns the material the description gives.
+func (sm SceneMaterial) material(files *textureFiles) (Material, error) {
+	m := NewPrincipledMaterial(Vector3{0.8, 0.8, 0.8})
+	if sm.IOR != 0 {
+		m.IOR = sm.IOR
+	}
+	settings := []struct {
+		desc  *SceneTexture
+		field *Texture
+	}{
+		{sm.BaseColor, &m.BaseColor},
+		{sm.Metallic, &m.Metallic},
+		{sm.Roughness, &m.Roughness},
+		{sm.Specular, &m.Specular},
+		{sm.Clearcoat, &m.Clearcoat},
+		{sm.ClearcoatRoughness, &m.ClearcoatRoughness},
+		{sm.Sheen, &m.Sheen},
+		{sm.SheenTint, &m.SheenTint},
+		{sm.Transmission, &m.Transmission},
+	}
+	images := false
+	for _, s := range settings {
+		if s.desc == nil {
+			continue
+		}
+		t, err := s.desc.texture(files)
+		if err != nil {
+			return nil, err
+		}
+		*s.field = t
+		images = images || s.desc.Image != ""
+	}
+
+	if sm.Type == "" || sm.Type == "principled" {
+		return m, nil
+	}
+	if images {
+		return nil, fmt.Errorf("%s material with an image", sm.Type)
+	}
+	color := m.BaseColor.Value(0, 0, Vector3{})
+	switch sm.Type {
+	case "lambertian":
+		return NewLambertianMaterial(color), nil
+	case "metal":
+		return NewReflectiveMaterial(color, m.Roughness.Value(0, 0, Vector3{}).X), nil
+	case "dielectric":
+		return NewDielectricMaterial(m.IOR), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", sm.Type)
+}
+
+// texture returns the texture the description gives.
+func (st SceneTexture) texture(files *textureFiles) (Texture, error) {
+	parts := textureParts{factor: Vector3{1, 1, 1}, channel: -1}
+	switch {
+	case st.Value != nil:
+		parts.factor = Vector3{*st.Value, *st.Value, *st.Value}
+	case st.Color != nil:
+		parts.factor = sceneVector(*st.Color)
+	case st.Image != "":
+		im, err := files.load(st.Image, st.Linear)
+		if err != nil {
+			return nil, err
+		}
+		parts.image = im
+		if st.Channel != "" {
+			parts.channel = strings.Index(mtlChannels, st.Channel)
+			if len(st.Channel) != 1 || parts.channel < 0 {
+				return nil, fmt.Errorf("unknown channel %q", st.Channel)
+			}
+		}
+		if st.Scale != nil {
+			parts.factor = sceneVector(*st.Scale)
+		}
+	default:
+		return nil, fmt.Errorf("texture has no value, color or image")
+	}
+	return parts.texture(), nil
+}
+
+// NewSceneMaterial returns the description of a principled material,
+// for writing to a scene file.
+func NewSceneMaterial(m PrincipledMaterial) (SceneMaterial, error) {
+	ret := SceneMaterial{IOR: m.IOR}
+	settings := []struct {
+		desc  **SceneTexture
+		field Texture
+	}{
+		{&ret.BaseColor, m.BaseColor},
+		{&ret.Metallic, m.Metallic},
+		{&ret.Roughness, m.Roughness},
+		{&ret.Specular, m.Specular},
+		{&ret.Clearcoat, m.Clearcoat},
+		{&ret.ClearcoatRoughness, m.ClearcoatRoughness},
+		{&ret.Sheen, m.Sheen},
+		{&ret.SheenTint, m.SheenTint},
+		{&ret.Transmission, m.Transmission},
+	}
+	for _, s := range settings {
+		parts, err := partsOf(s.field)
+		if err != nil {
+			return SceneMaterial{}, err
+		}
+		*s.desc = newSceneTexture(parts)
+	}
+	return ret, nil
+}
+
+// newSceneTexture returns the description of a texture.
+func newSceneTexture(parts textureParts) *SceneTexture {
+	f := parts.factor
+	if parts.image == nil {
+		if f.X == f.Y && f.Y == f.Z {
+			return &SceneTexture{Value: &f.X}
+		}
+		return &SceneTexture{Color: &[3]float64{f.X, f.Y, f.Z}}
+	}
+	ret := &SceneTexture{Image: parts.image.path, Linear: parts.image.linear}
+	if parts.channel >= 0 {
+		ret.Channel = mtlChannels[parts.channel : parts.channel+1]
+	}
+	if f != (Vector3{1, 1, 1}) {
+		ret.Scale = &[3]float64{f.X, f.Y, f.Z}
+	}
+	return ret
+}
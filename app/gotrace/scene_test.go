@@ -0,0 +1,207 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestImage writes a small image to dir, and returns its texture.
+func writeTestImage(t *testing.T, dir string, name string, linear bool) *ImageTexture {
+	t.Helper()
+	im := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	im.Set(0, 0, color.NRGBA{255, 128, 0, 255})
+	im.Set(1, 1, color.NRGBA{0, 64, 255, 255})
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, im); err != nil {
+		t.Fatal(err)
+	}
+	tex, err := newTextureFiles(dir).load(name, linear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tex
+}
+
+// sameMaterial fails the test unless the materials are described the
+// same, down to the image files.
+func sameMaterial(t *testing.T, name string, got PrincipledMaterial, want PrincipledMaterial) {
+	t.Helper()
+	g, err := NewSceneMaterial(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewSceneMaterial(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		var gb, wb bytes.Buffer
+		(&Scene{Materials: map[string]SceneMaterial{name: g}}).Write(&gb)
+		(&Scene{Materials: map[string]SceneMaterial{name: w}}).Write(&wb)
+		t.Errorf("material %s = %s, want %s", name, gb.String(), wb.String())
+	}
+}
+
+// carPaint returns a material which uses most kinds of texture.
+func carPaint(t *testing.T, dir string) PrincipledMaterial {
+	m := NewPrincipledMaterial(Vector3{0.6, 0.1, 0.1})
+	m.BaseColor = NewScaledTexture(writeTestImage(t, dir, "paint.png", false), Vector3{1, 0.5, 0.5})
+	packed := writeTestImage(t, dir, "metal_rough.png", true)
+	m.Metallic = NewChannelTexture(packed, 2)
+	m.Roughness = NewScaledTexture(NewChannelTexture(packed, 1), Vector3{0.5, 0.5, 0.5})
+	m.Clearcoat = NewSolidValue(1)
+	m.ClearcoatRoughness = NewSolidValue(0.05)
+	m.Transmission = NewSolidValue(0.25)
+	m.IOR = 1.45
+	return m
+}
+
+func TestScene_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	paint := carPaint(t, dir)
+	desc, err := NewSceneMaterial(paint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Scene{
+		Camera:    &SceneCamera{LookFrom: [3]float64{0, 1, 5}, Up: [3]float64{0, 1, 0}},
+		Materials: map[string]SceneMaterial{"paint": desc, "floor": {Type: "lambertian", BaseColor: &SceneTexture{Color: &[3]float64{0.5, 0.5, 0.4}}}},
+		Objects: []SceneObject{
+			{Type: "sphere", Material: "paint", Points: [][3]float64{{0, 1, 0}}, Radius: 1},
+			{Type: "box", Material: "floor", Points: [][3]float64{{-5, -1, -5}, {5, 0, 5}}},
+		},
+	}
+	var b bytes.Buffer
+	if err := want.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadScene(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadScene() = %+v, want %+v", got, want)
+	}
+
+	objects, err := got.Build(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("Build() = %d objects, want 2", len(objects))
+	}
+	hr := objects[0].Hit(NewRay(Vector3{0, 1, 5}, Vector3{0, 0, -1}, 0), 0.001, 100)
+	if hr == nil {
+		t.Fatal("Build() sphere missed")
+	}
+	sameMaterial(t, "paint", hr.Material.(PrincipledMaterial), paint)
+	if floor := objects[1].Hit(NewRay(Vector3{0, 5, 3}, Vector3{0, -1, 0}, 0), 0.001, 100); floor == nil || floor.Material != NewLambertianMaterial(Vector3{0.5, 0.5, 0.4}) {
+		t.Errorf("Build() floor = %v, want lambertian", floor)
+	}
+}
+
+func TestScene_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		scene string
+	}{
+		{"unknown field", `{"objects": [], "lights": []}`},
+		{"missing material", `{"objects": [{"type": "sphere", "material": "x", "points": [[0, 0, 0]], "radius": 1}]}`},
+		{"wrong points", `{"materials": {"x": {}}, "objects": [{"type": "box", "material": "x", "points": [[0, 0, 0]]}]}`},
+		{"unknown type", `{"materials": {"x": {}}, "objects": [{"type": "cone", "material": "x", "points": []}]}`},
+		{"empty texture", `{"materials": {"x": {"baseColor": {}}}, "objects": []}`},
+		{"missing image", `{"materials": {"x": {"baseColor": {"image": "nothing.png"}}}, "objects": []}`},
+		{"missing library", `{"libraries": ["nothing.mtl"], "objects": []}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ReadScene(strings.NewReader(tt.scene))
+			if err == nil {
+				_, err = s.Build(t.TempDir())
+			}
+			if err == nil {
+				t.Errorf("scene %s was read", tt.scene)
+			}
+		})
+	}
+}
+
+func TestScene_Libraries(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.mtl"), []byte("newmtl wood\nKd 0.5 0.3 0.1\nPr 0.7\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ReadScene(strings.NewReader(`{
+		"libraries": ["lib.mtl"],
+		"objects": [{"type": "triangle", "material": "wood", "points": [[0, 0, 0], [1, 0, 0], [0, 0, -1]]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := s.Build(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr := objects[0].Hit(NewRay(Vector3{0.2, 1, -0.2}, Vector3{0, -1, 0}, 0), 0.001, 100)
+	if hr == nil {
+		t.Fatal("Build() triangle missed")
+	}
+	want := NewPrincipledMaterial(Vector3{0.5, 0.3, 0.1})
+	want.Roughness = NewSolidValue(0.7)
+	sameMaterial(t, "wood", hr.Material.(PrincipledMaterial), want)
+}
+
+func TestLoadScene_Animate(t *testing.T) {
+	savedWorld, savedCamera, savedAnimate := world, cameraAnimation, animateScene
+	savedFrom, savedAt, savedUp := lookFrom, lookAt, vup
+	defer func() {
+		world, cameraAnimation, animateScene = savedWorld, savedCamera, savedAnimate
+		lookFrom, lookAt, vup = savedFrom, savedAt, savedUp
+	}()
+
+	built := animationWorld(world)
+	if reflect.DeepEqual(built.Objects, world.Objects) {
+		t.Error("animationWorld() left the built in scene still")
+	}
+
+	filename := filepath.Join(t.TempDir(), "scene.json")
+	scene := `{
+		"materials": {"matte": {"type": "lambertian", "baseColor": {"color": [0.5, 0.5, 0.5]}}},
+		"objects": [{"type": "sphere", "material": "matte", "points": [[0, 1, 0]], "radius": 1}]
+	}`
+	if err := os.WriteFile(filename, []byte(scene), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loadScene(filename)
+	got := animationWorld(world)
+	if !reflect.DeepEqual(got.Objects, world.Objects) {
+		t.Errorf("animationWorld() moved the objects of a loaded scene")
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// DistanceFunc is a signed distance field: it returns how far p is
+// from the nearest surface, negative inside.  It may underestimate the
+// distance, which only slows down sphere tracing, but must never
+// overestimate it, or rays will step through the surface.
+type DistanceFunc func(p Vector3) float64
+
+// SDFSphere is a sphere.
+func SDFSphere(center Vector3, radius float64) DistanceFunc {
+	return func(p Vector3) float64 {
+		return p.Subtract(center).Length() - radius
+	}
+}
+
+// SDFBox is an axis aligned box, extending halfSize from its center
+// along each axis.
+func SDFBox(center Vector3, halfSize Vector3) DistanceFunc {
+	return SDFRoundBox(center, halfSize, 0)
+}
+
+// SDFRoundBox is a box with its edges rounded off to radius, within
+// the same extent as the unrounded box.
+func SDFRoundBox(center Vector3, halfSize Vector3, radius float64) DistanceFunc {
+	return func(p Vector3) float64 {
+		d := p.Subtract(center)
+		q := Vector3{math.Abs(d.X), math.Abs(d.Y), math.Abs(d.Z)}.Subtract(halfSize).AddScalar(radius)
+		outside := maxVector(q, Vector3{}).Length()
+		inside := math.Min(math.Max(q.X, math.Max(q.Y, q.Z)), 0)
+		return outside + inside - radius
+	}
+}
+
+// SDFTorus is a ring around the Y axis.
+func SDFTorus(center Vector3, majorRadius float64, minorRadius float64) DistanceFunc {
+	return func(p Vector3) float64 {
+		d := p.Subtract(center)
+		return math.Hypot(math.Hypot(d.X, d.Z)-majorRadius, d.Y) - minorRadius
+	}
+}
+
+// SDFCapsule is a line segment from a to b, thickened to radius.
+func SDFCapsule(a Vector3, b Vector3, radius float64) DistanceFunc {
+	ab := b.Subtract(a)
+	return func(p Vector3) float64 {
+		h := clamp(p.Subtract(a).Dot(ab)/ab.LengthSquared(), 0, 1)
+		return p.Subtract(a.Add(ab.MultiplyScalar(h))).Length() - radius
+	}
+}
+
+// SDFPlane is the half space below the plane through point, facing
+// normal.
+func SDFPlane(point Vector3, normal Vector3) DistanceFunc {
+	n := normal.Normalize()
+	return func(p Vector3) float64 {
+		return p.Subtract(point).Dot(n)
+	}
+}
+
+// SDFUnion is inside any of the fields.
+func SDFUnion(fields ...DistanceFunc) DistanceFunc {
+	return func(p Vector3) float64 {
+		d := math.Inf(1)
+		for _, f := range fields {
+			d = math.Min(d, f(p))
+		}
+		return d
+	}
+}
+
+// SDFIntersection is inside both a and b.
+func SDFIntersection(a DistanceFunc, b DistanceFunc) DistanceFunc {
+	return func(p Vector3) float64 {
+		return math.Max(a(p), b(p))
+	}
+}
+
+// SDFDifference is inside a but not b.
+func SDFDifference(a DistanceFunc, b DistanceFunc) DistanceFunc {
+	return func(p Vector3) float64 {
+		return math.Max(a(p), -b(p))
+	}
+}
+
+// SDFSmoothUnion blends a and b together where they are within about
+// k of each other, using a polynomial smooth minimum.
+func SDFSmoothUnion(a DistanceFunc, b DistanceFunc, k float64) DistanceFunc {
+	return func(p Vector3) float64 {
+		da, db := a(p), b(p)
+		h := clamp(0.5+0.5*(db-da)/k, 0, 1)
+		return db + (da-db)*h - k*h*(1-h)
+	}
+}
+
+// SDFTranslate moves the field by offset.
+func SDFTranslate(f DistanceFunc, offset Vector3) DistanceFunc {
+	return func(p Vector3) float64 {
+		return f(p.Subtract(offset))
+	}
+}
+
+// SDFScale scales the field about the origin.
+func SDFScale(f DistanceFunc, scale float64) DistanceFunc {
+	return func(p Vector3) float64 {
+		return f(p.DivideScalar(scale)) * scale
+	}
+}
+
+// SDFTwist twists the field around the Y axis by rate radians per unit
+// of height.  Twisting stretches space, more so further from the axis,
+// so the distance is shrunk to match.
+func SDFTwist(f DistanceFunc, rate float64) DistanceFunc {
+	return func(p Vector3) float64 {
+		angle := rate * p.Y
+		c, s := math.Cos(angle), math.Sin(angle)
+		q := Vector3{c*p.X - s*p.Z, p.Y, s*p.X + c*p.Z}
+		stretch := rate * math.Hypot(p.X, p.Z)
+		return f(q) / math.Sqrt(1+stretch*stretch)
+	}
+}
+
+// SDFRepeat repeats the field forever, every period along each axis.
+// A zero period leaves that axis alone.  The field should fit within
+// one period, centered on the origin.
+func SDFRepeat(f DistanceFunc, period Vector3) DistanceFunc {
+	wrap := func(x float64, period float64) float64 {
+		if period == 0 {
+			return x
+		}
+		return x - period*math.Round(x/period)
+	}
+	return func(p Vector3) float64 {
+		return f(Vector3{wrap(p.X, period.X), wrap(p.Y, period.Y), wrap(p.Z, period.Z)})
+	}
+}
+
+// SDFDisplace adds displacement to the surface of f.  The distance is
+// divided by one more than the steepest slope of displacement, so
+// sphere tracing stays safe.
+func SDFDisplace(f DistanceFunc, displacement func(p Vector3) float64, slope float64) DistanceFunc {
+	return func(p Vector3) float64 {
+		return (f(p) + displacement(p)) / (1 + slope)
+	}
+}
+
+// SDFMandelbulb is the Mandelbulb fractal of the given power, usually
+// 8, centered on the origin and about 1.2 across.  More iterations add
+// finer detail.
+func SDFMandelbulb(power float64, iterations int) DistanceFunc {
+	return func(p Vector3) float64 {
+		z := p
+		dr := 1.0
+		r := 0.0
+		for i := 0; i < iterations; i++ {
+			r = z.Length()
+			if r > 2 {
+				break
+			}
+			theta := math.Acos(clamp(z.Y/r, -1, 1)) * power
+			phi := math.Atan2(z.Z, z.X) * power
+			dr = math.Pow(r, power-1)*power*dr + 1
+			zr := math.Pow(r, power)
+			z = Vector3{
+				math.Sin(theta) * math.Cos(phi),
+				math.Cos(theta),
+				math.Sin(theta) * math.Sin(phi),
+			}.MultiplyScalar(zr).Add(p)
+		}
+		if r == 0 {
+			return 0
+		}
+		return 0.5 * math.Log(r) * r / dr
+	}
+}
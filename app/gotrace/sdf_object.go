@@ -0,0 +1,90 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type sdfObject struct {
+	Distance DistanceFunc
+	Bounds   aabb
+	Epsilon  float64
+	MaxSteps int
+	Material Material
+}
+
+// NewSDF returns an object whose surface is where distance is zero,
+// found by sphere tracing: stepping along the ray by the distance to
+// the nearest surface, which can never overshoot it.  The surface must
+// lie within the box from min to max, where marching starts and stops.
+// A ray is taken to have hit once it is within epsilon of the surface,
+// which should be well below World.TMin, and gives up after maxSteps.
+func NewSDF(distance DistanceFunc, min Vector3, max Vector3, epsilon float64, maxSteps int, mat Material) Hittable {
+	return sdfObject{
+		Distance: distance,
+		Bounds:   aabb{minVector(min, max), maxVector(min, max)},
+		Epsilon:  epsilon,
+		MaxSteps: maxSteps,
+		Material: mat,
+	}
+}
+
+func (s sdfObject) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	r.countIntersectionTest()
+	t, tEnd, ok := s.Bounds.hitRange(r, tMin, tMax)
+	if !ok {
+		return nil
+	}
+	// Distances are along the surface, and t is in units of the
+	// ray's direction.
+	scale := 1 / r.Direction.Length()
+	for step := 0; step < s.MaxSteps && t <= tEnd; step++ {
+		p := r.Point(t)
+		// Rays leaving from inside, such as through glass, march
+		// out to the surface the same way.
+		d := math.Abs(s.Distance(p))
+		if d < s.Epsilon {
+			return s.surface(r, t, p)
+		}
+		t += d * scale
+	}
+	return nil
+}
+
+// surface returns the hit at p, with the normal taken from the
+// gradient of the field.
+func (s sdfObject) surface(r Ray, t float64, p Vector3) *HitRecord {
+	h := s.Epsilon
+	gradient := Vector3{
+		s.Distance(p.Add(Vector3{X: h})) - s.Distance(p.Subtract(Vector3{X: h})),
+		s.Distance(p.Add(Vector3{Y: h})) - s.Distance(p.Subtract(Vector3{Y: h})),
+		s.Distance(p.Add(Vector3{Z: h})) - s.Distance(p.Subtract(Vector3{Z: h})),
+	}
+	normal := gradient.Normalize()
+	hr := &HitRecord{T: t, P: p, Material: s.Material}
+	hr.SetFaceNormal(r, normal)
+	// Fields have no natural parameterization, so project onto a
+	// sphere around the middle of the bounds.  That projection does
+	// not follow the surface, so DPDU and DPDV are left zero, and
+	// Tangents falls back to an arbitrary frame around the normal.
+	center := s.Bounds.minimum.Add(s.Bounds.maximum).MultiplyScalar(0.5)
+	hr.U, hr.V = sphereUV(p.Subtract(center).Normalize())
+	return hr
+}
+
+func (s sdfObject) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return s.Bounds, true
+}
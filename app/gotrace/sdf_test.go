@@ -0,0 +1,100 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSDF_Hit(t *testing.T) {
+	mat := NewLambertianMaterial(Vector3{0.5, 0.5, 0.5})
+	bounds := Vector3{3, 3, 3}
+	sdf := func(f DistanceFunc) Hittable {
+		return NewSDF(f, bounds.Neg(), bounds, 1e-6, 500, mat)
+	}
+	fromX := NewRay(Vector3{-10, 0, 0}, Vector3{2, 0, 0}, 0)
+	tests := []struct {
+		name       string
+		obj        Hittable
+		r          Ray
+		wantT      float64
+		wantNormal Vector3
+	}{
+		{"sphere", sdf(SDFSphere(Vector3{}, 1)), fromX, 4.5, Vector3{-1, 0, 0}},
+		{"sphere misses", sdf(SDFSphere(Vector3{}, 1)), NewRay(Vector3{-10, 2, 0}, Vector3{1, 0, 0}, 0), -1, Vector3{}},
+		{"sphere from inside", sdf(SDFSphere(Vector3{}, 1)), NewRay(Vector3{}, Vector3{1, 0, 0}, 0), 1, Vector3{-1, 0, 0}},
+		{"box", sdf(SDFBox(Vector3{}, Vector3{1, 2, 2})), fromX, 4.5, Vector3{-1, 0, 0}},
+		{"union", sdf(SDFUnion(SDFSphere(Vector3{-1, 0, 0}, 0.5), SDFSphere(Vector3{1, 0, 0}, 0.5))), fromX, 4.25, Vector3{-1, 0, 0}},
+		{"difference", sdf(SDFDifference(SDFSphere(Vector3{}, 1), SDFSphere(Vector3{-1, 0, 0}, 0.5))), fromX, 4.75, Vector3{-1, 0, 0}},
+		{"repeat", sdf(SDFRepeat(SDFSphere(Vector3{}, 0.25), Vector3{Y: 1})), NewRay(Vector3{-10, 2, 0}, Vector3{1, 0, 0}, 0), 9.75, Vector3{-1, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := tt.obj.Hit(tt.r, 0.001, math.Inf(1))
+			if tt.wantT < 0 {
+				if hr != nil {
+					t.Fatalf("Hit() = %v, want miss", hr.T)
+				}
+				return
+			}
+			if hr == nil {
+				t.Fatalf("Hit() missed, want %v", tt.wantT)
+			}
+			if math.Abs(hr.T-tt.wantT) > 1e-5 {
+				t.Errorf("Hit().T = %v, want %v", hr.T, tt.wantT)
+			}
+			if hr.Normal.Subtract(tt.wantNormal).Length() > 1e-3 {
+				t.Errorf("Hit().Normal = %v, want %v", hr.Normal, tt.wantNormal)
+			}
+		})
+	}
+}
+
+func TestSDFSmoothUnion(t *testing.T) {
+	a := SDFSphere(Vector3{-1, 0, 0}, 1)
+	b := SDFSphere(Vector3{1, 0, 0}, 1)
+	f := SDFSmoothUnion(a, b, 0.5)
+	// Far from where they meet, the blend is the plain union.
+	p := Vector3{-3, 0, 0}
+	if got := f(p); math.Abs(got-a(p)) > 1e-9 {
+		t.Errorf("SDFSmoothUnion far = %v, want %v", got, a(p))
+	}
+	// Where they touch, the blend fills in the seam.
+	p = Vector3{0, 1, 0}
+	if got, hard := f(p), SDFUnion(a, b)(p); got >= hard {
+		t.Errorf("SDFSmoothUnion seam = %v, want below %v", got, hard)
+	}
+}
+
+func TestSDFMandelbulb(t *testing.T) {
+	f := SDFMandelbulb(8, 10)
+	if d := f(Vector3{}); d > 0 {
+		t.Errorf("Mandelbulb center = %v, want inside", d)
+	}
+	if d := f(Vector3{2, 0, 0}); d <= 0 {
+		t.Errorf("Mandelbulb outside = %v, want positive", d)
+	}
+	obj := NewSDF(f, Vector3{-1.5, -1.5, -1.5}, Vector3{1.5, 1.5, 1.5}, 1e-4, 500, nil)
+	hr := obj.Hit(NewRay(Vector3{0, 0, -5}, Vector3{0, 0, 1}, 0), 0.001, math.Inf(1))
+	if hr == nil {
+		t.Fatal("Mandelbulb missed")
+	}
+	if hr.P.Length() > 1.3 || hr.P.Length() < 0.5 {
+		t.Errorf("Mandelbulb hit at %v, want near the unit sphere", hr.P)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// rampTexture is U, in every channel.
+type rampTexture struct{}
+
+func (rampTexture) Value(u float64, v float64, p Vector3) Vector3 {
+	return Vector3{u, u, u}
+}
+
+func TestSphereTangents(t *testing.T) {
+	s := NewSphere(Vector3{1, 2, 3}, 2, nil)
+	for _, d := range []Vector3{{1, 0.5, 0.2}, {-0.3, -0.8, 0.4}, {0.1, 0.2, -1}} {
+		d = d.Normalize()
+		hr := s.Hit(NewRay(Vector3{1, 2, 3}.Add(d.MultiplyScalar(5)), d.Neg(), 0), 0, math.Inf(1))
+		if math.Abs(hr.DPDU.Dot(hr.Normal)) > 1e-9 || math.Abs(hr.DPDV.Dot(hr.Normal)) > 1e-9 {
+			t.Errorf("tangents %v, %v are not perpendicular to %v", hr.DPDU, hr.DPDV, hr.Normal)
+		}
+		const eps = 1e-6
+		u, _ := sphereUV(hr.P.Add(hr.DPDU.MultiplyScalar(eps)).Subtract(Vector3{1, 2, 3}).Normalize())
+		_, v := sphereUV(hr.P.Add(hr.DPDV.MultiplyScalar(eps)).Subtract(Vector3{1, 2, 3}).Normalize())
+		if math.Abs(u-hr.U-eps) > 1e-8 || math.Abs(v-hr.V-eps) > 1e-8 {
+			t.Errorf("moving along the tangents changes UV by %v, %v, want %v", u-hr.U, v-hr.V, eps)
+		}
+	}
+}
+
+func TestSurfaceTangents(t *testing.T) {
+	terrain, err := NewHeightfield(3, 3, []float64{0, 0.2, 0.1, 0.4, 0.9, 0.3, 0.2, 0.5, 0}, Vector3{}, Vector3{2, 1, 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		object Hittable
+		ray    Ray
+	}{
+		{"cone", NewCone(Vector3{0, 0, 0}, 1, 2, 360, nil), NewRay(Vector3{3, 1, 2}, Vector3{-1, -0.3, -0.5}, 0)},
+		{"torus", NewTorus(Vector3{0, 0, 0}, 2, 0.5, 360, nil), NewRay(Vector3{2, 3, 1}, Vector3{-0.2, -1, 0.1}, 0)},
+		{"capsule side", NewCapsule(Vector3{0, 0, 0}, Vector3{0, 2, 0}, 0.5, nil), NewRay(Vector3{3, 1.2, 1}, Vector3{-1, 0.1, -0.3}, 0)},
+		{"capsule end", NewCapsule(Vector3{0, 0, 0}, Vector3{0, 2, 0}, 0.5, nil), NewRay(Vector3{0.2, 5, 0.1}, Vector3{0, -1, 0.05}, 0)},
+		{"heightfield", terrain, NewRay(Vector3{0.7, 5, 1.3}, Vector3{0.1, -1, 0.05}, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := tt.object.Hit(tt.ray, 0.001, math.Inf(1))
+			if hr == nil {
+				t.Fatal("ray missed")
+			}
+			// The heightfield's normals are interpolated, so the
+			// tangents need only span a plane facing the same way.
+			if n := hr.outwardNormal(); math.Abs(hr.DPDU.Cross(hr.DPDV).Normalize().Dot(n)) < 0.9 {
+				t.Errorf("tangents %v, %v do not lie along the surface with normal %v", hr.DPDU, hr.DPDV, n)
+			}
+			// Moving the ray across by a tangent moves UV along it.
+			const eps = 1e-6
+			for i, d := range []Vector3{hr.DPDU, hr.DPDV} {
+				moved := tt.ray
+				moved.Origin = moved.Origin.Add(d.MultiplyScalar(eps))
+				next := tt.object.Hit(moved, 0.001, math.Inf(1))
+				if next == nil {
+					t.Fatal("moved ray missed")
+				}
+				want := [2]float64{}
+				want[i] = eps
+				if du, dv := next.U-hr.U, next.V-hr.V; math.Abs(du-want[0]) > eps*1e-3 || math.Abs(dv-want[1]) > eps*1e-3 {
+					t.Errorf("moving along tangent %d changes UV by %v, %v, want %v", i, du, dv, want)
+				}
+			}
+		})
+	}
+}
+
+func TestShadingNormals(t *testing.T) {
+	// A triangle facing up, with U running along X and V along -Z.
+	tri := NewTriangle(Vector3{0, 0, 0}, Vector3{1, 0, 0}, Vector3{0, 0, -1}, nil)
+	down := NewRay(Vector3{0.25, 1, -0.25}, Vector3{0, -1, 0}, 0)
+	hr := tri.Hit(down, 0, math.Inf(1))
+	if hr.DPDU != (Vector3{1, 0, 0}) || hr.DPDV != (Vector3{0, 0, -1}) {
+		t.Fatalf("tangents = %v, %v, want along X and -Z", hr.DPDU, hr.DPDV)
+	}
+	up := Vector3{0, 1, 0}
+	tests := []struct {
+		name string
+		got  Vector3
+		want Vector3
+	}{
+		{"flat bump", NewBumpMaterial(nil, NewSolidValue(3), 1).normal(hr), up},
+		{"sloped bump", NewBumpMaterial(nil, rampTexture{}, 1).normal(hr), Vector3{-1, 1, 0}.Normalize()},
+		{"flat normal map", NewNormalMapMaterial(nil, NewSolidColor(Vector3{0.5, 0.5, 1}), 1).normal(hr), up},
+		{"tilted normal map", NewNormalMapMaterial(nil, NewSolidColor(Vector3{1, 0.5, 0.5}), 1).normal(hr), Vector3{1, 0, 0}},
+		{"tilted toward V", NewNormalMapMaterial(nil, NewSolidColor(Vector3{0.5, 1, 1}), 1).normal(hr), Vector3{0, 1, -1}.Normalize()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.Subtract(tt.want).Length() > 1e-6 {
+				t.Errorf("normal() = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+
+	// From below, the bumps are seen from the other side.
+	back := tri.Hit(NewRay(Vector3{0.25, -1, -0.25}, Vector3{0, 1, 0}, 0), 0, math.Inf(1))
+	if got, want := NewBumpMaterial(nil, rampTexture{}, 1).normal(back), (Vector3{1, -1, 0}.Normalize()); got.Subtract(want).Length() > 1e-6 {
+		t.Errorf("normal() from below = %v, want %v", got, want)
+	}
+}
+
+func TestAlphaMask(t *testing.T) {
+	// The near side of the sphere has U of 1/4, and the far side 3/4.
+	s := NewAlphaMask(NewSphere(Vector3{}, 1, nil), rampTexture{})
+	hr := s.Hit(NewRay(Vector3{0, 0, 5}, Vector3{0, 0, -1}, 0), 0.001, math.Inf(1))
+	if hr == nil || math.Abs(hr.T-6) > 1e-9 {
+		t.Errorf("Hit() = %v, want the far side", hr)
+	}
+	hr = s.Hit(NewRay(Vector3{0, 0, -5}, Vector3{0, 0, 1}, 0), 0.001, math.Inf(1))
+	if hr == nil || math.Abs(hr.T-4) > 1e-9 {
+		t.Errorf("Hit() = %v, want the near side", hr)
+	}
+	if hr := NewAlphaMask(NewSphere(Vector3{}, 1, nil), NewSolidValue(0)).Hit(NewRay(Vector3{0, 0, 5}, Vector3{0, 0, -1}, 0), 0.001, math.Inf(1)); hr != nil {
+		t.Errorf("Hit() of a fully cut out sphere = %v, want nil", hr)
+	}
+}
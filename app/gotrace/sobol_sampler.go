@@ -0,0 +1,103 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math/bits"
+
+// sobolDirections holds the direction numbers for the second dimension
+// of the Sobol sequence.  The first dimension is the van der Corput
+// sequence, which is just the index with its bits reversed.
+var sobolDirections = func() [32]uint32 {
+	var v [32]uint32
+	v[0] = 1 << 31
+	for i := 1; i < 32; i++ {
+		v[i] = v[i-1] ^ (v[i-1] >> 1)
+	}
+	return v
+}()
+
+// sobol2D returns the first two dimensions of the Sobol sequence.
+func sobol2D(index uint32) (uint32, uint32) {
+	y := uint32(0)
+	for i := 0; index>>i != 0; i++ {
+		if index&(1<<i) != 0 {
+			y ^= sobolDirections[i]
+		}
+	}
+	return bits.Reverse32(index), y
+}
+
+// laineKarrasPermutation scrambles the bits of x such that each bit
+// only depends on the bits below it.
+func laineKarrasPermutation(x uint32, seed uint32) uint32 {
+	x += seed
+	x ^= x * 0x6c50b47c
+	x ^= x * 0xb82f1e52
+	x ^= x * 0xc7afe638
+	x ^= x * 0x8d22f6e6
+	return x
+}
+
+// nestedUniformScramble performs an Owen scramble of x, which keeps the
+// stratification of a Sobol sequence while randomizing it.
+func nestedUniformScramble(x uint32, seed uint32) uint32 {
+	x = bits.Reverse32(x)
+	x = laineKarrasPermutation(x, seed)
+	return bits.Reverse32(x)
+}
+
+// shuffledScrambledSobol2D returns a point from an Owen scrambled Sobol
+// sequence, visited in a shuffled order.  This follows Burley's
+// "Practical Hash-based Owen Scrambling".
+func shuffledScrambledSobol2D(index uint32, seed uint32) (float64, float64) {
+	index = nestedUniformScramble(index, seed)
+	x, y := sobol2D(index)
+	x = nestedUniformScramble(x, hashInts(int(seed), 0))
+	y = nestedUniformScramble(y, hashInts(int(seed), 1))
+	return toUnit(x), toUnit(y)
+}
+
+// SobolSampler uses an Owen scrambled Sobol sequence.  Every one or two
+// dimensions asked for gets its own independently scrambled and
+// shuffled copy of the first two Sobol dimensions, which stay well
+// stratified at any sample count, rather than using higher Sobol
+// dimensions, which are poor in pairs.
+type SobolSampler struct {
+	pixelSample
+}
+
+// NewSobolSampler returns a Sobol sampler.
+func NewSobolSampler(seed int64) *SobolSampler {
+	return &SobolSampler{pixelSample{seed: uint32(seed)}}
+}
+
+// Get1D returns the next dimension.
+func (s *SobolSampler) Get1D() float64 {
+	x, _ := shuffledScrambledSobol2D(uint32(s.index), s.hash(s.next()))
+	return x
+}
+
+// Get2D returns the next two dimensions.
+func (s *SobolSampler) Get2D() (float64, float64) {
+	return shuffledScrambledSobol2D(uint32(s.index), s.hash(s.next()))
+}
+
+// Clone returns a new Sobol sampler.  The numbers depend only on the
+// pixel and sample, so the seed is ignored, and every worker agrees.
+func (s *SobolSampler) Clone(seed int64) Sampler {
+	return &SobolSampler{pixelSample{seed: s.seed}}
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// Interval is a span of a ray which is inside a solid.  Enter and Exit
+// are where the ray crosses the surface, with Normal pointing out of
+// the solid rather than against the ray, and Enter.T <= Exit.T.
+type Interval struct {
+	Enter *HitRecord
+	Exit  *HitRecord
+}
+
+// Solid is a closed Hittable, with a well defined inside, so it can be
+// combined with other solids using CSG.
+type Solid interface {
+	Hittable
+
+	// Intervals returns every span of the whole line through r which
+	// is inside the solid, in order along the ray, whatever the sign
+	// of t.  A span may be open at either end, with T infinite.
+	Intervals(r Ray) []Interval
+}
+
+// firstSurface returns the first place within tMin to tMax at which
+// the ray crosses the surface bounding the intervals, with the normal
+// set against the ray, or nil if there is none.
+func firstSurface(r Ray, intervals []Interval, tMin float64, tMax float64) *HitRecord {
+	for _, iv := range intervals {
+		for _, hr := range []*HitRecord{iv.Enter, iv.Exit} {
+			if hr.T < tMin || math.IsInf(hr.T, 0) {
+				continue
+			}
+			if hr.T > tMax {
+				return nil
+			}
+			ret := *hr
+			ret.SetFaceNormal(r, hr.Normal)
+			return &ret
+		}
+	}
+	return nil
+}
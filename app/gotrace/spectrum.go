@@ -0,0 +1,200 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// The range of wavelengths, in nanometres, rendered in spectral mode.
+const (
+	minWavelength = 380.0
+	maxWavelength = 780.0
+)
+
+// Wavelengths are what a path carries in spectral mode: a hero
+// wavelength, chosen at random, and two more spaced evenly from it
+// across the visible range.  While they travel together, the three
+// components of a color along the path are the values at each
+// wavelength, rather than red, green and blue.
+type Wavelengths struct {
+	Lambda [3]float64
+	// Active is set in spectral mode.
+	Active bool
+	// Collapsed is set once something which depends on wavelength,
+	// such as dispersion, has split the path, leaving only the hero
+	// wavelength to follow it.
+	Collapsed bool
+}
+
+// SampleWavelengths returns the wavelengths for a path, from u between
+// 0 and 1.
+func SampleWavelengths(u float64) Wavelengths {
+	span := maxWavelength - minWavelength
+	w := Wavelengths{Active: true}
+	for i := range w.Lambda {
+		offset := math.Mod(u+float64(i)/3, 1)
+		w.Lambda[i] = minWavelength + offset*span
+	}
+	return w
+}
+
+// collapse returns the wavelengths once only the hero is followed.
+func (w Wavelengths) collapse() Wavelengths {
+	w.Collapsed = true
+	return w
+}
+
+// collapsed returns the values at each wavelength, given those seen
+// by a path which carried on with only the hero wavelength.  The hero
+// then stands for all three.
+func (w Wavelengths) collapsed(v Vector3) Vector3 {
+	return Vector3{3 * v.X, 0, 0}
+}
+
+// fromRGB returns the color at each wavelength of the linear RGB color
+// c, or c itself outside spectral mode.
+func (w Wavelengths) fromRGB(c Vector3) Vector3 {
+	if !w.Active {
+		return c
+	}
+	return Vector3{
+		upsampleRGB(c, w.Lambda[0]),
+		upsampleRGB(c, w.Lambda[1]),
+		upsampleRGB(c, w.Lambda[2]),
+	}
+}
+
+// ToRGB returns the linear RGB color of the values v at each
+// wavelength, or v itself outside spectral mode.  Averaged over many
+// paths, this integrates the spectrum against the CIE 1931 color
+// matching functions, and converts to RGB, white balanced so that an
+// even spectrum is white.
+func (w Wavelengths) ToRGB(v Vector3) Vector3 {
+	if !w.Active {
+		return v
+	}
+	values := [3]float64{v.X, v.Y, v.Z}
+	var xyz Vector3
+	for i, lambda := range w.Lambda {
+		xyz = xyz.Add(cieXYZ(lambda).MultiplyScalar(values[i]))
+	}
+	xyz = xyz.MultiplyScalar((maxWavelength - minWavelength) / (3 * cieYIntegral))
+	return xyzToLinearRGB(xyz).Divide(spectralWhite)
+}
+
+// cieXYZ is the CIE 1931 color matching functions at lambda, by the
+// multi-lobe Gaussian fit of Wyman, Sloan and Shirley, "Simple
+// Analytic Approximations to the CIE XYZ Color Matching Functions".
+func cieXYZ(lambda float64) Vector3 {
+	g := func(mu float64, sigma1 float64, sigma2 float64) float64 {
+		sigma := sigma1
+		if lambda >= mu {
+			sigma = sigma2
+		}
+		t := (lambda - mu) / sigma
+		return math.Exp(-t * t / 2)
+	}
+	return Vector3{
+		1.056*g(599.8, 37.9, 31.0) + 0.362*g(442.0, 16.0, 26.7) - 0.065*g(501.1, 20.4, 26.2),
+		0.821*g(568.8, 46.9, 40.5) + 0.286*g(530.9, 16.3, 31.1),
+		1.217*g(437.0, 11.8, 36.0) + 0.681*g(459.0, 26.0, 13.8),
+	}
+}
+
+// xyzToLinearRGB converts to linear sRGB.
+func xyzToLinearRGB(c Vector3) Vector3 {
+	return Vector3{
+		3.2404542*c.X - 1.5371385*c.Y - 0.4985314*c.Z,
+		-0.9692660*c.X + 1.8760108*c.Y + 0.0415560*c.Z,
+		0.0556434*c.X - 0.2040259*c.Y + 1.0572252*c.Z,
+	}
+}
+
+// integrateCIE returns the integral of the color matching functions
+// over the rendered range.
+func integrateCIE() Vector3 {
+	var sum Vector3
+	for lambda := minWavelength + 0.5; lambda < maxWavelength; lambda++ {
+		sum = sum.Add(cieXYZ(lambda))
+	}
+	return sum
+}
+
+var (
+	cieYIntegral = integrateCIE().Y
+	// spectralWhite is the RGB color of an even spectrum, before
+	// white balancing.
+	spectralWhite = xyzToLinearRGB(integrateCIE().DivideScalar(cieYIntegral))
+)
+
+// Smits' basis spectra, from "An RGB to Spectrum Conversion for
+// Reflectances", in ten bins evenly spread from 380 to 720 nm.
+var (
+	smitsWhite   = [10]float64{1.0000, 1.0000, 0.9999, 0.9993, 0.9992, 0.9998, 1.0000, 1.0000, 1.0000, 1.0000}
+	smitsCyan    = [10]float64{0.9710, 0.9426, 1.0007, 1.0007, 1.0007, 1.0007, 0.1564, 0.0000, 0.0000, 0.0000}
+	smitsMagenta = [10]float64{1.0000, 1.0000, 0.9685, 0.2229, 0.0000, 0.0458, 0.8369, 1.0000, 1.0000, 0.9959}
+	smitsYellow  = [10]float64{0.0001, 0.0000, 0.1088, 0.6651, 1.0000, 1.0000, 0.9996, 0.9586, 0.9685, 0.9840}
+	smitsRed     = [10]float64{0.1012, 0.0515, 0.0000, 0.0000, 0.0000, 0.0000, 0.8325, 1.0149, 1.0149, 1.0149}
+	smitsGreen   = [10]float64{0.0000, 0.0000, 0.0273, 0.7937, 1.0000, 0.9418, 0.1719, 0.0000, 0.0000, 0.0025}
+	smitsBlue    = [10]float64{1.0000, 1.0000, 0.8916, 0.3323, 0.0000, 0.0000, 0.0003, 0.0369, 0.0483, 0.0496}
+)
+
+// smitsAt returns a basis spectrum at lambda, interpolating between
+// the centers of its bins, and holding the end values beyond them.
+func smitsAt(spectrum *[10]float64, lambda float64) float64 {
+	const start, width = 380.0, 34.0
+	x := (lambda-start)/width - 0.5
+	if x <= 0 {
+		return spectrum[0]
+	}
+	if x >= 9 {
+		return spectrum[9]
+	}
+	i := int(x)
+	f := x - float64(i)
+	return spectrum[i]*(1-f) + spectrum[i+1]*f
+}
+
+// upsampleRGB returns the value at lambda of a smooth spectrum with
+// the color c, by Smits' method: as much white as all three channels
+// share, then as much of the two channels which remain as they share,
+// then the last channel alone.
+func upsampleRGB(c Vector3, lambda float64) float64 {
+	r, g, b := c.X, c.Y, c.Z
+	at := func(spectrum *[10]float64) float64 {
+		return smitsAt(spectrum, lambda)
+	}
+	switch {
+	case r <= g && r <= b:
+		ret := r * at(&smitsWhite)
+		if g <= b {
+			return ret + (g-r)*at(&smitsCyan) + (b-g)*at(&smitsBlue)
+		}
+		return ret + (b-r)*at(&smitsCyan) + (g-b)*at(&smitsGreen)
+	case g <= r && g <= b:
+		ret := g * at(&smitsWhite)
+		if r <= b {
+			return ret + (r-g)*at(&smitsMagenta) + (b-r)*at(&smitsBlue)
+		}
+		return ret + (b-g)*at(&smitsMagenta) + (r-b)*at(&smitsRed)
+	default:
+		ret := b * at(&smitsWhite)
+		if r <= g {
+			return ret + (r-b)*at(&smitsYellow) + (g-r)*at(&smitsGreen)
+		}
+		return ret + (g-b)*at(&smitsYellow) + (r-g)*at(&smitsRed)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// averageSpectral returns the RGB color found by averaging f over
+// evenly spread hero wavelengths.
+func averageSpectral(f func(w Wavelengths) Vector3) Vector3 {
+	const n = 3000
+	var sum Vector3
+	for i := 0; i < n; i++ {
+		w := SampleWavelengths((float64(i) + 0.5) / n)
+		sum = sum.Add(w.ToRGB(f(w)))
+	}
+	return sum.DivideScalar(n)
+}
+
+func TestWavelengths_RoundTrip(t *testing.T) {
+	for _, c := range []Vector3{{1, 1, 1}, {0.5, 0.5, 0.5}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {0.8, 0.5, 0.2}} {
+		got := averageSpectral(func(w Wavelengths) Vector3 { return w.fromRGB(c) })
+		if got.Subtract(c).Length() > 0.05 {
+			t.Errorf("%v came back as %v", c, got)
+		}
+	}
+	if got := (Wavelengths{}).ToRGB(Vector3{1, 2, 3}); got != (Vector3{1, 2, 3}) {
+		t.Errorf("ToRGB() outside spectral mode = %v, want it unchanged", got)
+	}
+}
+
+func TestDispersion_IOR(t *testing.T) {
+	tests := []struct {
+		name   string
+		d      Dispersion
+		lambda float64
+		want   float64
+	}{
+		{"BK7 d", BK7, 587.56, 1.5168},
+		{"BK7 F", BK7, 486.13, 1.5224},
+		{"BK7 C", BK7, 656.27, 1.5143},
+		{"diamond", Diamond, 589.3, 2.417},
+		{"cauchy", CauchyDispersion(1.5, 0.005), 500, 1.52},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.d.IOR(tt.lambda); math.Abs(got-tt.want) > 1e-3 {
+				t.Errorf("IOR(%v) = %v, want %v", tt.lambda, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDielectricMaterial_Dispersion(t *testing.T) {
+	m := NewDispersiveDielectricMaterial(Diamond)
+	hr := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+	smp := NewIndependentSampler(1)
+	refracted := func(lambda float64) Ray {
+		for {
+			r := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+			r.wavelengths = Wavelengths{Lambda: [3]float64{lambda, 500, 600}, Active: true}
+			if _, out, _ := m.Scatter(r, hr, smp); out.Direction.Y < 0 {
+				return out
+			}
+		}
+	}
+	blue, red := refracted(420), refracted(680)
+	if !blue.wavelengths.Collapsed {
+		t.Error("Scatter() through diamond did not collapse to the hero wavelength")
+	}
+	// Blue bends more, so ends up further from the incoming direction.
+	if blue.Direction.Normalize().X >= red.Direction.Normalize().X {
+		t.Errorf("Scatter() bent blue to %v and red to %v, want blue bent more", blue.Direction, red.Direction)
+	}
+
+	// Outside spectral mode, the d line index is used.
+	if got := m.Interior().IOR; math.Abs(got-2.417) > 1e-3 {
+		t.Errorf("IOR = %v, want 2.417", got)
+	}
+}
+
+func TestWorld_CastSpectral(t *testing.T) {
+	// Through a slab of clear glass which does not disperse, the sky
+	// should look the same in spectral mode as in RGB.
+	glass := NewDielectricMaterial(1)
+	w := World{Objects: []Hittable{NewSphere(Vector3{}, 1, glass)}, MaxDepth: 10, TMin: 0.001, TMax: math.Inf(1), Spectral: true}
+	r := NewRay(Vector3{0, 0, 5}, Vector3{0, 0, -1}, 0)
+	smp := NewIndependentSampler(1)
+	want := w.Cast(r, w.MaxDepth, smp)
+	got := averageSpectral(func(wl Wavelengths) Vector3 {
+		r.wavelengths = wl
+		return w.Cast(r, w.MaxDepth, smp)
+	})
+	if got.Subtract(want).Length() > 0.02 {
+		t.Errorf("Cast() in spectral mode = %v, want %v", got, want)
+	}
+}
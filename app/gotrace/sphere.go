@@ -27,7 +27,7 @@ type sphere struct {
 }
 
 // NewSphere returns a new sphere.
-func NewSphere(c Vector3, r float64, mat Material) Hittable {
+func NewSphere(c Vector3, r float64, mat Material) Solid {
 	return sphere{
 		Center:   c,
 		Radius:   r,
@@ -35,29 +35,83 @@ func NewSphere(c Vector3, r float64, mat Material) Hittable {
 	}
 }
 
-func (s sphere) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
-	oc := r.Origin.Subtract(s.Center)
+// sphereRoots returns where the line through r crosses a sphere, in
+// order along the ray, or false if it misses.
+func sphereRoots(r Ray, center Vector3, radius float64) (float64, float64, bool) {
+	oc := r.Origin.Subtract(center)
 	a := r.Direction.LengthSquared()
 	bHalf := oc.Dot(r.Direction)
-	c := oc.LengthSquared() - s.Radius*s.Radius
+	c := oc.LengthSquared() - radius*radius
 	discriminant := bHalf*bHalf - a*c
 
 	if discriminant < 0 {
-		return nil
+		return 0, 0, false
 	}
 	sqrtd := math.Sqrt(discriminant)
+	return (-bHalf - sqrtd) / a, (-bHalf + sqrtd) / a, true
+}
+
+// surface returns the hit at root, with the outward normal.
+func (s sphere) surface(r Ray, root float64) *HitRecord {
+	hitPoint := r.Point(root)
+	outwardNormal := hitPoint.Subtract(s.Center).DivideScalar(s.Radius)
+	hr := &HitRecord{T: root, P: hitPoint, Normal: outwardNormal, Material: s.Material}
+	hr.U, hr.V = sphereUV(outwardNormal)
+	hr.DPDU, hr.DPDV = sphereTangents(outwardNormal, s.Radius)
+	return hr
+}
 
-	root := (-bHalf - sqrtd) / a
+func (s sphere) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	r.countIntersectionTest()
+	t0, t1, ok := sphereRoots(r, s.Center, s.Radius)
+	if !ok {
+		return nil
+	}
+	root := t0
 	if root < tMin || root > tMax {
-		root = (-bHalf + sqrtd) / a
+		root = t1
 		if root < tMin || root > tMax {
 			return nil
 		}
 	}
 
-	hitPoint := r.Point(root)
-	outwardNormal := hitPoint.Subtract(s.Center).DivideScalar(s.Radius)
-	hr := &HitRecord{T: root, P: hitPoint, Material: s.Material}
-	hr.SetFaceNormal(r, outwardNormal)
+	hr := s.surface(r, root)
+	hr.SetFaceNormal(r, hr.Normal)
 	return hr
 }
+
+func (s sphere) Intervals(r Ray) []Interval {
+	r.countIntersectionTest()
+	t0, t1, ok := sphereRoots(r, s.Center, s.Radius)
+	if !ok {
+		return nil
+	}
+	return []Interval{{s.surface(r, t0), s.surface(r, t1)}}
+}
+
+// sphereUV returns the texture coordinates of a point on the unit
+// sphere.  U runs around the Y axis starting from -X, and V from the
+// bottom to the top.
+func sphereUV(p Vector3) (float64, float64) {
+	theta := math.Acos(clamp(-p.Y, -1, 1))
+	phi := math.Atan2(-p.Z, p.X) + math.Pi
+	return phi / (2 * math.Pi), theta / math.Pi
+}
+
+// sphereTangents returns how a point p on the unit sphere moves with
+// the texture coordinates from sphereUV, on a sphere of the given
+// radius.  At the poles, where U is undefined, both are zero.
+func sphereTangents(p Vector3, radius float64) (Vector3, Vector3) {
+	sinTheta := math.Hypot(p.X, p.Z)
+	if sinTheta < 1e-9 {
+		return Vector3{}, Vector3{}
+	}
+	dpdu := Vector3{p.Z, 0, -p.X}.MultiplyScalar(2 * math.Pi * radius)
+	dpdv := Vector3{-p.X * p.Y / sinTheta, sinTheta, -p.Y * p.Z / sinTheta}.MultiplyScalar(math.Pi * radius)
+	return dpdu, dpdv
+}
+
+func (s sphere) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	r := Vector3{s.Radius, s.Radius, s.Radius}
+	return aabb{s.Center.Subtract(r), s.Center.Add(r)}, true
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Convergence selects how the eyes of a StereoRig are aimed.
+type Convergence int
+
+// Supported convergence modes.
+const (
+	// ConvergeParallel points both eyes straight ahead, so everything
+	// appears in front of the screen.
+	ConvergeParallel Convergence = iota
+	// ConvergeToeIn turns both eyes towards the point of convergence.
+	// This is simple, but adds vertical parallax towards the edges.
+	ConvergeToeIn
+	// ConvergeOffAxis keeps the eyes parallel, and shifts each image
+	// window so they line up at the convergence distance.
+	ConvergeOffAxis
+)
+
+// StereoLayout selects how the two eyes are packed into one image.
+type StereoLayout int
+
+// Supported stereo layouts.
+const (
+	StereoSideBySide StereoLayout = iota
+	StereoOverUnder
+)
+
+// StereoRig places a pair of cameras for the left and right eyes.
+type StereoRig struct {
+	InterpupillaryDistance float64
+	Convergence            Convergence
+	ConvergenceDistance    float64
+}
+
+// stereoEye is implemented by panoramic cameras, which offset each ray
+// by the eye position rather than moving the whole camera.
+type stereoEye interface {
+	withEye(offset float64, convergenceDistance float64) Camera
+}
+
+// Eyes returns the left and right eye cameras for a camera which would
+// otherwise be placed at lookFrom, looking at lookAt.
+func (rig StereoRig) Eyes(maker CameraMaker, lookFrom Vector3, lookAt Vector3, vup Vector3, time0 float64, time1 float64) (Camera, Camera) {
+	left := rig.eye(maker, -rig.InterpupillaryDistance/2, lookFrom, lookAt, vup, time0, time1)
+	right := rig.eye(maker, rig.InterpupillaryDistance/2, lookFrom, lookAt, vup, time0, time1)
+	return left, right
+}
+
+func (rig StereoRig) eye(maker CameraMaker, offset float64, lookFrom Vector3, lookAt Vector3, vup Vector3, time0 float64, time1 float64) Camera {
+	center := maker(lookFrom, lookAt, vup, time0, time1)
+	if pano, ok := center.(stereoEye); ok {
+		convergenceDistance := 0.0
+		if rig.Convergence == ConvergeToeIn {
+			convergenceDistance = rig.ConvergenceDistance
+		}
+		return pano.withEye(offset, convergenceDistance)
+	}
+
+	basis := newCameraBasis(lookFrom, lookAt, vup, time0, time1)
+	shift := basis.u.MultiplyScalar(offset)
+	from := lookFrom.Add(shift)
+	at := lookAt.Add(shift)
+	if rig.Convergence == ConvergeToeIn {
+		at = lookFrom.Subtract(basis.w.MultiplyScalar(rig.ConvergenceDistance))
+	}
+	cam := maker(from, at, vup, time0, time1)
+
+	if pc, ok := cam.(PerspectiveCamera); ok && rig.Convergence == ConvergeOffAxis {
+		// Move the window back towards the center by the eye offset,
+		// as measured at the convergence distance.
+		pc.Shift(-offset/(rig.ConvergenceDistance*pc.ViewportWidth), 0)
+		return pc
+	}
+	return cam
+}
+
+// panoramaEye holds the eye position for omni-directional stereo, where
+// each ray starts on a circle around the camera, at right angles to
+// the direction it looks in.
+type panoramaEye struct {
+	EyeOffset           float64
+	ConvergenceDistance float64
+}
+
+// ray returns a ray in the given direction, starting from the eye.
+func (e panoramaEye) ray(c cameraBasis, smp Sampler, direction Vector3) Ray {
+	if e.EyeOffset == 0 {
+		return NewRay(c.origin, direction, c.time(smp))
+	}
+	// The offset is taken in the horizontal plane, so it fades out
+	// towards the poles instead of swirling around them.
+	right := direction.Cross(c.v).MultiplyScalar(1 / direction.Length())
+	origin := c.origin.Add(right.MultiplyScalar(e.EyeOffset))
+	if e.ConvergenceDistance > 0 {
+		target := c.origin.Add(direction.Normalize().MultiplyScalar(e.ConvergenceDistance))
+		direction = target.Subtract(origin)
+	}
+	return NewRay(origin, direction, c.time(smp))
+}
+
+// ComposeStereo packs the left and right eye images into one image.
+// Left is placed on the left or on top.
+func ComposeStereo(left image.Image, right image.Image, layout StereoLayout) *image.NRGBA {
+	lb := left.Bounds()
+	offset := image.Pt(lb.Dx(), 0)
+	if layout == StereoOverUnder {
+		offset = image.Pt(0, lb.Dy())
+	}
+	ret := image.NewNRGBA(image.Rectangle{Max: offset.Add(right.Bounds().Size())})
+	draw.Draw(ret, image.Rectangle{Max: lb.Size()}, left, lb.Min, draw.Src)
+	draw.Draw(ret, right.Bounds().Sub(right.Bounds().Min).Add(offset), right, right.Bounds().Min, draw.Src)
+	return ret
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func perspectiveMaker(from Vector3, at Vector3, up Vector3, time0 float64, time1 float64) Camera {
+	return NewCamera(from, at, up, 40, 2, 0, 5, time0, time1)
+}
+
+// centerCrossing returns where the ray through the center of the image
+// crosses the plane z = -depth.
+func centerCrossing(t *testing.T, c Camera, depth float64) Vector3 {
+	ok, r := c.GetRay(0.5, 0.5, NewIndependentSampler(1))
+	if !ok {
+		t.Fatal("expected a ray")
+	}
+	return r.Point((-depth - r.Origin.Z) / r.Direction.Z)
+}
+
+func TestStereoRig_Eyes(t *testing.T) {
+	from := Vector3{0, 0, 0}
+	at := Vector3{0, 0, -1}
+	up := Vector3{0, 1, 0}
+	tests := []struct {
+		name        string
+		convergence Convergence
+		converge    bool
+	}{
+		{"parallel", ConvergeParallel, false},
+		{"toe-in", ConvergeToeIn, true},
+		{"off-axis", ConvergeOffAxis, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rig := StereoRig{InterpupillaryDistance: 0.5, Convergence: tt.convergence, ConvergenceDistance: 8}
+			left, right := rig.Eyes(perspectiveMaker, from, at, up, 0, 0)
+			l := centerCrossing(t, left, 8)
+			r := centerCrossing(t, right, 8)
+			gap := r.Subtract(l).Length()
+			if tt.converge && gap > 1e-9 {
+				t.Errorf("eyes meet %v apart at the convergence distance", gap)
+			}
+			if !tt.converge && gap < 0.5-1e-9 {
+				t.Errorf("parallel eyes are only %v apart", gap)
+			}
+		})
+	}
+}
+
+func TestStereoRig_Panorama(t *testing.T) {
+	rig := StereoRig{InterpupillaryDistance: 0.5, Convergence: ConvergeParallel}
+	maker := func(from Vector3, at Vector3, up Vector3, time0 float64, time1 float64) Camera {
+		return NewEquirectangularCamera(from, at, up, time0, time1)
+	}
+	left, right := rig.Eyes(maker, Vector3{}, Vector3{0, 0, -1}, Vector3{0, 1, 0}, 0, 0)
+
+	// Looking right, the left eye is in front of the right eye.
+	_, l := left.GetRay(0.75, 0.5, NewIndependentSampler(1))
+	_, r := right.GetRay(0.75, 0.5, NewIndependentSampler(1))
+	if !nearVector(l.Origin, Vector3{0, 0, -0.25}) || !nearVector(r.Origin, Vector3{0, 0, 0.25}) {
+		t.Errorf("eye origins = %v, %v", l.Origin, r.Origin)
+	}
+}
+
+func TestComposeStereo(t *testing.T) {
+	left := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	right := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	if got := ComposeStereo(left, right, StereoSideBySide).Bounds(); got != image.Rect(0, 0, 8, 2) {
+		t.Errorf("side-by-side bounds = %v", got)
+	}
+	if got := ComposeStereo(left, right, StereoOverUnder).Bounds(); got != image.Rect(0, 0, 4, 4) {
+		t.Errorf("over-under bounds = %v", got)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Texture gives a value which varies over a surface, looked up by the
+// hit's U and V, or by its position.  Colors use all three channels;
+// textures driving a single number, such as roughness, use the first.
+type Texture interface {
+	Value(u float64, v float64, p Vector3) Vector3
+}
+
+// SolidColor is a texture which is the same everywhere.
+type SolidColor struct {
+	color Vector3
+}
+
+// NewSolidColor returns a texture of one color.
+func NewSolidColor(color Vector3) SolidColor {
+	return SolidColor{color: color}
+}
+
+// NewSolidValue returns a texture of one number, in every channel.
+func NewSolidValue(v float64) SolidColor {
+	return SolidColor{color: Vector3{v, v, v}}
+}
+
+// Value returns the color.
+func (t SolidColor) Value(u float64, v float64, p Vector3) Vector3 {
+	return t.color
+}
+
+// ImageTexture wraps an image over a surface, repeating it outside U
+// and V of 0 to 1, and blending between pixels.
+type ImageTexture struct {
+	width  int
+	height int
+	pixels []Vector3
+
+	// path and linear are the file the texture was loaded from, if
+	// any, and how, so that it can be written back to a scene.
+	path   string
+	linear bool
+}
+
+// NewImageTexture returns a texture of the image.  Color images are
+// taken as gamma 2 encoded, the same as the images written, and
+// decoded.  Images holding data, such as roughness or normal maps,
+// should be linear.
+func NewImageTexture(im image.Image, linear bool) *ImageTexture {
+	b := im.Bounds()
+	t := &ImageTexture{width: b.Dx(), height: b.Dy(), pixels: make([]Vector3, 0, b.Dx()*b.Dy()), linear: linear}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, _ := im.At(x, y).RGBA()
+			c := Vector3{float64(r), float64(g), float64(b)}.DivideScalar(0xffff)
+			if !linear {
+				c = c.Multiply(c)
+			}
+			t.pixels = append(t.pixels, c)
+		}
+	}
+	return t
+}
+
+// NewAlphaTexture returns a texture of the image's opacity, in every
+// channel, for cutting out shapes such as leaves.
+func NewAlphaTexture(im image.Image) *ImageTexture {
+	b := im.Bounds()
+	t := &ImageTexture{width: b.Dx(), height: b.Dy(), pixels: make([]Vector3, 0, b.Dx()*b.Dy())}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := im.At(x, y).RGBA()
+			v := float64(a) / 0xffff
+			t.pixels = append(t.pixels, Vector3{v, v, v})
+		}
+	}
+	return t
+}
+
+// LoadImageTexture reads an image file as a texture.
+func LoadImageTexture(filename string, linear bool) (*ImageTexture, error) {
+	im, err := decodeImageFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	t := NewImageTexture(im, linear)
+	t.path = filename
+	return t, nil
+}
+
+// LoadAlphaTexture reads the opacity of an image file as a texture.
+func LoadAlphaTexture(filename string) (*ImageTexture, error) {
+	im, err := decodeImageFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewAlphaTexture(im), nil
+}
+
+func decodeImageFile(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	im, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	if im.Bounds().Empty() {
+		return nil, fmt.Errorf("%s: empty image", filename)
+	}
+	return im, nil
+}
+
+// Value returns the color at u, v, with V running up the image.  An
+// empty image is black.
+func (t *ImageTexture) Value(u float64, v float64, p Vector3) Vector3 {
+	if len(t.pixels) == 0 {
+		return Vector3{}
+	}
+	x := (u-math.Floor(u))*float64(t.width) - 0.5
+	y := (1-(v-math.Floor(v)))*float64(t.height) - 0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	at := func(x int, y int) Vector3 {
+		x = ((x % t.width) + t.width) % t.width
+		y = ((y % t.height) + t.height) % t.height
+		return t.pixels[y*t.width+x]
+	}
+	ix, iy := int(x0), int(y0)
+	return at(ix, iy).Lerp(at(ix+1, iy), fx).Lerp(at(ix, iy+1).Lerp(at(ix+1, iy+1), fx), fy)
+}
+
+// ChannelTexture is one channel of another texture, such as the
+// roughness packed into the green of a glTF metallic-roughness map.
+type ChannelTexture struct {
+	texture Texture
+	channel int
+}
+
+// NewChannelTexture returns channel 0, 1 or 2 of t, in every channel.
+func NewChannelTexture(t Texture, channel int) ChannelTexture {
+	return ChannelTexture{texture: t, channel: channel}
+}
+
+// Value returns the channel's value.
+func (t ChannelTexture) Value(u float64, v float64, p Vector3) Vector3 {
+	c := axis(t.texture.Value(u, v, p), t.channel)
+	return Vector3{c, c, c}
+}
+
+// ScaledTexture is another texture, multiplied by a color, as when a
+// material file gives both a factor and a map.
+type ScaledTexture struct {
+	texture Texture
+	scale   Vector3
+}
+
+// NewScaledTexture returns t multiplied by scale.
+func NewScaledTexture(t Texture, scale Vector3) ScaledTexture {
+	return ScaledTexture{texture: t, scale: scale}
+}
+
+// Value returns the scaled value.
+func (t ScaledTexture) Value(u float64, v float64, p Vector3) Vector3 {
+	return t.texture.Value(u, v, p).Multiply(t.scale)
+}
+
+// textureParts is a texture as material files describe one: a color or
+// value, which an image, or one channel of it, is multiplied by.
+type textureParts struct {
+	factor  Vector3
+	image   *ImageTexture
+	channel int
+}
+
+// partsOf takes a texture apart.  Only solid colors and image files,
+// possibly scaled or with one channel picked, can be.
+func partsOf(t Texture) (textureParts, error) {
+	switch t := t.(type) {
+	case SolidColor:
+		return textureParts{factor: t.color, channel: -1}, nil
+	case *ImageTexture:
+		if t.path == "" {
+			return textureParts{}, fmt.Errorf("image texture was not loaded from a file")
+		}
+		return textureParts{factor: Vector3{1, 1, 1}, image: t, channel: -1}, nil
+	case ChannelTexture:
+		parts, err := partsOf(t.texture)
+		if err != nil || parts.image == nil || parts.channel >= 0 || parts.factor != (Vector3{1, 1, 1}) {
+			return textureParts{}, fmt.Errorf("channel of %T cannot be written", t.texture)
+		}
+		parts.channel = t.channel
+		return parts, nil
+	case ScaledTexture:
+		parts, err := partsOf(t.texture)
+		if err != nil {
+			return textureParts{}, err
+		}
+		parts.factor = parts.factor.Multiply(t.scale)
+		return parts, nil
+	}
+	return textureParts{}, fmt.Errorf("%T cannot be written", t)
+}
+
+// texture puts the parts back together.
+func (p textureParts) texture() Texture {
+	if p.image == nil {
+		return NewSolidColor(p.factor)
+	}
+	var t Texture = p.image
+	if p.channel >= 0 {
+		t = NewChannelTexture(t, p.channel)
+	}
+	if p.factor != (Vector3{1, 1, 1}) {
+		t = NewScaledTexture(t, p.factor)
+	}
+	return t
+}
+
+// textureFiles loads the image textures a scene or material file names,
+// relative to the file's directory, loading each only once.
+type textureFiles struct {
+	dir    string
+	loaded map[textureFile]*ImageTexture
+}
+
+type textureFile struct {
+	path   string
+	linear bool
+}
+
+func newTextureFiles(dir string) *textureFiles {
+	return &textureFiles{dir: dir, loaded: map[textureFile]*ImageTexture{}}
+}
+
+// load returns the texture of the image at path, which keeps the path
+// as given so that it is written back the same way.
+func (f *textureFiles) load(path string, linear bool) (*ImageTexture, error) {
+	key := textureFile{path, linear}
+	if t, ok := f.loaded[key]; ok {
+		return t, nil
+	}
+	filename := path
+	if !filepath.IsAbs(path) {
+		filename = filepath.Join(f.dir, path)
+	}
+	t, err := LoadImageTexture(filename, linear)
+	if err != nil {
+		return nil, err
+	}
+	t.path = path
+	f.loaded[key] = t
+	return t, nil
+}
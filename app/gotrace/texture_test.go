@@ -0,0 +1,59 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageTexture_Value(t *testing.T) {
+	im := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	im.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	im.Set(1, 0, color.NRGBA{0, 255, 0, 255})
+	im.Set(0, 1, color.NRGBA{0, 0, 255, 255})
+	im.Set(1, 1, color.NRGBA{128, 128, 128, 255})
+	grey := 128.0 * 257 / 0xffff
+
+	tests := []struct {
+		name   string
+		linear bool
+		u, v   float64
+		want   Vector3
+	}{
+		{"top left", true, 0.25, 0.75, Vector3{1, 0, 0}},
+		{"bottom right", true, 0.75, 0.25, Vector3{grey, grey, grey}},
+		{"decoded", false, 0.75, 0.25, Vector3{grey * grey, grey * grey, grey * grey}},
+		{"repeats", true, 1.25, -0.25, Vector3{1, 0, 0}},
+		{"blends", true, 0.5, 0.75, Vector3{0.5, 0.5, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewImageTexture(im, tt.linear).Value(tt.u, tt.v, Vector3{})
+			if got.Subtract(tt.want).Length() > 1e-9 {
+				t.Errorf("Value(%v, %v) = %v, want %v", tt.u, tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageTexture_Empty(t *testing.T) {
+	if got := NewImageTexture(image.NewNRGBA(image.Rect(0, 0, 0, 0)), true).Value(0.5, 0.5, Vector3{}); got != (Vector3{}) {
+		t.Errorf("Value() of an empty image = %v, want black", got)
+	}
+}
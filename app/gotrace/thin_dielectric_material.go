@@ -0,0 +1,61 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+// ThinDielectricMaterial is a sheet of glass too thin to model both
+// sides of, such as a window made of a single quad.  Light reflects off
+// it by the Fresnel equations, counting the reflections back and forth
+// between the two faces, and what is not reflected passes straight
+// through, since the two faces bend it back again.
+type ThinDielectricMaterial struct {
+	ior float64
+}
+
+// NewThinDielectricMaterial returns a thin sheet with the given index
+// of refraction.
+func NewThinDielectricMaterial(indexOfRefraction float64) ThinDielectricMaterial {
+	return ThinDielectricMaterial{ior: indexOfRefraction}
+}
+
+// reflectance returns how much light the sheet reflects in all, at the
+// given cosine of the angle of incidence.
+func (m ThinDielectricMaterial) reflectance(cosTheta float64) float64 {
+	r := fresnelDielectric(cosTheta, m.ior)
+	if r >= 1 {
+		return 1
+	}
+	// Of the light entering, R of what reaches each face bounces back,
+	// so R + T²R + T²R³ + ... comes out the front.
+	return 2 * r / (1 + r)
+}
+
+// Scatter reflects or passes straight through.
+func (m ThinDielectricMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	unitDirection := r.Direction.Normalize()
+	cosTheta := math.Min(unitDirection.Neg().Dot(hr.Normal), 1.0)
+	if m.reflectance(cosTheta) > smp.Get1D() {
+		return true, r.Spawn(hr.P, reflectRay(unitDirection, hr.Normal)), Vector3{1, 1, 1}
+	}
+	return true, r.Spawn(hr.P, r.Direction), Vector3{1, 1, 1}
+}
+
+// Albedo returns white, since the sheet itself absorbs nothing.
+func (m ThinDielectricMaterial) Albedo(hr *HitRecord) Vector3 {
+	return Vector3{1, 1, 1}
+}
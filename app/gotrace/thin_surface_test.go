@@ -0,0 +1,141 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestThinDielectricMaterial_Scatter(t *testing.T) {
+	hr := &HitRecord{Normal: Vector3{0, 0, 1}, FrontFace: true}
+	in := NewRay(Vector3{0, 0, 1}, Vector3{0, 0, -2}, 0)
+	m := NewThinDielectricMaterial(1.5)
+	smp := NewIndependentSampler(1)
+	const n = 100000
+	reflected := 0
+	for i := 0; i < n; i++ {
+		ok, out, attenuation := m.Scatter(in, hr, smp)
+		if !ok || attenuation != (Vector3{1, 1, 1}) {
+			t.Fatalf("Scatter() = %v, %v", ok, attenuation)
+		}
+		switch out.Direction.Normalize() {
+		case Vector3{0, 0, 1}:
+			reflected++
+		case Vector3{0, 0, -1}:
+		default:
+			t.Fatalf("Scatter() = %v, want straight back or through", out.Direction)
+		}
+	}
+	// A single face reflects 4%, and the two together almost twice that.
+	if got, want := float64(reflected)/n, 0.08/1.04; math.Abs(got-want) > 0.005 {
+		t.Errorf("Scatter() reflected %v, want %v", got, want)
+	}
+}
+
+func TestTranslucentMaterial_Scatter(t *testing.T) {
+	hr := &HitRecord{Normal: Vector3{0, 1, 0}, FrontFace: true}
+	in := NewRay(Vector3{-1, 1, 0}, Vector3{1, -1, 0}, 0)
+	m := NewTranslucentMaterial(Vector3{0.2, 0.3, 0.1}, Vector3{0.3, 0.6, 0.1})
+	smp := NewIndependentSampler(1)
+	const n = 20000
+	var front, back Vector3
+	for i := 0; i < n; i++ {
+		_, out, attenuation := m.Scatter(in, hr, smp)
+		if out.Direction.Dot(hr.Normal) > 0 {
+			front = front.Add(attenuation)
+		} else {
+			back = back.Add(attenuation)
+		}
+	}
+	if got, want := front.DivideScalar(n), (Vector3{0.2, 0.3, 0.1}); got.Subtract(want).Length() > 0.02 {
+		t.Errorf("Scatter() reflects %v, want %v", got, want)
+	}
+	if got, want := back.DivideScalar(n), (Vector3{0.3, 0.6, 0.1}); got.Subtract(want).Length() > 0.02 {
+		t.Errorf("Scatter() passes %v, want %v", got, want)
+	}
+}
+
+func TestOneSidedMaterial(t *testing.T) {
+	behind := NewSphere(Vector3{0, 0, 5}, 1, NewLambertianMaterial(Vector3{1, 1, 1}))
+	// The quad's front faces +Z, toward the sphere.
+	quad := NewBVH([]Hittable{
+		NewTriangle(Vector3{-1, -1, 0}, Vector3{1, -1, 0}, Vector3{1, 1, 0}, NewOneSidedMaterial(NewLambertianMaterial(Vector3{}))),
+		NewTriangle(Vector3{-1, -1, 0}, Vector3{1, 1, 0}, Vector3{-1, 1, 0}, NewOneSidedMaterial(NewLambertianMaterial(Vector3{}))),
+	}, 0, 1)
+	w := World{Objects: []Hittable{quad, behind}, MaxDepth: 10, TMin: 0.001, TMax: math.Inf(1)}
+
+	if hr := w.Hit(NewRay(Vector3{0.5, 0.2, 2}, Vector3{0, 0, -1}, 0)); hr == nil || hr.ObjectID != 1 {
+		t.Errorf("Hit() from the front = %v, want the quad", hr)
+	}
+	if hr := w.Hit(NewRay(Vector3{0.5, 0.2, -10}, Vector3{0, 0, 1}, 0)); hr == nil || hr.ObjectID != 2 {
+		t.Errorf("Hit() from behind = %v, want the sphere", hr)
+	}
+	w.Objects = []Hittable{quad}
+	if hr := w.Hit(NewRay(Vector3{0.5, 0.2, -10}, Vector3{0, 0, 1}, 0)); hr != nil {
+		t.Errorf("Hit() of the back = %v, want nil", hr)
+	}
+}
+
+func TestOneSided_Wrapped(t *testing.T) {
+	paint := NewOneSidedMaterial(NewLambertianMaterial(Vector3{1, 0, 0}))
+	glass := NewOneSidedMaterial(NewMediumDielectricMaterial(Medium{IOR: 1.5, Priority: 1}))
+	tests := []struct {
+		name string
+		mat  Material
+		want bool
+	}{
+		{"two-sided", NewLambertianMaterial(Vector3{}), false},
+		{"one-sided", paint, true},
+		{"glass", glass, true},
+		{"bumped", NewBumpMaterial(paint, NewSolidValue(0), 1), true},
+		{"normal mapped", NewNormalMapMaterial(paint, NewSolidColor(Vector3{0.5, 0.5, 1}), 1), true},
+		{"coated", NewCoatedMaterial(paint, 1.5, 0, Vector3{1, 1, 1}), true},
+		{"mixed", NewMixMaterial(NewLambertianMaterial(Vector3{}), paint, NewSolidValue(0.5)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := culled(&HitRecord{Material: tt.mat}); got != tt.want {
+				t.Errorf("culled() of the back = %v, want %v", got, tt.want)
+			}
+			if culled(&HitRecord{Material: tt.mat, FrontFace: true}) {
+				t.Error("culled() of the front = true")
+			}
+		})
+	}
+	if mm, ok := glass.(MediumMaterial); !ok || mm.Interior().IOR != 1.5 {
+		t.Errorf("one-sided glass = %v, want its medium kept", glass)
+	}
+}
+
+// stuckObject reports the same hit on its back, however far along the
+// ray it is asked to look.
+type stuckObject struct{}
+
+func (stuckObject) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return &HitRecord{T: 1, Material: NewOneSidedMaterial(NewLambertianMaterial(Vector3{}))}
+}
+
+func (stuckObject) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return aabb{}, false
+}
+
+func TestVisibleHit_Bounded(t *testing.T) {
+	if hr := visibleHit(stuckObject{}, NewRay(Vector3{}, Vector3{0, 0, 1}, 0), 0, math.Inf(1)); hr != nil {
+		t.Errorf("visibleHit() = %v, want nil", hr)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type torus struct {
+	Center      Vector3
+	MajorRadius float64
+	MinorRadius float64
+	PhiMax      float64
+	Material    Material
+}
+
+// NewTorus returns a ring around the Y axis, with its tube's center
+// majorRadius from the axis, swept phiMax degrees around from +X
+// towards +Z.  A full torus is closed, and so is a Solid.
+func NewTorus(center Vector3, majorRadius float64, minorRadius float64, phiMax float64, mat Material) Hittable {
+	t := torus{center, majorRadius, minorRadius, sweepRadians(phiMax), mat}
+	if t.PhiMax < 2*math.Pi {
+		return t
+	}
+	box, _ := t.BoundingBox(0, 0)
+	return closedSurface{Parts: []crosser{t}, Box: box}
+}
+
+// crossings solves the torus's quartic.  To keep the coefficients
+// well scaled, the ray is first normalized and moved up to where it
+// meets the torus's bounding sphere.
+func (s torus) crossings(r Ray) []*HitRecord {
+	r.countIntersectionTest()
+	length := r.Direction.Length()
+	d := r.Direction.DivideScalar(length)
+	o := r.Origin.Subtract(s.Center)
+	bound := s.MajorRadius + s.MinorRadius
+	t0, _, ok := sphereRoots(Ray{Origin: o, Direction: d}, Vector3{}, bound)
+	if !ok {
+		return nil
+	}
+	o = o.Add(d.MultiplyScalar(t0))
+
+	R2 := s.MajorRadius * s.MajorRadius
+	r2 := s.MinorRadius * s.MinorRadius
+	od := o.Dot(d)
+	e := o.Dot(o) - R2 - r2
+	roots := solveQuartic(
+		1,
+		4*od,
+		2*e+4*od*od+4*R2*d.Y*d.Y,
+		4*od*e+8*R2*o.Y*d.Y,
+		e*e-4*R2*(r2-o.Y*o.Y),
+	)
+
+	var ret []*HitRecord
+	for _, t := range roots {
+		p := o.Add(d.MultiplyScalar(t))
+		phi := sweepAngle(p.X, p.Z)
+		if phi > s.PhiMax {
+			continue
+		}
+		// The normal points away from the center of the tube.
+		rho := math.Hypot(p.X, p.Z)
+		tube := Vector3{p.X / rho * s.MajorRadius, 0, p.Z / rho * s.MajorRadius}
+		normal := p.Subtract(tube).DivideScalar(s.MinorRadius)
+		theta := math.Atan2(p.Y, rho-s.MajorRadius)
+		if theta < 0 {
+			theta += 2 * math.Pi
+		}
+		ret = append(ret, &HitRecord{
+			T:        (t + t0) / length,
+			P:        p.Add(s.Center),
+			Normal:   normal,
+			U:        phi / s.PhiMax,
+			V:        theta / (2 * math.Pi),
+			DPDU:     Vector3{-p.Z, 0, p.X}.MultiplyScalar(s.PhiMax),
+			DPDV:     Vector3{-p.Y * p.X / rho, rho - s.MajorRadius, -p.Y * p.Z / rho}.MultiplyScalar(2 * math.Pi),
+			Material: s.Material,
+		})
+	}
+	return ret
+}
+
+func (s torus) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	return firstCrossing(r, s.crossings(r), tMin, tMax)
+}
+
+func (s torus) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	extent := Vector3{s.MajorRadius + s.MinorRadius, s.MinorRadius, s.MajorRadius + s.MinorRadius}
+	return aabb{s.Center.Subtract(extent), s.Center.Add(extent)}, true
+}
@@ -0,0 +1,295 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Transform is an affine transform which scales, then rotates, then
+// translates.  The inverse is kept alongside so rays can be moved into
+// object space cheaply.
+type Transform struct {
+	m   [3][3]float64
+	inv [3][3]float64
+	t   Vector3
+}
+
+// IdentityTransform returns a transform which changes nothing.
+func IdentityTransform() Transform {
+	return newTransform(Vector3{}, Vector3{}, Vector3{1, 1, 1})
+}
+
+// NewTransform returns a transform from a translation, a rotation given
+// as Euler angles in degrees (applied about X, then Y, then Z), and a
+// per-axis scale.  A scale of zero along any axis cannot be undone, so
+// is an error.
+func NewTransform(translate Vector3, rotate Vector3, scale Vector3) (Transform, error) {
+	if scale.X == 0 || scale.Y == 0 || scale.Z == 0 {
+		return Transform{}, fmt.Errorf("transform scale %v must not be zero along any axis", scale)
+	}
+	return newTransform(translate, rotate, scale), nil
+}
+
+func newTransform(translate Vector3, rotate Vector3, scale Vector3) Transform {
+	sx, cx := math.Sincos(rotate.X * math.Pi / 180)
+	sy, cy := math.Sincos(rotate.Y * math.Pi / 180)
+	sz, cz := math.Sincos(rotate.Z * math.Pi / 180)
+
+	// R = Rz * Ry * Rx
+	r := [3][3]float64{
+		{cy * cz, sx*sy*cz - cx*sz, cx*sy*cz + sx*sz},
+		{cy * sz, sx*sy*sz + cx*cz, cx*sy*sz - sx*cz},
+		{-sy, sx * cy, cx * cy},
+	}
+	s := [3]float64{scale.X, scale.Y, scale.Z}
+
+	ret := Transform{t: translate}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			// M = R * S, and M^-1 = S^-1 * R^T
+			ret.m[i][j] = r[i][j] * s[j]
+			ret.inv[i][j] = r[j][i] / s[i]
+		}
+	}
+	return ret
+}
+
+func mul3(m *[3][3]float64, v Vector3) Vector3 {
+	return Vector3{
+		X: m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		Y: m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		Z: m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+// Point transforms a point from object space to world space.
+func (t Transform) Point(p Vector3) Vector3 {
+	return mul3(&t.m, p).Add(t.t)
+}
+
+// Vector transforms a direction from object space to world space.
+func (t Transform) Vector(v Vector3) Vector3 {
+	return mul3(&t.m, v)
+}
+
+// InversePoint transforms a point from world space to object space.
+func (t Transform) InversePoint(p Vector3) Vector3 {
+	return mul3(&t.inv, p.Subtract(t.t))
+}
+
+// InverseVector transforms a direction from world space to object space.
+func (t Transform) InverseVector(v Vector3) Vector3 {
+	return mul3(&t.inv, v)
+}
+
+// Normal transforms an object space normal to world space.  The result
+// is not normalized.
+func (t Transform) Normal(n Vector3) Vector3 {
+	// multiply by the transpose of the inverse.
+	return Vector3{
+		X: t.inv[0][0]*n.X + t.inv[1][0]*n.Y + t.inv[2][0]*n.Z,
+		Y: t.inv[0][1]*n.X + t.inv[1][1]*n.Y + t.inv[2][1]*n.Z,
+		Z: t.inv[0][2]*n.X + t.inv[1][2]*n.Y + t.inv[2][2]*n.Z,
+	}
+}
+
+// Box returns the world space box which encloses an object space box.
+func (t Transform) Box(b aabb) aabb {
+	ret := aabb{
+		minimum: Vector3{math.Inf(1), math.Inf(1), math.Inf(1)},
+		maximum: Vector3{math.Inf(-1), math.Inf(-1), math.Inf(-1)},
+	}
+	for _, c := range b.corners() {
+		p := t.Point(c)
+		ret.minimum = minVector(ret.minimum, p)
+		ret.maximum = maxVector(ret.maximum, p)
+	}
+	return ret
+}
+
+// Motion is a keyframed Transform.  An empty Scale track means
+// the object is not scaled.
+type Motion struct {
+	Translate Track
+	Rotate    Track
+	Scale     Track
+}
+
+// minScale is the smallest scale along any axis Motion.At gives, as a
+// scale track may pass through zero, such as a coin flipping over.
+const minScale = 1e-9
+
+// At returns the transform at the given time.  A scale closer to zero
+// than minScale is taken as minScale, so the object flattens rather
+// than vanishing in a division by zero.
+func (m Motion) At(time float64) Transform {
+	scale := Vector3{1, 1, 1}
+	if len(m.Scale.Keyframes) > 0 {
+		scale = m.Scale.At(time)
+	}
+	guard := func(s float64) float64 {
+		if math.Abs(s) < minScale {
+			return math.Copysign(minScale, s)
+		}
+		return s
+	}
+	scale = Vector3{guard(scale.X), guard(scale.Y), guard(scale.Z)}
+	return newTransform(m.Translate.At(time), m.Rotate.At(time), scale)
+}
+
+// boundsSteps is how many times each interval between keyframes is
+// sampled when computing a swept bounding box.
+const boundsSteps = 16
+
+// sampleTimes returns time0, time1, every keyframe time between them,
+// and evenly spaced times between each of those.
+func (m Motion) sampleTimes(time0 float64, time1 float64) []float64 {
+	stops := []float64{time0}
+	for _, tr := range []Track{m.Translate, m.Rotate, m.Scale} {
+		for _, k := range tr.Keyframes {
+			if k.Time > time0 && k.Time < time1 {
+				stops = append(stops, k.Time)
+			}
+		}
+	}
+	stops = append(stops, time1)
+	sort.Float64s(stops)
+
+	times := []float64{}
+	for i := 0; i < len(stops)-1; i++ {
+		for step := 0; step < boundsSteps; step++ {
+			times = append(times, stops[i]+(stops[i+1]-stops[i])*float64(step)/boundsSteps)
+		}
+	}
+	return append(times, time1)
+}
+
+type transformedObject struct {
+	Object Hittable
+	Motion Motion
+}
+
+// transformedSolid is a transformed object which is a Solid, so it
+// stays one.
+type transformedSolid struct {
+	transformedObject
+}
+
+// NewTransformedObject returns a Hittable which moves, rotates, and
+// scales obj according to the transform at the time of each ray, so
+// any object can be motion blurred.  If obj is a Solid, so is the
+// transformed object.
+func NewTransformedObject(obj Hittable, motion Motion) Hittable {
+	o := transformedObject{
+		Object: obj,
+		Motion: motion,
+	}
+	if _, ok := obj.(Solid); ok {
+		return transformedSolid{o}
+	}
+	return o
+}
+
+// NewTransformedSolid is NewTransformedObject for solids.
+func NewTransformedSolid(obj Solid, motion Motion) Solid {
+	return NewTransformedObject(obj, motion).(Solid)
+}
+
+// NewAnimatedObject returns a Hittable which moves obj by the offset
+// given by the position track at the time of each ray.
+func NewAnimatedObject(obj Hittable, position Track) Hittable {
+	return NewTransformedObject(obj, Motion{Translate: position})
+}
+
+func (o transformedObject) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	xf := o.Motion.At(r.Time)
+	local := r.Spawn(xf.InversePoint(r.Origin), xf.InverseVector(r.Direction))
+	hr := o.Object.Hit(local, tMin, tMax)
+	if hr == nil {
+		return nil
+	}
+	o.toWorld(xf, hr, r.Time)
+	return hr
+}
+
+// toWorld moves a hit from object space to world space.  An affine
+// transform keeps the ray parameter and the sign of the normal against
+// the ray, so T and FrontFace stay valid.
+func (o transformedObject) toWorld(xf Transform, hr *HitRecord, time float64) {
+	hr.Velocity = o.velocity(xf, hr.P, hr.Velocity, time)
+	hr.P = xf.Point(hr.P)
+	hr.Normal = xf.Normal(hr.Normal).Normalize()
+	hr.DPDU = xf.Vector(hr.DPDU)
+	hr.DPDV = xf.Vector(hr.DPDV)
+}
+
+func (o transformedSolid) Intervals(r Ray) []Interval {
+	xf := o.Motion.At(r.Time)
+	local := r.Spawn(xf.InversePoint(r.Origin), xf.InverseVector(r.Direction))
+	intervals := o.Object.(Solid).Intervals(local)
+	for _, iv := range intervals {
+		o.toWorld(xf, iv.Enter, r.Time)
+		o.toWorld(xf, iv.Exit, r.Time)
+	}
+	return intervals
+}
+
+// velocityStep is the time step used to find how fast a transform
+// is changing.
+const velocityStep = 1e-4
+
+// velocity returns the world space velocity of the object space point p,
+// which is itself moving within the object at localVelocity.
+func (o transformedObject) velocity(xf Transform, p Vector3, localVelocity Vector3, time float64) Vector3 {
+	before := o.Motion.At(time - velocityStep).Point(p)
+	after := o.Motion.At(time + velocityStep).Point(p)
+	return after.Subtract(before).DivideScalar(2 * velocityStep).Add(xf.Vector(localVelocity))
+}
+
+func (o transformedObject) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	childBox, ok := o.Object.BoundingBox(time0, time1)
+	if !ok {
+		return aabb{}, false
+	}
+
+	var ret aabb
+	var prevCorners [8]Vector3
+	pad := 0.0
+	for i, t := range o.Motion.sampleTimes(time0, time1) {
+		xf := o.Motion.At(t)
+		box := xf.Box(childBox)
+		if i == 0 {
+			ret = box
+		} else {
+			ret = surroundingBox(ret, box)
+		}
+		// Between two samples a corner can wander off the line joining
+		// them, such as along the arc of a rotation.  Pad by half the
+		// largest step so the swept box stays conservative.
+		for j, c := range childBox.corners() {
+			p := xf.Point(c)
+			if i > 0 {
+				pad = math.Max(pad, p.Subtract(prevCorners[j]).Length()/2)
+			}
+			prevCorners[j] = p
+		}
+	}
+	return ret.expand(pad), true
+}
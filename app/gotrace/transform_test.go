@@ -0,0 +1,125 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func nearVector(a Vector3, b Vector3) bool {
+	return a.Subtract(b).Length() < 1e-9
+}
+
+func TestTransform_Inverse(t *testing.T) {
+	xf, err := NewTransform(Vector3{1, 2, 3}, Vector3{30, 45, 60}, Vector3{2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Vector3{0.5, -1, 2}
+	if got := xf.InversePoint(xf.Point(p)); !nearVector(got, p) {
+		t.Errorf("InversePoint(Point(p)) = %v, want %v", got, p)
+	}
+	if got := xf.InverseVector(xf.Vector(p)); !nearVector(got, p) {
+		t.Errorf("InverseVector(Vector(p)) = %v, want %v", got, p)
+	}
+}
+
+func TestTransform_Rotate(t *testing.T) {
+	xf, err := NewTransform(Vector3{}, Vector3{0, 0, 90}, Vector3{1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := xf.Vector(Vector3{1, 0, 0}); !nearVector(got, Vector3{0, 1, 0}) {
+		t.Errorf("Vector() = %v, want %v", got, Vector3{0, 1, 0})
+	}
+}
+
+func TestTransform_ZeroScale(t *testing.T) {
+	for _, scale := range []Vector3{{0, 1, 1}, {1, 0, 1}, {1, 1, 0}} {
+		if _, err := NewTransform(Vector3{}, Vector3{}, scale); err == nil {
+			t.Errorf("NewTransform() with scale %v succeeded", scale)
+		}
+	}
+}
+
+func TestMotion_AtZeroScale(t *testing.T) {
+	// A coin flipping over, with no thickness half way.
+	m := Motion{Scale: NewTrack(InterpolateLinear,
+		Keyframe{0, Vector3{1, 1, 1}},
+		Keyframe{1, Vector3{1, -1, 1}},
+	)}
+	xf := m.At(0.5)
+	p := xf.InversePoint(Vector3{1, 0, 1})
+	if math.IsNaN(p.X) || math.IsInf(p.X, 0) || math.IsNaN(p.Y) || math.IsNaN(p.Z) {
+		t.Errorf("InversePoint() at zero scale = %v", p)
+	}
+	if got := xf.Point(Vector3{0, 1, 0}); got.Length() > 1e-6 {
+		t.Errorf("Point() at zero scale = %v, want flattened", got)
+	}
+}
+
+func TestTransformedObject_Hit(t *testing.T) {
+	// A unit sphere stretched to radius 2 along X, moving along Z.
+	obj := NewTransformedObject(NewSphere(Vector3{}, 1, nil), Motion{
+		Translate: NewTrack(InterpolateLinear,
+			Keyframe{0, Vector3{0, 0, 0}},
+			Keyframe{1, Vector3{0, 0, 10}},
+			Keyframe{2, Vector3{0, 0, 0}},
+		),
+		Scale: StaticTrack(Vector3{2, 1, 1}),
+	})
+
+	r := Ray{Origin: Vector3{-10, 0, 5}, Direction: Vector3{1, 0, 0}, Time: 0.5}
+	hr := obj.Hit(r, 0.001, math.MaxFloat64)
+	if hr == nil {
+		t.Fatal("expected a hit")
+	}
+	if !nearVector(hr.P, Vector3{-2, 0, 5}) {
+		t.Errorf("P = %v, want %v", hr.P, Vector3{-2, 0, 5})
+	}
+	if !nearVector(hr.Normal, Vector3{-1, 0, 0}) || !hr.FrontFace {
+		t.Errorf("Normal = %v, FrontFace = %v", hr.Normal, hr.FrontFace)
+	}
+
+	r.Time = 0
+	if hr := obj.Hit(r, 0.001, math.MaxFloat64); hr != nil {
+		t.Errorf("expected a miss at time 0, got %v", hr.P)
+	}
+}
+
+func TestTransformedObject_BoundingBox(t *testing.T) {
+	// A sphere orbiting the Y axis at a distance of 5.
+	obj := NewTransformedObject(NewSphere(Vector3{5, 0, 0}, 1, nil), Motion{
+		Rotate: NewTrack(InterpolateLinear,
+			Keyframe{0, Vector3{0, 0, 0}},
+			Keyframe{1, Vector3{0, 360, 0}},
+		),
+	})
+	box, ok := obj.BoundingBox(0, 1)
+	if !ok {
+		t.Fatal("expected a bounding box")
+	}
+	for i := 0; i <= 1000; i++ {
+		time := float64(i) / 1000
+		c := newTransform(Vector3{}, Vector3{0, 360 * time, 0}, Vector3{1, 1, 1}).Point(Vector3{5, 0, 0})
+		if c.X+1 > box.maximum.X || c.X-1 < box.minimum.X ||
+			c.Z+1 > box.maximum.Z || c.Z-1 < box.minimum.Z {
+			t.Fatalf("sphere at time %v (center %v) escapes box %v", time, c, box)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// TranslucentMaterial is a thin matt surface which lets some light
+// through, scattered in all directions, such as a leaf, paper or a
+// lampshade.  The light coming through is lit from behind, and so
+// takes its own color.
+type TranslucentMaterial struct {
+	reflectance   Vector3
+	transmittance Vector3
+}
+
+// NewTranslucentMaterial returns a surface which reflects the first
+// color and passes the second.  To be plausible, the two should add up
+// to no more than one.
+func NewTranslucentMaterial(reflectance Vector3, transmittance Vector3) TranslucentMaterial {
+	return TranslucentMaterial{reflectance: reflectance, transmittance: transmittance}
+}
+
+// Scatter scatters the ray to the side it came from or through to the
+// other, in proportion to how bright each is.
+func (m TranslucentMaterial) Scatter(r Ray, hr *HitRecord, smp Sampler) (bool, Ray, Vector3) {
+	reflected, transmitted := luminance(m.reflectance), luminance(m.transmittance)
+	if reflected+transmitted <= 0 {
+		return false, r, Vector3{}
+	}
+	pTransmit := transmitted / (reflected + transmitted)
+	normal, color := hr.Normal, m.reflectance.DivideScalar(1-pTransmit)
+	if smp.Get1D() < pTransmit {
+		normal, color = hr.Normal.Neg(), m.transmittance.DivideScalar(pTransmit)
+	}
+	scatterDirection := normal.Add(SampleUnitSphere(smp.Get2D()))
+	if NearZeroVector(scatterDirection) {
+		scatterDirection = normal
+	}
+	return true, r.Spawn(hr.P, scatterDirection), color
+}
+
+// Albedo returns the reflected color.
+func (m TranslucentMaterial) Albedo(hr *HitRecord) Vector3 {
+	return m.reflectance
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "math"
+
+type triangle struct {
+	Vertices [3]Vector3
+	Normals  [3]Vector3
+	UVs      [3][2]float64
+	Material Material
+}
+
+// NewTriangle returns a flat triangle.  U and V run from a towards b
+// and c.
+func NewTriangle(a Vector3, b Vector3, c Vector3, mat Material) Hittable {
+	n := b.Subtract(a).Cross(c.Subtract(a)).Normalize()
+	return triangle{
+		Vertices: [3]Vector3{a, b, c},
+		Normals:  [3]Vector3{n, n, n},
+		UVs:      [3][2]float64{{0, 0}, {1, 0}, {0, 1}},
+		Material: mat,
+	}
+}
+
+// Hit uses the Möller-Trumbore test, and interpolates the normals and
+// UVs given at the corners.
+func (tr triangle) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	r.countIntersectionTest()
+	e1 := tr.Vertices[1].Subtract(tr.Vertices[0])
+	e2 := tr.Vertices[2].Subtract(tr.Vertices[0])
+	pvec := r.Direction.Cross(e2)
+	det := e1.Dot(pvec)
+	if math.Abs(det) < 1e-12 {
+		return nil
+	}
+	inv := 1 / det
+	tvec := r.Origin.Subtract(tr.Vertices[0])
+	b1 := tvec.Dot(pvec) * inv
+	if b1 < 0 || b1 > 1 {
+		return nil
+	}
+	qvec := tvec.Cross(e1)
+	b2 := r.Direction.Dot(qvec) * inv
+	if b2 < 0 || b1+b2 > 1 {
+		return nil
+	}
+	t := e2.Dot(qvec) * inv
+	if t < tMin || t > tMax {
+		return nil
+	}
+	b0 := 1 - b1 - b2
+	normal := tr.Normals[0].MultiplyScalar(b0).
+		Add(tr.Normals[1].MultiplyScalar(b1)).
+		Add(tr.Normals[2].MultiplyScalar(b2)).
+		Normalize()
+	hr := &HitRecord{
+		T:        t,
+		P:        r.Point(t),
+		U:        b0*tr.UVs[0][0] + b1*tr.UVs[1][0] + b2*tr.UVs[2][0],
+		V:        b0*tr.UVs[0][1] + b1*tr.UVs[1][1] + b2*tr.UVs[2][1],
+		Material: tr.Material,
+	}
+	hr.DPDU, hr.DPDV = tr.tangents(e1, e2)
+	hr.SetFaceNormal(r, normal)
+	return hr
+}
+
+// tangents returns how P moves with U and V across the triangle with
+// edges e1 and e2, or zeros if the UVs do not span it.
+func (tr triangle) tangents(e1 Vector3, e2 Vector3) (Vector3, Vector3) {
+	du1, dv1 := tr.UVs[1][0]-tr.UVs[0][0], tr.UVs[1][1]-tr.UVs[0][1]
+	du2, dv2 := tr.UVs[2][0]-tr.UVs[0][0], tr.UVs[2][1]-tr.UVs[0][1]
+	det := du1*dv2 - dv1*du2
+	if math.Abs(det) < 1e-12 {
+		return Vector3{}, Vector3{}
+	}
+	inv := 1 / det
+	dpdu := e1.MultiplyScalar(dv2).Subtract(e2.MultiplyScalar(dv1)).MultiplyScalar(inv)
+	dpdv := e2.MultiplyScalar(du1).Subtract(e1.MultiplyScalar(du2)).MultiplyScalar(inv)
+	return dpdu, dpdv
+}
+
+func (tr triangle) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return aabb{
+		minVector(tr.Vertices[0], minVector(tr.Vertices[1], tr.Vertices[2])),
+		maxVector(tr.Vertices[0], maxVector(tr.Vertices[1], tr.Vertices[2])),
+	}, true
+}
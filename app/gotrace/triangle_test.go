@@ -0,0 +1,39 @@
+/*
+ * Copyright 2022 Michael Graff.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTriangle_Hit(t *testing.T) {
+	tri := NewTriangle(Vector3{0, 0, 0}, Vector3{2, 0, 0}, Vector3{0, 0, 2}, nil)
+	hr := tri.Hit(NewRay(Vector3{0.5, 1, 0.5}, Vector3{0, -1, 0}, 0), 0.001, math.Inf(1))
+	if hr == nil {
+		t.Fatal("Hit() missed")
+	}
+	if hr.T != 1 || hr.U != 0.25 || hr.V != 0.25 {
+		t.Errorf("Hit() = %v at %v, %v, want 1 at 0.25, 0.25", hr.T, hr.U, hr.V)
+	}
+	if hr.Normal != (Vector3{0, 1, 0}) {
+		t.Errorf("Hit().Normal = %v, want up", hr.Normal)
+	}
+	if hr := tri.Hit(NewRay(Vector3{1.5, 1, 1.5}, Vector3{0, -1, 0}, 0), 0.001, math.Inf(1)); hr != nil {
+		t.Errorf("Hit() outside = %v, want miss", hr.P)
+	}
+}
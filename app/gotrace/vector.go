@@ -52,6 +52,12 @@ func (v Vector3) Multiply(o Vector3) Vector3 {
 	return Vector3{v.X * o.X, v.Y * o.Y, v.Z * o.Z}
 }
 
+// Divide divides each element of the vector by the matching element
+// of "o", and returns a new vector.
+func (v Vector3) Divide(o Vector3) Vector3 {
+	return Vector3{v.X / o.X, v.Y / o.Y, v.Z / o.Z}
+}
+
 // AddScalar adds the scalar component to each element of the
 // vector, and returns a new vector.
 // This is in effect a translation.
@@ -159,6 +165,37 @@ func RandomUnitSphere() Vector3 {
 	}
 }
 
+// SampleUnitSphere maps two uniform numbers in [0, 1) to a point on the
+// surface of the unit sphere, evenly spread.
+func SampleUnitSphere(u float64, v float64) Vector3 {
+	z := 1 - 2*u
+	r := math.Sqrt(math.Max(0, 1-z*z))
+	sinPhi, cosPhi := math.Sincos(2 * math.Pi * v)
+	return Vector3{r * cosPhi, r * sinPhi, z}
+}
+
+// SampleUnitDisk maps two uniform numbers in [0, 1) to a point within
+// the unit disk, with Z = 0.  It uses Shirley's concentric mapping,
+// which keeps samples which were well spread in the square well spread
+// in the disk.
+func SampleUnitDisk(u float64, v float64) Vector3 {
+	a := 2*u - 1
+	b := 2*v - 1
+	if a == 0 && b == 0 {
+		return Vector3{}
+	}
+	var r, theta float64
+	if math.Abs(a) > math.Abs(b) {
+		r = a
+		theta = math.Pi / 4 * (b / a)
+	} else {
+		r = b
+		theta = math.Pi/2 - math.Pi/4*(a/b)
+	}
+	sinTheta, cosTheta := math.Sincos(theta)
+	return Vector3{r * cosTheta, r * sinTheta, 0}
+}
+
 // RandomUnitDisk returns a random vector that is constrained by a disk.  That is,
 // Z = 0 for all.
 func RandomUnitDisk() Vector3 {
@@ -189,3 +226,13 @@ func NearZeroVector(v Vector3) bool {
 	const s = 1e-8
 	return (math.Abs(v.X) < s) && (math.Abs(v.Y) < s) && (math.Abs(v.Z) < s)
 }
+
+// orthonormalBasis returns two unit vectors which are perpendicular to
+// the unit vector n and to each other, by the method of Duff et al.
+func orthonormalBasis(n Vector3) (Vector3, Vector3) {
+	sign := math.Copysign(1, n.Z)
+	a := -1 / (sign + n.Z)
+	b := n.X * n.Y * a
+	return Vector3{1 + sign*n.X*n.X*a, sign * b, -sign * n.X},
+		Vector3{b, sign + n.Y*n.Y*a, -n.Y}
+}
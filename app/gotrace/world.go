@@ -16,6 +16,8 @@
 
 package main
 
+import "math"
+
 // World defines our massive world.
 type World struct {
 	Camera   Camera
@@ -23,31 +25,124 @@ type World struct {
 	MaxDepth int
 	TMin     float64
 	TMax     float64
+	// Spectral renders by tracing wavelengths of light, rather than
+	// red, green and blue, so that dispersion can be seen.
+	Spectral bool
+
+	// bvh holds the objects for Hit to search, once WithBVH has built
+	// it.
+	bvh Hittable
 }
 
-// Cast returns the color of a point, using the vector to define
-// where it is cast into the scene.
-func (w World) Cast(r Ray, depth int) Vector3 {
-	depth--
-	if depth < 0 {
-		return Vector3{}
+// indexedObject is an object which marks its hits with its ObjectID.
+type indexedObject struct {
+	Object Hittable
+	ID     int
+}
+
+func (o indexedObject) Hit(r Ray, tMin float64, tMax float64) *HitRecord {
+	hr := o.Object.Hit(r, tMin, tMax)
+	if hr != nil {
+		hr.ObjectID = o.ID
 	}
+	return hr
+}
 
+func (o indexedObject) BoundingBox(time0 float64, time1 float64) (aabb, bool) {
+	return o.Object.BoundingBox(time0, time1)
+}
+
+// WithBVH returns the world with a bounding volume hierarchy built over
+// its objects, for the shutter interval time0 to time1, so that rays
+// are only tested against the objects near them.  It should be called
+// again whenever Objects or the interval changes.
+func (w World) WithBVH(time0 float64, time1 float64) World {
+	objects := make([]Hittable, len(w.Objects))
+	for i, obj := range w.Objects {
+		objects[i] = indexedObject{Object: obj, ID: i + 1}
+	}
+	w.bvh = NewBVH(objects, time0, time1)
+	return w
+}
+
+// Hit returns the closest object hit by the ray, or nil if nothing
+// was hit.
+func (w World) Hit(r Ray) *HitRecord {
+	if w.bvh != nil {
+		return visibleHit(w.bvh, r, w.TMin, w.TMax)
+	}
 	var closestHit *HitRecord
-	smallestDistance := world.TMax
-	for _, obj := range world.Objects {
-		if hitRecord := obj.Hit(r, world.TMin, smallestDistance); hitRecord != nil {
+	smallestDistance := w.TMax
+	for i, obj := range w.Objects {
+		if hitRecord := visibleHit(obj, r, w.TMin, smallestDistance); hitRecord != nil {
 			if closestHit == nil || closestHit.T > hitRecord.T {
 				smallestDistance = hitRecord.T
 				closestHit = hitRecord
+				closestHit.ObjectID = i + 1
 			}
 		}
 	}
-	if closestHit != nil {
-		if propagate, scatteredRay, attentuation := closestHit.Material.Scatter(r, closestHit); propagate {
-			return attentuation.Multiply(world.Cast(scatteredRay, depth-1))
+	return closestHit
+}
+
+// maxSkippedHits limits how many hits one ray passes through, such as
+// the holes of an alpha mask or the backs of one-sided surfaces, in
+// case an object keeps reporting the same one.
+const maxSkippedHits = 64
+
+// visibleHit returns the closest hit on obj, passing through the backs
+// of one-sided surfaces.
+func visibleHit(obj Hittable, r Ray, tMin float64, tMax float64) *HitRecord {
+	for i := 0; i < maxSkippedHits; i++ {
+		hr := obj.Hit(r, tMin, tMax)
+		if hr == nil || !culled(hr) {
+			return hr
+		}
+		tMin = math.Nextafter(hr.T, math.Inf(1))
+	}
+	return nil
+}
+
+// Cast returns the color of a point, using the vector to define
+// where it is cast into the scene.
+func (w World) Cast(r Ray, depth int, smp Sampler) Vector3 {
+	color, _ := w.CastFirst(r, depth, smp)
+	return color
+}
+
+// CastFirst is Cast, which also returns what the ray hit first, or nil
+// if it hit nothing.
+func (w World) CastFirst(r Ray, depth int, smp Sampler) (Vector3, *HitRecord) {
+	depth--
+	if depth < 0 {
+		return Vector3{}, nil
+	}
+
+	if closestHit := w.Hit(r); closestHit != nil {
+		// Light reaching the hit through a medium is partly absorbed
+		// along the way.
+		transmittance := Vector3{1, 1, 1}
+		if medium, ok := r.interior.current(); ok {
+			transmittance = r.wavelengths.fromRGB(medium.transmittance(closestHit.T * r.Direction.Length()))
+		}
+		if mm, ok := closestHit.Material.(MediumMaterial); ok && r.interior.falseHit(mm.Interior()) {
+			// The surface is within a medium which takes priority, so
+			// carry straight on, without using up a bounce.
+			next := r.Spawn(closestHit.P, r.Direction)
+			next.interior = r.interior.cross(mm.Interior(), closestHit.FrontFace)
+			color, hit := w.CastFirst(next, depth+1, smp)
+			return transmittance.Multiply(color), hit
+		}
+		if propagate, scatteredRay, attentuation := closestHit.Material.Scatter(r, closestHit, smp); propagate {
+			scatteredRay.countSecondaryRay()
+			incoming := w.Cast(scatteredRay, depth-1, smp)
+			if scatteredRay.wavelengths.Collapsed && !r.wavelengths.Collapsed {
+				incoming = r.wavelengths.collapsed(incoming)
+			}
+			attentuation = r.wavelengths.fromRGB(attentuation)
+			return transmittance.Multiply(attentuation).Multiply(incoming), closestHit
 		}
-		return Vector3{}
+		return Vector3{}, closestHit
 	}
 
 	// make unit vector so y is between -1.0 and 1.0
@@ -59,5 +154,5 @@ func (w World) Cast(r Ray, depth int) Vector3 {
 	white := Vector3{1.0, 1.0, 1.0}
 	blue := Vector3{0.5, 0.7, 1.0}
 
-	return white.Lerp(blue, t)
+	return r.wavelengths.fromRGB(white.Lerp(blue, t)), nil
 }